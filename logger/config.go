@@ -0,0 +1,195 @@
+package logger
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"gopkg.in/yaml.v3"
+)
+
+// 日志的配置，优先级：参数 > 环境变量 > 配置文件 > 默认值
+type Config struct {
+	// debug、info、warn、error
+	Level string `json:"level" yaml:"level"`
+	// json或者console
+	Format string `json:"format" yaml:"format"`
+	// 是否输出到标准错误
+	Stderr bool `json:"stderr" yaml:"stderr"`
+	// 日志文件的路径，为空表示不写文件，目录不存在的时候会自动创建
+	File string `json:"file" yaml:"file"`
+	// 单个日志文件的最大大小（MB），超过之后轮转
+	MaxSizeMB int `json:"maxSizeMB" yaml:"maxSizeMB"`
+	// 最多保留多少个轮转出来的旧文件，0表示都保留
+	MaxBackups int `json:"maxBackups" yaml:"maxBackups"`
+	// 旧文件最多保留多少天，0表示不按时间删除
+	MaxAgeDays int `json:"maxAgeDays" yaml:"maxAgeDays"`
+}
+
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+// 环境变量的名字
+const (
+	EnvLogLevel  = "MINIK8S_LOG_LEVEL"
+	EnvLogFormat = "MINIK8S_LOG_FORMAT"
+	EnvLogStderr = "MINIK8S_LOG_STDERR"
+	EnvLogFile   = "MINIK8S_LOG_FILE"
+)
+
+func DefaultConfig() Config {
+	return Config{
+		Level:      "info",
+		Format:     FormatConsole,
+		Stderr:     true,
+		MaxSizeMB:  100,
+		MaxBackups: 5,
+	}
+}
+
+// 从yaml文件中读取配置，文件中没有的字段保持默认值
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("parse log config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// 用环境变量覆盖配置
+func (c *Config) ApplyEnv() {
+	if v, ok := os.LookupEnv(EnvLogLevel); ok {
+		c.Level = v
+	}
+	if v, ok := os.LookupEnv(EnvLogFormat); ok {
+		c.Format = v
+	}
+	if v, ok := os.LookupEnv(EnvLogStderr); ok {
+		if b, err := strconv.ParseBool(v); err == nil {
+			c.Stderr = b
+		}
+	}
+	if v, ok := os.LookupEnv(EnvLogFile); ok {
+		c.File = v
+	}
+}
+
+// 注册命令行参数，默认值是c当前的值，解析之后直接写回c
+func (c *Config) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Level, "log-level", c.Level, "log level: debug, info, warn or error")
+	fs.StringVar(&c.Format, "log-format", c.Format, "log encoding: json or console")
+	fs.BoolVar(&c.Stderr, "log-stderr", c.Stderr, "write logs to stderr")
+	fs.StringVar(&c.File, "log-file", c.File, "write logs to this file, rotated by size; empty disables the file sink")
+	fs.IntVar(&c.MaxSizeMB, "log-file-max-size", c.MaxSizeMB, "maximum size in megabytes of the log file before it is rotated")
+	fs.IntVar(&c.MaxBackups, "log-file-max-backups", c.MaxBackups, "maximum number of rotated log files to keep, 0 keeps all")
+}
+
+func (c Config) Validate() error {
+	if _, err := zapcore.ParseLevel(c.Level); err != nil {
+		return err
+	}
+	if c.Format != FormatJSON && c.Format != FormatConsole {
+		return fmt.Errorf("unknown log format %q, must be %s or %s", c.Format, FormatJSON, FormatConsole)
+	}
+	if !c.Stderr && c.File == "" {
+		return errors.New("no log sink configured, enable stderr or set a log file")
+	}
+	if c.MaxSizeMB < 0 || c.MaxBackups < 0 || c.MaxAgeDays < 0 {
+		return errors.New("log file size, backups and age must not be negative")
+	}
+	return nil
+}
+
+// 按照配置替换所有日志（包括已经创建的子日志）的输出和级别
+func Configure(c Config) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	lvl, _ := zapcore.ParseLevel(c.Level)
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	var encoder zapcore.Encoder
+	if c.Format == FormatJSON {
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	} else {
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	}
+
+	var sinks []zapcore.WriteSyncer
+	var closer io.Closer
+	if c.Stderr {
+		sinks = append(sinks, zapcore.Lock(os.Stderr))
+	}
+	if c.File != "" {
+		if err := os.MkdirAll(filepath.Dir(c.File), 0755); err != nil {
+			return fmt.Errorf("create log directory: %w", err)
+		}
+		// 先试着打开一次，路径不可写的时候在这里报错，而不是之后每条日志都写失败
+		f, err := os.OpenFile(c.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("open log file: %w", err)
+		}
+		f.Close()
+		file := &lumberjack.Logger{
+			Filename:   c.File,
+			MaxSize:    c.MaxSizeMB,
+			MaxBackups: c.MaxBackups,
+			MaxAge:     c.MaxAgeDays,
+		}
+		sinks = append(sinks, zapcore.AddSync(file))
+		closer = file
+	}
+
+	level.SetLevel(lvl)
+	root.swap(zapcore.NewCore(encoder, zapcore.NewMultiWriteSyncer(sinks...), level), closer)
+	return nil
+}
+
+// 修改日志级别
+func SetLevel(l string) error {
+	lvl, err := zapcore.ParseLevel(l)
+	if err != nil {
+		return err
+	}
+	level.SetLevel(lvl)
+	return nil
+}
+
+// 在运行时查看和修改日志级别的http接口
+// GET返回 {"level":"info"}，PUT {"level":"debug"} 修改级别
+// 这个接口没有认证，只接受本机发来的请求
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !fromLoopback(r) {
+			http.Error(w, "log level can only be read or changed from localhost", http.StatusForbidden)
+			return
+		}
+		level.ServeHTTP(w, r)
+	})
+}
+
+func fromLoopback(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
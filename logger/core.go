@@ -0,0 +1,94 @@
+package logger
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// 可以替换下层core的zapcore.Core
+// With出来的子core只记录字段，写日志的时候再加到当前的下层core上，所以替换之后子日志也会生效
+// 加上字段之后的core会缓存起来，下层core被替换之后才重新生成
+type swappableCore struct {
+	parent *swappableCore // 为nil表示这是根
+	fields []zapcore.Field
+	cache  atomic.Pointer[derivedCore]
+
+	// 只有根使用
+	lock       sync.RWMutex
+	core       zapcore.Core
+	generation uint64    // 每次替换下层core加一
+	closer     io.Closer // 当前的日志文件，替换之后关闭
+}
+
+// 在第generation个下层core上加上字段得到的core
+type derivedCore struct {
+	generation uint64
+	core       zapcore.Core
+}
+
+func (c *swappableCore) root() *swappableCore {
+	if c.parent != nil {
+		return c.parent
+	}
+	return c
+}
+
+func (c *swappableCore) swap(core zapcore.Core, closer io.Closer) {
+	r := c.root()
+	r.lock.Lock()
+	old := r.closer
+	if r.core != nil {
+		r.core.Sync()
+	}
+	r.core, r.closer = core, closer
+	r.generation++
+	r.lock.Unlock()
+	if old != nil {
+		old.Close()
+	}
+}
+
+func (c *swappableCore) current() zapcore.Core {
+	r := c.root()
+	r.lock.RLock()
+	core, generation := r.core, r.generation
+	r.lock.RUnlock()
+	if len(c.fields) == 0 {
+		return core
+	}
+	if cached := c.cache.Load(); cached != nil && cached.generation == generation {
+		return cached.core
+	}
+	derived := core.With(c.fields)
+	c.cache.Store(&derivedCore{generation: generation, core: derived})
+	return derived
+}
+
+func (c *swappableCore) Enabled(l zapcore.Level) bool {
+	return level.Enabled(l)
+}
+
+func (c *swappableCore) With(fields []zapcore.Field) zapcore.Core {
+	all := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	all = append(all, c.fields...)
+	all = append(all, fields...)
+	return &swappableCore{parent: c.root(), fields: all}
+}
+
+func (c *swappableCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(entry.Level) {
+		return checked
+	}
+	return c.current().Check(entry, checked)
+}
+
+func (c *swappableCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.current().Write(entry, fields)
+}
+
+func (c *swappableCore) Sync() error {
+	return c.current().Sync()
+}
@@ -1,30 +1,58 @@
 package logger
 
 import (
+	"fmt"
 	"os"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
+/*
+	全局的日志，每个组件用Named得到带组件名字的子日志
+	各个包在初始化的时候就把K8sLogger保存下来了，所以之后修改配置不能替换K8sLogger，
+	而是替换它下面的core（输出的位置和格式），级别用AtomicLevel，可以在运行时修改
+*/
+
 var K8sLogger *zap.SugaredLogger
 
+var (
+	// 当前的日志级别，所有的子日志共用
+	level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	// K8sLogger和所有子日志实际写入的core，Configure的时候替换
+	root = &swappableCore{}
+)
+
 func init() {
-	//初始化日志
-	config := zap.NewProductionEncoderConfig()
-	config.EncodeTime = zapcore.ISO8601TimeEncoder
-	fileEncoder := zapcore.NewJSONEncoder(config)
-	defaultLogLevel := zapcore.DebugLevel // 设置 loglevel，debug表示所有日志都输出，info表示只输出info以上的日志
-
-	logFile, _ := os.OpenFile("./logFile/minik8s_log.json", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 06666)
-	// or os.Create()
-	writer := zapcore.AddSync(logFile)
-
-	logger := zap.New(
-		zapcore.NewCore(fileEncoder, writer, defaultLogLevel),
+	//初始化日志，先用默认配置加上环境变量，之后可以再用参数或者配置文件调用Configure
+	config := DefaultConfig()
+	config.ApplyEnv()
+	if err := Configure(config); err != nil {
+		fmt.Fprintln(os.Stderr, "invalid log config from environment, using defaults: ", err)
+		if err := Configure(DefaultConfig()); err != nil {
+			panic(err)
+		}
+	}
+	K8sLogger = zap.New(root,
 		zap.AddCaller(),
 		zap.AddStacktrace(zapcore.ErrorLevel),
-	)
-	K8sLogger = logger.Sugar()
+	).Sugar()
+}
+
+// 某个组件的日志，日志中会带上"logger":"组件名字"
+func Named(component string) *zap.SugaredLogger {
+	return K8sLogger.Named(component)
+}
+
+// 带上pod信息的日志
+func WithPod(l *zap.SugaredLogger, namespace, name, uid string) *zap.SugaredLogger {
+	return l.With("pod", namespace+"/"+name, "podUID", uid)
+}
 
+// 带上容器信息的日志，id为空的时候（容器还没有创建）只带名字
+func WithContainer(l *zap.SugaredLogger, name, id string) *zap.SugaredLogger {
+	if id == "" {
+		return l.With("container", name)
+	}
+	return l.With("container", name, "containerID", id)
 }
@@ -1,7 +1,16 @@
 package logger
 
 import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"go.uber.org/zap/zapcore"
 )
 
 func TestLogger(t *testing.T) {
@@ -9,3 +18,157 @@ func TestLogger(t *testing.T) {
 	K8sLogger.Info("init logger success")
 	K8sLogger.Sync()
 }
+
+func restoreDefaultConfig(t *testing.T) {
+	t.Cleanup(func() {
+		if err := Configure(DefaultConfig()); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestConfigureFileSink(t *testing.T) {
+	restoreDefaultConfig(t)
+	// 在Configure之前创建的子日志也要写到新的位置
+	child := WithContainer(WithPod(Named("runtime"), "default", "web", "uid1"), "nginx", "abc")
+	path := filepath.Join(t.TempDir(), "nested", "minik8s.json")
+	config := DefaultConfig()
+	config.Format = FormatJSON
+	config.Stderr = false
+	config.File = path
+	config.Level = "warn"
+	if err := Configure(config); err != nil {
+		t.Fatal(err)
+	}
+	child.Info("dropped")
+	child.Warn("kept")
+	child.Sync()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected one line, got %q", data)
+	}
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"msg": "kept", "logger": "runtime", "pod": "default/web", "podUID": "uid1", "container": "nginx", "containerID": "abc",
+	} {
+		if entry[key] != want {
+			t.Errorf("%s = %v, want %s", key, entry[key], want)
+		}
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	restoreDefaultConfig(t)
+	server := httptest.NewServer(LevelHandler())
+	defer server.Close()
+	req, err := http.NewRequest(http.MethodPut, server.URL, strings.NewReader(`{"level":"debug"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+	if !K8sLogger.Desugar().Core().Enabled(zapcore.DebugLevel) {
+		t.Fatal("debug level should be enabled")
+	}
+	if err := SetLevel("error"); err != nil {
+		t.Fatal(err)
+	}
+	if K8sLogger.Desugar().Core().Enabled(zapcore.WarnLevel) {
+		t.Fatal("warn level should be disabled")
+	}
+}
+
+func TestLevelHandlerRejectsRemoteClients(t *testing.T) {
+	restoreDefaultConfig(t)
+	req := httptest.NewRequest(http.MethodPut, "/debug/loglevel", strings.NewReader(`{"level":"debug"}`))
+	req.RemoteAddr = "10.0.0.8:52000"
+	rec := httptest.NewRecorder()
+	LevelHandler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a remote client, got %d", rec.Code)
+	}
+	if K8sLogger.Desugar().Core().Enabled(zapcore.DebugLevel) {
+		t.Fatal("debug level should not be enabled by a remote client")
+	}
+}
+
+// 记录With调用次数的core
+type countingCore struct {
+	zapcore.Core
+	with *int
+}
+
+func (c countingCore) With(fields []zapcore.Field) zapcore.Core {
+	*c.with++
+	return countingCore{Core: c.Core.With(fields), with: c.with}
+}
+
+func TestDerivedCoreIsCached(t *testing.T) {
+	restoreDefaultConfig(t)
+	var with int
+	root.swap(countingCore{Core: zapcore.NewNopCore(), with: &with}, nil)
+	child := WithPod(Named("runtime"), "default", "web", "uid1")
+	for i := 0; i < 3; i++ {
+		child.Infoln("cached")
+	}
+	if with != 1 {
+		t.Fatalf("expected the derived core to be built once, got %d", with)
+	}
+	// 替换下层core之后要重新生成
+	root.swap(countingCore{Core: zapcore.NewNopCore(), with: &with}, nil)
+	child.Infoln("rebuilt")
+	if with != 2 {
+		t.Fatalf("expected the derived core to be rebuilt after a swap, got %d", with)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	for _, c := range []Config{
+		{Level: "loud", Format: FormatJSON, Stderr: true},
+		{Level: "info", Format: "xml", Stderr: true},
+		{Level: "info", Format: FormatJSON},
+		{Level: "info", Format: FormatJSON, Stderr: true, MaxSizeMB: -1},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("expected error for %+v", c)
+		}
+	}
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.yaml")
+	if err := os.WriteFile(path, []byte("level: debug\nformat: json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Level != "debug" || config.Format != FormatJSON || !config.Stderr {
+		t.Fatalf("unexpected config from file %+v", config)
+	}
+	t.Setenv(EnvLogLevel, "warn")
+	config.ApplyEnv()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	config.AddFlags(fs)
+	if err := fs.Parse([]string{"-log-format", "console"}); err != nil {
+		t.Fatal(err)
+	}
+	if config.Level != "warn" || config.Format != FormatConsole {
+		t.Fatalf("unexpected config %+v", config)
+	}
+}
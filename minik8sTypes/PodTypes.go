@@ -5,6 +5,25 @@ const (
 	KubernetesPodNameLabel      = "io.minik8s.pod.name"
 	KubernetesPodNamespaceLabel = "io.minik8s.pod.namespace"
 	KubernetesPodUIDLabel       = "io.minik8s.pod.uid"
+	// 同一个容器每重启一次attempt加一，从0开始
+	KubernetesContainerAttemptLabel = "io.minik8s.container.attempt"
+	// 容器日志文件的路径 <root>/pods/<uid>/<container>/<attempt>.log
+	KubernetesContainerLogPathLabel = "io.minik8s.container.logpath"
+	// 容器终止信息文件在宿主机上的路径 <root>/pods/<uid>/<container>/<attempt>.termination-log
+	KubernetesContainerTerminationMessagePathLabel = "io.minik8s.container.terminationMessagePath"
+)
+
+// pod来源相关的注解
+const (
+	// pod来自哪里，file表示静态pod，api表示来自apiserver
+	ConfigSourceAnnotation = "io.minik8s.config.source"
+	// 静态pod根据文件内容算出来的hash，也就是它的uid
+	ConfigHashAnnotation = "io.minik8s.config.hash"
+	// 带有这个注解的pod是静态pod在apiserver中的镜像（mirror pod）
+	ConfigMirrorAnnotation = "io.minik8s.config.mirror"
+
+	FileSource      = "file"
+	ApiserverSource = "api"
 )
 
 type RestartPolicy string
@@ -22,7 +41,7 @@ type PodType string
 const (
 	Minik8sPodTypeLabel   = "io.minik8s.pod.type"
 	Minik8sPausePodType   = "pause"
-	Minik8sPauseImage     = "k8s.gcr.io/pause:3.1"
+	Minik8sPauseImage     = "registry.k8s.io/pause:3.9" // 默认的pause镜像，可以在kubelet配置中修改
 	Minik8sGenericPodType = "generic"
 )
 
@@ -35,6 +35,8 @@ type Config struct {
 	//我们不需要使用太多，所以只保留了一些常用的
 	//********** docker custom config ***********************//
 	Tty             bool                // 是否需要Tty终端 Attach standard streams to a tty, including stdin if it is not closed.
+	OpenStdin       bool                // 保持stdin打开，attach的时候可以往容器里输入 Open stdin
+	StdinOnce       bool                // 第一个attach的客户端断开之后关闭stdin If true, close stdin after the 1 attached client disconnects.
 	Env             []string            // 环境变量 List of environment variable to set in the container
 	Cmd             []string            // 启动子容器的时候执行的命令 Command to run when starting the container
 	Entrypoint      []string            // Entrypoint to run when starting the container
@@ -118,15 +120,21 @@ type HostConfig struct {
 	//****************************************************//
 	//我们不需要使用太多，所以只保留了一些常用的
 	//********** docker custom config ***********************//
-	VolumesFrom      []string    // List of volumes to take from other containers
-	Links            []string    // List of links (in the name:alias form)
-	NetworkMode      string      // [网络模式] Network mode to use for the container
-	PidMode          string      // [PidMode] PID namespace to use for the container
-	IpcMode          string      // [IPC Mode ]IPC namespace to use for the container(设置这三个可以让容器共享网络、PID、IPC的ns)
-	Binds            []string    // List of volume bindings for this container
-	PortBindings     nat.PortMap // List of port bindings for this container // Port mapping between the exposed port (container) and the host 配置端口映射，这通常与exposedPorts配合使用
-	CPUResourceLimit int64       // CPU资源限制 单位是10的负9次方核
-	MemoryLimit      int64       // 内存资源限制 单位是字节
+	VolumesFrom      []string          // List of volumes to take from other containers
+	Links            []string          // List of links (in the name:alias form)
+	NetworkMode      string            // [网络模式] Network mode to use for the container
+	PidMode          string            // [PidMode] PID namespace to use for the container
+	IpcMode          string            // [IPC Mode ]IPC namespace to use for the container(设置这三个可以让容器共享网络、PID、IPC的ns)
+	Binds            []string          // List of volume bindings for this container
+	PortBindings     nat.PortMap       // List of port bindings for this container // Port mapping between the exposed port (container) and the host 配置端口映射，这通常与exposedPorts配合使用
+	CPUResourceLimit int64             // CPU资源限制 单位是10的负9次方核
+	MemoryLimit      int64             // 内存资源限制 单位是字节
+	Sysctls          map[string]string // 内核参数
+	DNS              []string          // dns服务器
+	DNSSearch        []string          // dns搜索域
+	DNSOptions       []string          // dns选项
+	CgroupParent     string            // cgroup父目录
+	ShmSize          int64             // /dev/shm的大小，单位是字节
 }
 
 type RunningSystem string
@@ -5,83 +5,100 @@ import (
 )
 
 type Container struct {
-	Name       string
-	Image      string
-	Command    []string
-	Args       []string
-	WorkingDir string
-	Ports      []ContainerPort
+	Name       string          `json:"name" yaml:"name"`
+	Image      string          `json:"image" yaml:"image"`
+	Command    []string        `json:"command" yaml:"command"`
+	Args       []string        `json:"args" yaml:"args"`
+	WorkingDir string          `json:"workingDir" yaml:"workingDir"`
+	Ports      []ContainerPort `json:"ports" yaml:"ports"`
 	// EnvFrom                  []EnvFromSource//configmap用的，我们不需要
-	Env       []EnvVar //环境变量
-	Resources ResourceRequirements
+	Env       []EnvVar             `json:"env" yaml:"env"` //环境变量
+	Resources ResourceRequirements `json:"resources" yaml:"resources"`
 	// ResizePolicy             []ContainerResizePolicy //用于容器资源的动态变化，比如cpu，内存等
 	// RestartPolicy            *ContainerRestartPolicy //默认always，不需要动了
-	VolumeMounts []VolumeMount
+	VolumeMounts []VolumeMount `json:"volumeMounts" yaml:"volumeMounts"`
 	// VolumeDevices            []VolumeDevice //用于挂载设备，比如硬盘，这种一般是statefulset用的，因为需要把硬盘和容器绑定在一起
-	LivenessProbe  *Probe
-	ReadinessProbe *Probe
-	StartupProbe   *Probe
-	Lifecycle      *Lifecycle
-	// TerminationMessagePath   string 终止信息默认写入容器的stdout，不需要动
-	// TerminationMessagePolicy string
-	ImagePullPolicy minik8sTypes.ImagePullPolicyType
+	LivenessProbe  *Probe     `json:"livenessProbe" yaml:"livenessProbe"`
+	ReadinessProbe *Probe     `json:"readinessProbe" yaml:"readinessProbe"`
+	StartupProbe   *Probe     `json:"startupProbe" yaml:"startupProbe"`
+	Lifecycle      *Lifecycle `json:"lifecycle" yaml:"lifecycle"`
+	// 容器退出前可以把退出的原因写到这个文件里，kubelet会把它记到容器的终止状态中，默认是/dev/termination-log
+	TerminationMessagePath string `json:"terminationMessagePath" yaml:"terminationMessagePath"`
+	// 终止信息文件为空的时候怎么办，默认是File
+	TerminationMessagePolicy TerminationMessagePolicy         `json:"terminationMessagePolicy" yaml:"terminationMessagePolicy"`
+	ImagePullPolicy          minik8sTypes.ImagePullPolicyType `json:"imagePullPolicy" yaml:"imagePullPolicy"`
+	// 为容器分配stdin，这样才能attach上去输入
+	Stdin bool `json:"stdin" yaml:"stdin"`
+	// 第一次attach断开之后关闭stdin
+	StdinOnce bool `json:"stdinOnce" yaml:"stdinOnce"`
 }
 
+type TerminationMessagePolicy string
+
+const (
+	// 只使用终止信息文件的内容
+	TerminationMessageReadFile TerminationMessagePolicy = "File"
+	// 终止信息文件为空并且容器异常退出的时候，用日志的最后几行作为终止信息
+	TerminationMessageFallbackToLogsOnError TerminationMessagePolicy = "FallbackToLogsOnError"
+)
+
+const DefaultTerminationMessagePath = "/dev/termination-log"
+
 type ContainerPort struct {
-	Name          string
-	HostPort      int32
-	ContainerPort string
-	Protocol      string
-	HostIP        string
+	Name          string `json:"name" yaml:"name"`
+	HostPort      int32  `json:"hostPort" yaml:"hostPort"`
+	ContainerPort string `json:"containerPort" yaml:"containerPort"`
+	Protocol      string `json:"protocol" yaml:"protocol"`
+	HostIP        string `json:"hostIP" yaml:"hostIP"`
 }
 
 type EnvVar struct {
-	Name  string
-	Value string
+	Name  string `json:"name" yaml:"name"`
+	Value string `json:"value" yaml:"value"`
 	//ValueFrom *EnvVarSource //configmap才需要，我们简化就直接用value
 }
 
 type ResourceRequirements struct {
-	Limits   ResourceList
-	Requests ResourceList
+	Limits   ResourceList `json:"limits" yaml:"limits"`
+	Requests ResourceList `json:"requests" yaml:"requests"`
 }
 
 // https://kubernetes.io/zh-cn/docs/concepts/configuration/manage-resources-containers/ 查阅资料
 type ResourceList struct {
-	Cpu    int
-	Memory int
+	Cpu    int `json:"cpu" yaml:"cpu"`
+	Memory int `json:"memory" yaml:"memory"`
 }
 
 type VolumeMount struct {
-	Name      string
-	MountPath string
-	ReadOnly  bool
+	Name      string `json:"name" yaml:"name"`
+	MountPath string `json:"mountPath" yaml:"mountPath"`
+	ReadOnly  bool   `json:"readOnly" yaml:"readOnly"`
 }
 
 type Probe struct {
-	Handler             Handler
-	InitialDelaySeconds int32 //容器启动后多久开始探测
-	TimeoutSeconds      int32 //探测超时时间，如果超过这个时间，就认为失败了
-	PeriodSeconds       int32 //探测周期
-	SuccessThreshold    int32 //成功门限，如果连续成功次数达到这个值，就认为成功了
-	FailureThreshold    int32 //失败门限，如果连续失败次数达到这个值，就认为失败了
+	Handler             Handler `json:"handler" yaml:"handler"`
+	InitialDelaySeconds int32   `json:"initialDelaySeconds" yaml:"initialDelaySeconds"` //容器启动后多久开始探测
+	TimeoutSeconds      int32   `json:"timeoutSeconds" yaml:"timeoutSeconds"`           //探测超时时间，如果超过这个时间，就认为失败了
+	PeriodSeconds       int32   `json:"periodSeconds" yaml:"periodSeconds"`             //探测周期
+	SuccessThreshold    int32   `json:"successThreshold" yaml:"successThreshold"`       //成功门限，如果连续成功次数达到这个值，就认为成功了
+	FailureThreshold    int32   `json:"failureThreshold" yaml:"failureThreshold"`       //失败门限，如果连续失败次数达到这个值，就认为失败了
 }
 
 type Handler struct {
-	HttpGet *HttpGetAction
+	HttpGet *HttpGetAction `json:"httpGet" yaml:"httpGet"`
 	// Exec    *ExecAction
 	// TcpSocket *TcpSocketAction
 	// Grpc      *GrpcAction
 }
 
 type HttpGetAction struct {
-	Path   string
-	Port   int32
-	Scheme string
-	Host   string
+	Path   string `json:"path" yaml:"path"`
+	Port   int32  `json:"port" yaml:"port"`
+	Scheme string `json:"scheme" yaml:"scheme"`
+	Host   string `json:"host" yaml:"host"`
 }
 
 type Lifecycle struct {
-	PostStart *Handler
-	PreStop   *Handler
+	PostStart *Handler `json:"postStart" yaml:"postStart"`
+	PreStop   *Handler `json:"preStop" yaml:"preStop"`
 }
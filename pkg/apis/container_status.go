@@ -0,0 +1,82 @@
+package apis
+
+import "time"
+
+/*
+	容器的状态，参照k8s的v1.ContainerStatus
+	由kubelet根据docker中容器的各个实例生成，不直接暴露docker的类型
+*/
+
+type ContainerStatus struct {
+	Name string `json:"name" yaml:"name"`
+	// 当前实例的id，格式为docker://<id>，还没有创建的时候为空
+	ContainerID string `json:"containerID" yaml:"containerID"`
+	Image       string `json:"image" yaml:"image"`
+	ImageID     string `json:"imageID" yaml:"imageID"`
+	// 容器是否可以提供服务
+	Ready bool `json:"ready" yaml:"ready"`
+	// 容器是否已经启动
+	Started bool `json:"started" yaml:"started"`
+	// 容器重启的次数，等于当前实例的attempt
+	RestartCount int `json:"restartCount" yaml:"restartCount"`
+	// 当前的状态
+	State ContainerState `json:"state" yaml:"state"`
+	// 上一个实例退出时的状态
+	LastTerminationState ContainerState `json:"lastState" yaml:"lastState"`
+}
+
+// Waiting、Running、Terminated三者最多只有一个不为空，都为空的时候当作Waiting
+type ContainerState struct {
+	Waiting    *ContainerStateWaiting    `json:"waiting,omitempty" yaml:"waiting,omitempty"`
+	Running    *ContainerStateRunning    `json:"running,omitempty" yaml:"running,omitempty"`
+	Terminated *ContainerStateTerminated `json:"terminated,omitempty" yaml:"terminated,omitempty"`
+}
+
+type ContainerStateWaiting struct {
+	// 为什么还没有运行，比如ContainerCreating、ErrImagePull
+	Reason  string `json:"reason" yaml:"reason"`
+	Message string `json:"message" yaml:"message"`
+}
+
+type ContainerStateRunning struct {
+	StartedAt time.Time `json:"startedAt" yaml:"startedAt"`
+}
+
+type ContainerStateTerminated struct {
+	ExitCode int `json:"exitCode" yaml:"exitCode"`
+	// 为什么退出，比如Completed、Error
+	Reason      string    `json:"reason" yaml:"reason"`
+	Message     string    `json:"message" yaml:"message"`
+	StartedAt   time.Time `json:"startedAt" yaml:"startedAt"`
+	FinishedAt  time.Time `json:"finishedAt" yaml:"finishedAt"`
+	ContainerID string    `json:"containerID" yaml:"containerID"`
+}
+
+// 容器状态的原因，和k8s保持一致
+const (
+	// Waiting
+	ContainerCreating          = "ContainerCreating"
+	PodInitializing            = "PodInitializing"
+	ErrImagePull               = "ErrImagePull"
+	ImagePullBackOff           = "ImagePullBackOff"
+	ErrImageNeverPull          = "ErrImageNeverPull"
+	CreateContainerConfigError = "CreateContainerConfigError"
+	CreateContainerError       = "CreateContainerError"
+	RunContainerError          = "RunContainerError"
+	// Terminated
+	ContainerCompleted = "Completed"
+	ContainerError     = "Error"
+	ContainerOOMKilled = "OOMKilled"
+	// docker没能启动容器的进程，比如命令不存在
+	ContainerCannotRun = "ContainerCannotRun"
+)
+
+// 当前是否在运行
+func (s *ContainerStatus) IsRunning() bool {
+	return s.State.Running != nil
+}
+
+// 当前实例已经退出，并且退出码为0
+func (s *ContainerStatus) IsSucceeded() bool {
+	return s.State.Terminated != nil && s.State.Terminated.ExitCode == 0
+}
@@ -0,0 +1,24 @@
+package apis
+
+import "minik8s/minik8sTypes"
+
+// 给pod补上没有填写的默认值，静态pod和apiserver创建的pod都用它
+func SetPodDefaults(pod *Pod) {
+	pod.Kind = "Pod"
+	if pod.Namespace == "" {
+		pod.Namespace = NamespaceDefault
+	}
+	if pod.Spec.RestartPolicy == "" {
+		pod.Spec.RestartPolicy = minik8sTypes.Minik8sRestartPolicyAlways
+	}
+	for _, containers := range [][]Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for i := range containers {
+			if containers[i].TerminationMessagePath == "" {
+				containers[i].TerminationMessagePath = DefaultTerminationMessagePath
+			}
+			if containers[i].TerminationMessagePolicy == "" {
+				containers[i].TerminationMessagePolicy = TerminationMessageReadFile
+			}
+		}
+	}
+}
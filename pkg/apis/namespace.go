@@ -0,0 +1,27 @@
+package apis
+
+type Namespace struct {
+	ObjectMeta `json:"metadata" yaml:"metadata"`
+	Kind       string          `json:"kind" yaml:"kind"`
+	Status     NamespaceStatus `json:"status" yaml:"status"`
+}
+
+type NamespaceStatus struct {
+	Phase NamespacePhase `json:"phase" yaml:"phase"`
+}
+
+type NamespacePhase string
+
+const (
+	NamespaceActive NamespacePhase = "Active"
+	// namespace正在被删除，不能再在里面创建新的对象
+	NamespaceTerminating NamespacePhase = "Terminating"
+)
+
+// 没有指定namespace的对象都放在default下面，apiserver启动的时候会创建它
+const NamespaceDefault = "default"
+
+type NamespaceList struct {
+	Kind  string      `json:"kind" yaml:"kind"`
+	Items []Namespace `json:"items" yaml:"items"`
+}
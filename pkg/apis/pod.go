@@ -3,8 +3,6 @@ package apis
 import (
 	"minik8s/minik8sTypes"
 	"time"
-
-	"github.com/docker/docker/api/types"
 )
 
 type PodSandboxConfig struct {
@@ -21,41 +19,62 @@ type PodSandboxMetadata struct {
 }
 
 type Pod struct {
-	ObjectMeta
-	Spec PodSpec
-	Kind string
-	PodStatus
+	ObjectMeta `json:"metadata" yaml:"metadata"`
+	Spec       PodSpec `json:"spec" yaml:"spec"`
+	Kind       string  `json:"kind" yaml:"kind"`
+	PodStatus  `json:"status" yaml:"status"`
+}
+
+type PodList struct {
+	Kind  string `json:"kind" yaml:"kind"`
+	Items []Pod  `json:"items" yaml:"items"`
 }
 
 type ObjectMeta struct {
-	Name        string
-	UID         string
-	Namespace   string
-	Labels      map[string]string
-	Annotations map[string]string
+	Name        string            `json:"name" yaml:"name"`
+	UID         string            `json:"uid" yaml:"uid"`
+	Namespace   string            `json:"namespace" yaml:"namespace"`
+	Labels      map[string]string `json:"labels" yaml:"labels"`
+	Annotations map[string]string `json:"annotations" yaml:"annotations"`
+	// 由apiserver在创建的时候设置
+	CreationTimestamp time.Time `json:"creationTimestamp" yaml:"creationTimestamp"`
+	// 每次写入apiserver都会变化，更新的时候带上它可以检测并发修改
+	ResourceVersion string `json:"resourceVersion,omitempty" yaml:"resourceVersion,omitempty"`
 }
 
 type PodSpec struct {
-	Volumes        []HostVolume
-	Containers     []Container
-	RestartPolicy  minik8sTypes.RestartPolicy
-	InitContainers []Container
+	Volumes        []HostVolume               `json:"volumes" yaml:"volumes"`
+	Containers     []Container                `json:"containers" yaml:"containers"`
+	RestartPolicy  minik8sTypes.RestartPolicy `json:"restartPolicy" yaml:"restartPolicy"`
+	InitContainers []Container                `json:"initContainers" yaml:"initContainers"`
+	// 拉取私有仓库镜像用到的secret
+	ImagePullSecrets []LocalObjectReference `json:"imagePullSecrets" yaml:"imagePullSecrets"`
+}
+
+// 引用同一个namespace下的对象
+type LocalObjectReference struct {
+	Name string `json:"name" yaml:"name"`
 }
 
 type HostVolume struct {
-	Name string
-	Type string
-	Path string
+	Name string `json:"name" yaml:"name"`
+	Type string `json:"type" yaml:"type"`
+	Path string `json:"path" yaml:"path"`
 }
 
 type PodStatus struct {
 	// IP address allocated to the pod. Routable at least within the cluster. Empty if not yet allocated.
 	PodIP string `json:"podIP" yaml:"podIP"` //在cni设置完毕（add）后，这个值将会被设置
 
-	Phase PodPhase
+	Phase PodPhase `json:"phase" yaml:"phase"`
+
+	// pod当前所处的各个阶段是否完成，比如是否已经调度、是否可以提供服务
+	Conditions []PodCondition `json:"conditions" yaml:"conditions"`
 
-	// 容器的状态数组
-	ContainerStatuses []types.ContainerState `json:"containerStatuses" yaml:"containerStatuses"`
+	// init容器的状态，顺序和spec中的initContainers一致
+	InitContainerStatuses []ContainerStatus `json:"initContainerStatuses" yaml:"initContainerStatuses"`
+	// 容器的状态，顺序和spec中的containers一致
+	ContainerStatuses []ContainerStatus `json:"containerStatuses" yaml:"containerStatuses"`
 
 	// 最新的更新时间
 	// UpdateTime string `json:"lastUpdateTime" yaml:"lastUpdateTime"`
@@ -0,0 +1,83 @@
+package apis
+
+import "time"
+
+/*
+	pod的condition，参照k8s的v1.PodCondition
+	现在没有调度器，PodScheduled在pod到达节点的时候由kubelet设置，其他的由kubelet根据容器的状态维护
+*/
+
+type PodConditionType string
+
+const (
+	// pod已经被调度到某个节点上
+	PodScheduled PodConditionType = "PodScheduled"
+	// 所有的init容器都已经成功退出
+	PodInitialized PodConditionType = "Initialized"
+	// pod中所有的容器都ready
+	ContainersReady PodConditionType = "ContainersReady"
+	// pod可以提供服务，可以加入service的endpoints
+	PodReady PodConditionType = "Ready"
+)
+
+type ConditionStatus string
+
+const (
+	ConditionTrue    ConditionStatus = "True"
+	ConditionFalse   ConditionStatus = "False"
+	ConditionUnknown ConditionStatus = "Unknown"
+)
+
+// condition的原因，和k8s保持一致
+const (
+	PodReasonUnschedulable      = "Unschedulable"
+	PodReasonContainersNotInit  = "ContainersNotInitialized"
+	PodReasonContainersNotReady = "ContainersNotReady"
+	PodReasonPodCompleted       = "PodCompleted"
+)
+
+type PodCondition struct {
+	Type   PodConditionType `json:"type" yaml:"type"`
+	Status ConditionStatus  `json:"status" yaml:"status"`
+	// 最后一次探测这个condition的时间
+	LastProbeTime time.Time `json:"lastProbeTime" yaml:"lastProbeTime"`
+	// 最后一次从一个status变成另一个status的时间
+	LastTransitionTime time.Time `json:"lastTransitionTime" yaml:"lastTransitionTime"`
+	Reason             string    `json:"reason" yaml:"reason"`
+	Message            string    `json:"message" yaml:"message"`
+}
+
+// 找到某一种condition，没有的时候返回nil
+func (s *PodStatus) GetCondition(conditionType PodConditionType) *PodCondition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// 设置某一种condition，status没有变化的时候保留原来的LastTransitionTime
+// 返回condition是否有变化（不算LastProbeTime）
+func (s *PodStatus) SetCondition(condition PodCondition) bool {
+	if condition.LastTransitionTime.IsZero() {
+		condition.LastTransitionTime = time.Now()
+	}
+	old := s.GetCondition(condition.Type)
+	if old == nil {
+		s.Conditions = append(s.Conditions, condition)
+		return true
+	}
+	if old.Status == condition.Status {
+		condition.LastTransitionTime = old.LastTransitionTime
+	}
+	changed := old.Status != condition.Status || old.Reason != condition.Reason || old.Message != condition.Message
+	*old = condition
+	return changed
+}
+
+// pod是否可以提供服务
+func (s *PodStatus) IsReady() bool {
+	c := s.GetCondition(PodReady)
+	return c != nil && c.Status == ConditionTrue
+}
@@ -0,0 +1,29 @@
+package apis
+
+// apiserver请求失败的时候返回的内容，和k8s的metav1.Status保持一致
+type Status struct {
+	Kind    string       `json:"kind" yaml:"kind"`
+	Status  string       `json:"status" yaml:"status"`
+	Message string       `json:"message" yaml:"message"`
+	Reason  StatusReason `json:"reason" yaml:"reason"`
+	Code    int          `json:"code" yaml:"code"`
+}
+
+const (
+	StatusSuccess = "Success"
+	StatusFailure = "Failure"
+)
+
+type StatusReason string
+
+const (
+	StatusReasonNotFound         StatusReason = "NotFound"
+	StatusReasonAlreadyExists    StatusReason = "AlreadyExists"
+	StatusReasonConflict         StatusReason = "Conflict"
+	StatusReasonInvalid          StatusReason = "Invalid"
+	StatusReasonBadRequest       StatusReason = "BadRequest"
+	StatusReasonForbidden        StatusReason = "Forbidden"
+	StatusReasonMethodNotAllowed StatusReason = "MethodNotAllowed"
+	StatusReasonUnsupportedMedia StatusReason = "UnsupportedMediaType"
+	StatusReasonInternalError    StatusReason = "InternalError"
+)
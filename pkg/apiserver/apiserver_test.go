@@ -0,0 +1,388 @@
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"minik8s/minik8sTypes"
+	"minik8s/pkg/apis"
+	staticpod "minik8s/pkg/kubelet/staticPod"
+	"minik8s/pkg/storage"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"gopkg.in/yaml.v3"
+)
+
+type testClient struct {
+	t      *testing.T
+	server *httptest.Server
+}
+
+func newTestClient(t *testing.T) *testClient {
+	s, err := NewServer(context.Background(), storage.NewMemoryStorage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return &testClient{t: t, server: server}
+}
+
+// 发送请求，body不是字符串的时候编码成json；out不为空的时候把响应解析到out中
+func (c *testClient) do(method, path string, body interface{}, header map[string]string, out interface{}) int {
+	c.t.Helper()
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.server.URL+path, reader)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if out != nil {
+		if strings.Contains(resp.Header.Get("Content-Type"), "yaml") {
+			err = yaml.Unmarshal(data, out)
+		} else {
+			err = json.Unmarshal(data, out)
+		}
+		if err != nil {
+			c.t.Fatalf("decode %s %s response %q: %v", method, path, data, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// 期望请求失败，并且返回的Status和状态码一致
+func (c *testClient) expectError(method, path string, body interface{}, code int, reason apis.StatusReason) {
+	c.t.Helper()
+	var status apis.Status
+	got := c.do(method, path, body, nil, &status)
+	if got != code || status.Code != code || status.Reason != reason || status.Status != apis.StatusFailure {
+		c.t.Errorf("%s %s: expected %d %s, got %d %+v", method, path, code, reason, got, status)
+	}
+}
+
+func testPod(name string) *apis.Pod {
+	return &apis.Pod{
+		ObjectMeta: apis.ObjectMeta{Name: name, Labels: map[string]string{"app": "web"}},
+		Spec:       apis.PodSpec{Containers: []apis.Container{{Name: "nginx", Image: "nginx:latest"}}},
+	}
+}
+
+const podsPath = "/api/v1/namespaces/default/pods"
+
+func TestPodCRUD(t *testing.T) {
+	c := newTestClient(t)
+
+	pod := testPod("web")
+	pod.UID = "client-uid"
+	pod.Phase = apis.PodRunning
+	var created apis.Pod
+	if code := c.do(http.MethodPost, podsPath, pod, nil, &created); code != http.StatusCreated {
+		t.Fatalf("create pod: %d", code)
+	}
+	if created.UID == "" || created.UID == "client-uid" || created.CreationTimestamp.IsZero() || created.ResourceVersion == "" {
+		t.Fatalf("server managed fields not set: %+v", created.ObjectMeta)
+	}
+	if created.Namespace != "default" || created.Kind != "Pod" || created.Phase != apis.PodPending ||
+		created.Spec.RestartPolicy != minik8sTypes.Minik8sRestartPolicyAlways ||
+		created.Spec.Containers[0].TerminationMessagePath != apis.DefaultTerminationMessagePath {
+		t.Fatalf("defaults not applied: %+v", created)
+	}
+
+	c.expectError(http.MethodPost, podsPath, testPod("web"), http.StatusConflict, apis.StatusReasonAlreadyExists)
+
+	var got apis.Pod
+	if code := c.do(http.MethodGet, podsPath+"/web", nil, nil, &got); code != http.StatusOK || got.UID != created.UID {
+		t.Fatalf("get pod: %d %+v", code, got.ObjectMeta)
+	}
+	c.expectError(http.MethodGet, podsPath+"/missing", nil, http.StatusNotFound, apis.StatusReasonNotFound)
+
+	// 更新的时候uid和创建时间保持不变，resourceVersion变化
+	got.Labels["tier"] = "frontend"
+	got.Phase = apis.PodRunning
+	var updated apis.Pod
+	if code := c.do(http.MethodPut, podsPath+"/web", &got, nil, &updated); code != http.StatusOK {
+		t.Fatalf("update pod: %d", code)
+	}
+	if updated.UID != created.UID || !updated.CreationTimestamp.Equal(created.CreationTimestamp) ||
+		updated.ResourceVersion == created.ResourceVersion || updated.Labels["tier"] != "frontend" || updated.Phase != apis.PodRunning {
+		t.Fatalf("unexpected updated pod %+v", updated)
+	}
+	// 用旧的resourceVersion更新会冲突
+	c.expectError(http.MethodPut, podsPath+"/web", &got, http.StatusConflict, apis.StatusReasonConflict)
+	c.expectError(http.MethodPut, podsPath+"/other", &updated, http.StatusBadRequest, apis.StatusReasonBadRequest)
+	c.expectError(http.MethodPut, podsPath+"/missing", testPod("missing"), http.StatusNotFound, apis.StatusReasonNotFound)
+
+	var deleted apis.Pod
+	if code := c.do(http.MethodDelete, podsPath+"/web", nil, nil, &deleted); code != http.StatusOK || deleted.UID != created.UID {
+		t.Fatalf("delete pod: %d %+v", code, deleted.ObjectMeta)
+	}
+	c.expectError(http.MethodGet, podsPath+"/web", nil, http.StatusNotFound, apis.StatusReasonNotFound)
+	c.expectError(http.MethodDelete, podsPath+"/web", nil, http.StatusNotFound, apis.StatusReasonNotFound)
+}
+
+func TestCreateInvalidPod(t *testing.T) {
+	c := newTestClient(t)
+	noContainers := testPod("web")
+	noContainers.Spec.Containers = nil
+	badName := testPod("Web_1")
+	noImage := testPod("web")
+	noImage.Spec.Containers[0].Image = ""
+	duplicate := testPod("web")
+	duplicate.Spec.InitContainers = []apis.Container{{Name: "nginx", Image: "busybox"}}
+	badPolicy := testPod("web")
+	badPolicy.Spec.RestartPolicy = "Sometimes"
+	for _, pod := range []*apis.Pod{noContainers, badName, noImage, duplicate, badPolicy, testPod("")} {
+		c.expectError(http.MethodPost, podsPath, pod, http.StatusUnprocessableEntity, apis.StatusReasonInvalid)
+	}
+
+	other := testPod("web")
+	other.Namespace = "other"
+	c.expectError(http.MethodPost, podsPath, other, http.StatusBadRequest, apis.StatusReasonBadRequest)
+	c.expectError(http.MethodPost, "/api/v1/namespaces/other/pods", testPod("web"), http.StatusNotFound, apis.StatusReasonNotFound)
+	c.expectError(http.MethodPost, podsPath, "{not json", http.StatusBadRequest, apis.StatusReasonBadRequest)
+	c.expectError(http.MethodPost, podsPath, "", http.StatusBadRequest, apis.StatusReasonBadRequest)
+	c.expectError(http.MethodPatch, podsPath+"/web", testPod("web"), http.StatusMethodNotAllowed, apis.StatusReasonMethodNotAllowed)
+	c.expectError(http.MethodPost, "/api/v1/pods", testPod("web"), http.StatusMethodNotAllowed, apis.StatusReasonMethodNotAllowed)
+	c.expectError(http.MethodGet, "/api/v1/services", nil, http.StatusNotFound, apis.StatusReasonNotFound)
+
+	var status apis.Status
+	code := c.do(http.MethodPost, podsPath, "name: web", map[string]string{"Content-Type": "text/plain"}, &status)
+	if code != http.StatusUnsupportedMediaType || status.Reason != apis.StatusReasonUnsupportedMedia {
+		t.Errorf("expected unsupported media type, got %d %+v", code, status)
+	}
+}
+
+func TestYAMLBodies(t *testing.T) {
+	c := newTestClient(t)
+	manifest := `
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+    - name: nginx
+      image: nginx:latest
+`
+	var created apis.Pod
+	code := c.do(http.MethodPost, podsPath, manifest, map[string]string{"Content-Type": "application/yaml", "Accept": "application/yaml"}, &created)
+	if code != http.StatusCreated || created.Name != "web" || created.UID == "" {
+		t.Fatalf("create pod from yaml: %d %+v", code, created.ObjectMeta)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, c.server.URL+podsPath+"/web", nil)
+	req.Header.Set("Accept", "application/yaml")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.Header.Get("Content-Type") != contentTypeYAML || !strings.Contains(string(data), "uid: "+created.UID) {
+		t.Fatalf("expected a yaml response, got %s %q", resp.Header.Get("Content-Type"), data)
+	}
+}
+
+func TestListPods(t *testing.T) {
+	c := newTestClient(t)
+	if code := c.do(http.MethodPost, "/api/v1/namespaces", &apis.Namespace{ObjectMeta: apis.ObjectMeta{Name: "prod"}}, nil, nil); code != http.StatusCreated {
+		t.Fatalf("create namespace: %d", code)
+	}
+	c.do(http.MethodPost, podsPath, testPod("db"), nil, nil)
+	c.do(http.MethodPost, podsPath, testPod("api"), nil, nil)
+	c.do(http.MethodPost, "/api/v1/namespaces/prod/pods", testPod("web"), nil, nil)
+
+	var list apis.PodList
+	if code := c.do(http.MethodGet, podsPath, nil, nil, &list); code != http.StatusOK || list.Kind != "PodList" {
+		t.Fatalf("list pods: %d", code)
+	}
+	if len(list.Items) != 2 || list.Items[0].Name != "api" || list.Items[1].Name != "db" {
+		t.Fatalf("unexpected pods in default %+v", list.Items)
+	}
+	c.do(http.MethodGet, "/api/v1/pods", nil, nil, &list)
+	if len(list.Items) != 3 || list.Items[2].Namespace != "prod" {
+		t.Fatalf("unexpected pods in all namespaces %+v", list.Items)
+	}
+	c.do(http.MethodGet, "/api/v1/namespaces/prod/pods", nil, nil, &list)
+	if len(list.Items) != 1 || list.Items[0].Name != "web" {
+		t.Fatalf("unexpected pods in prod %+v", list.Items)
+	}
+	c.expectError(http.MethodGet, "/api/v1/namespaces/missing/pods", nil, http.StatusNotFound, apis.StatusReasonNotFound)
+}
+
+func TestNamespaceCRUD(t *testing.T) {
+	c := newTestClient(t)
+	var list apis.NamespaceList
+	c.do(http.MethodGet, "/api/v1/namespaces", nil, nil, &list)
+	if len(list.Items) != 1 || list.Items[0].Name != apis.NamespaceDefault || list.Items[0].Status.Phase != apis.NamespaceActive {
+		t.Fatalf("default namespace should exist %+v", list.Items)
+	}
+
+	var created apis.Namespace
+	if code := c.do(http.MethodPost, "/api/v1/namespaces", &apis.Namespace{ObjectMeta: apis.ObjectMeta{Name: "prod"}}, nil, &created); code != http.StatusCreated {
+		t.Fatalf("create namespace: %d", code)
+	}
+	if created.UID == "" || created.Kind != "Namespace" || created.Status.Phase != apis.NamespaceActive {
+		t.Fatalf("unexpected namespace %+v", created)
+	}
+	c.expectError(http.MethodPost, "/api/v1/namespaces", &apis.Namespace{ObjectMeta: apis.ObjectMeta{Name: "prod"}}, http.StatusConflict, apis.StatusReasonAlreadyExists)
+	c.expectError(http.MethodPost, "/api/v1/namespaces", &apis.Namespace{ObjectMeta: apis.ObjectMeta{Name: "prod.eu"}}, http.StatusUnprocessableEntity, apis.StatusReasonInvalid)
+
+	created.Labels = map[string]string{"env": "prod"}
+	var updated apis.Namespace
+	if code := c.do(http.MethodPut, "/api/v1/namespaces/prod", &created, nil, &updated); code != http.StatusOK || updated.Labels["env"] != "prod" || updated.UID != created.UID {
+		t.Fatalf("update namespace: %d %+v", code, updated)
+	}
+	c.expectError(http.MethodPut, "/api/v1/namespaces/prod", &created, http.StatusConflict, apis.StatusReasonConflict)
+
+	// 删除namespace的时候里面的pod也被删除
+	c.do(http.MethodPost, "/api/v1/namespaces/prod/pods", testPod("web"), nil, nil)
+	if code := c.do(http.MethodDelete, "/api/v1/namespaces/prod", nil, nil, nil); code != http.StatusOK {
+		t.Fatalf("delete namespace: %d", code)
+	}
+	c.expectError(http.MethodGet, "/api/v1/namespaces/prod", nil, http.StatusNotFound, apis.StatusReasonNotFound)
+	c.expectError(http.MethodGet, "/api/v1/namespaces/prod/pods/web", nil, http.StatusNotFound, apis.StatusReasonNotFound)
+	c.expectError(http.MethodDelete, "/api/v1/namespaces/default", nil, http.StatusForbidden, apis.StatusReasonForbidden)
+}
+
+// 正在删除的namespace不能创建pod，并发创建的pod不能在namespace删除之后残留下来
+func TestNamespaceTerminating(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStorage()
+	r := newRegistry(store)
+	if _, err := r.createNamespace(ctx, &apis.Namespace{ObjectMeta: apis.ObjectMeta{Name: "prod"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 上一次删除中途失败，namespace停在Terminating
+	ns, err := r.getNamespace(ctx, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns.Status.Phase = apis.NamespaceTerminating
+	data, _ := encode(&ns.ObjectMeta, ns)
+	if _, err := store.Update(ctx, namespaceKey("prod"), data, 0); err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.createPod(ctx, &apis.Pod{ObjectMeta: apis.ObjectMeta{Name: "web", Namespace: "prod"}})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.ErrStatus.Reason != apis.StatusReasonForbidden {
+		t.Fatalf("expected forbidden, got %v", err)
+	}
+	if _, err := r.deleteNamespace(ctx, "prod"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		if _, err := r.createNamespace(ctx, &apis.Namespace{ObjectMeta: apis.ObjectMeta{Name: "prod"}}); err != nil {
+			t.Fatal(err)
+		}
+		var wg sync.WaitGroup
+		for j := 0; j < 5; j++ {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				r.createPod(ctx, &apis.Pod{ObjectMeta: apis.ObjectMeta{Name: fmt.Sprintf("web-%d", j), Namespace: "prod"}})
+			}(j)
+		}
+		if _, err := r.deleteNamespace(ctx, "prod"); err != nil {
+			t.Fatal(err)
+		}
+		wg.Wait()
+		if pods, _, err := store.List(ctx, podKey("prod", "")); err != nil || len(pods) != 0 {
+			t.Fatalf("expected no pods left after deleting the namespace, got %d (%v)", len(pods), err)
+		}
+	}
+}
+
+// kubelet的mirror client应该能和apiserver配合使用
+func TestMirrorClient(t *testing.T) {
+	c := newTestClient(t)
+	mirror := staticpod.NewMirrorClient(c.server.URL)
+	pod := testPod("etcd-node1")
+	pod.Namespace = "default"
+	pod.UID = "static-uid"
+	if err := mirror.CreateMirrorPod(pod); err != nil {
+		t.Fatal(err)
+	}
+	// 已经存在不算错误
+	if err := mirror.CreateMirrorPod(pod); err != nil {
+		t.Fatal(err)
+	}
+	var got apis.Pod
+	c.do(http.MethodGet, podsPath+"/etcd-node1", nil, nil, &got)
+	if got.Annotations[minik8sTypes.ConfigMirrorAnnotation] != "static-uid" {
+		t.Fatalf("unexpected mirror pod %+v", got.ObjectMeta)
+	}
+	if err := mirror.DeleteMirrorPod(pod); err != nil {
+		t.Fatal(err)
+	}
+	if err := mirror.DeleteMirrorPod(pod); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 用redis保存的时候，重启apiserver之后对象还在
+func TestRedisBackedServer(t *testing.T) {
+	address := miniredis.RunT(t).Addr()
+	start := func() *httptest.Server {
+		store, err := storage.NewRedisStorage(context.Background(), storage.RedisOptions{Address: address})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := NewServer(context.Background(), store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := httptest.NewServer(s)
+		t.Cleanup(func() {
+			server.Close()
+			store.Close()
+		})
+		return server
+	}
+	c := &testClient{t: t, server: start()}
+	var created apis.Pod
+	if code := c.do(http.MethodPost, podsPath, testPod("web"), nil, &created); code != http.StatusCreated {
+		t.Fatalf("create pod: %d", code)
+	}
+
+	c.server = start()
+	var got apis.Pod
+	if code := c.do(http.MethodGet, podsPath+"/web", nil, nil, &got); code != http.StatusOK {
+		t.Fatalf("get pod after restart: %d", code)
+	}
+	if got.UID != created.UID || got.ResourceVersion != created.ResourceVersion || !got.CreationTimestamp.Equal(created.CreationTimestamp) {
+		t.Fatalf("pod changed after restart: %+v %+v", created.ObjectMeta, got.ObjectMeta)
+	}
+	c.expectError(http.MethodPost, podsPath, testPod("web"), http.StatusConflict, apis.StatusReasonAlreadyExists)
+}
@@ -0,0 +1,105 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 和k8s一样，请求体最大3MB
+const maxRequestBodyBytes = 3 * 1024 * 1024
+
+const (
+	contentTypeJSON = "application/json"
+	contentTypeYAML = "application/yaml"
+)
+
+func isYAML(mediaType string) bool {
+	switch mediaType {
+	case contentTypeYAML, "application/x-yaml", "text/yaml", "text/x-yaml":
+		return true
+	}
+	return false
+}
+
+// 按照Content-Type解析请求体，没有Content-Type的时候当作json
+func decodeBody(r *http.Request, obj interface{}) error {
+	mediaType := contentTypeJSON
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(contentType); err != nil {
+			return NewUnsupportedMediaType(contentType)
+		}
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodyBytes+1))
+	if err != nil {
+		return NewBadRequest("read request body: %v", err)
+	}
+	if len(data) > maxRequestBodyBytes {
+		return newStatusError(http.StatusRequestEntityTooLarge, "RequestEntityTooLarge", "request body is larger than %d bytes", maxRequestBodyBytes)
+	}
+	if len(data) == 0 {
+		return NewBadRequest("request body is empty")
+	}
+	switch {
+	case mediaType == contentTypeJSON:
+		err = json.Unmarshal(data, obj)
+	case isYAML(mediaType):
+		err = yaml.Unmarshal(data, obj)
+	default:
+		return NewUnsupportedMediaType(mediaType)
+	}
+	if err != nil {
+		return NewBadRequest("decode request body: %v", err)
+	}
+	return nil
+}
+
+// Accept里要求yaml的时候返回yaml，其他情况都返回json
+func wantsYAML(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		if mediaType == contentTypeJSON {
+			return false
+		}
+		if isYAML(mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeObject(w http.ResponseWriter, r *http.Request, code int, obj interface{}) {
+	var data []byte
+	var err error
+	contentType := contentTypeJSON
+	if wantsYAML(r) {
+		contentType = contentTypeYAML
+		data, err = yaml.Marshal(obj)
+	} else {
+		data, err = json.Marshal(obj)
+	}
+	if err != nil {
+		K8sLogger.Errorln("encode response error: ", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status := statusForError(err)
+	if status.Code == http.StatusInternalServerError {
+		K8sLogger.Errorln(r.Method, " ", r.URL.Path, " error: ", err)
+	}
+	writeObject(w, r, status.Code, status)
+}
@@ -0,0 +1,88 @@
+package apiserver
+
+import (
+	"errors"
+	"fmt"
+	"minik8s/pkg/apis"
+	"net/http"
+)
+
+// 带有http状态码的错误，handler把它原样写回给客户端
+type StatusError struct {
+	ErrStatus apis.Status
+}
+
+func (e *StatusError) Error() string {
+	return e.ErrStatus.Message
+}
+
+func newStatusError(code int, reason apis.StatusReason, format string, args ...interface{}) *StatusError {
+	return &StatusError{ErrStatus: apis.Status{
+		Kind:    "Status",
+		Status:  apis.StatusFailure,
+		Message: fmt.Sprintf(format, args...),
+		Reason:  reason,
+		Code:    code,
+	}}
+}
+
+func NewNotFound(kind, name string) *StatusError {
+	return newStatusError(http.StatusNotFound, apis.StatusReasonNotFound, "%s %q not found", kind, name)
+}
+
+func NewAlreadyExists(kind, name string) *StatusError {
+	return newStatusError(http.StatusConflict, apis.StatusReasonAlreadyExists, "%s %q already exists", kind, name)
+}
+
+func NewConflict(kind, name string, err error) *StatusError {
+	return newStatusError(http.StatusConflict, apis.StatusReasonConflict, "operation cannot be fulfilled on %s %q: %v", kind, name, err)
+}
+
+func NewInvalid(kind, name string, err error) *StatusError {
+	return newStatusError(http.StatusUnprocessableEntity, apis.StatusReasonInvalid, "%s %q is invalid: %v", kind, name, err)
+}
+
+func NewBadRequest(format string, args ...interface{}) *StatusError {
+	return newStatusError(http.StatusBadRequest, apis.StatusReasonBadRequest, format, args...)
+}
+
+func NewForbidden(kind, name string, err error) *StatusError {
+	return newStatusError(http.StatusForbidden, apis.StatusReasonForbidden, "%s %q is forbidden: %v", kind, name, err)
+}
+
+func NewMethodNotSupported(method, path string) *StatusError {
+	return newStatusError(http.StatusMethodNotAllowed, apis.StatusReasonMethodNotAllowed, "method %s is not supported on %s", method, path)
+}
+
+func NewUnsupportedMediaType(contentType string) *StatusError {
+	return newStatusError(http.StatusUnsupportedMediaType, apis.StatusReasonUnsupportedMedia, "unsupported content type %q", contentType)
+}
+
+func IsNotFound(err error) bool {
+	return reasonForError(err) == apis.StatusReasonNotFound
+}
+
+func IsAlreadyExists(err error) bool {
+	return reasonForError(err) == apis.StatusReasonAlreadyExists
+}
+
+func IsConflict(err error) bool {
+	return reasonForError(err) == apis.StatusReasonConflict
+}
+
+func reasonForError(err error) apis.StatusReason {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.ErrStatus.Reason
+	}
+	return ""
+}
+
+// 不是StatusError的错误都当作服务器内部错误
+func statusForError(err error) apis.Status {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.ErrStatus
+	}
+	return newStatusError(http.StatusInternalServerError, apis.StatusReasonInternalError, "%v", err).ErrStatus
+}
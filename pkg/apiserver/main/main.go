@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"minik8s/logger"
+	"minik8s/pkg/apiserver"
+	"minik8s/pkg/storage"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+/*
+	apiserver的入口
+	apiserver [-address :8080] [-storage redis -redis-address 127.0.0.1:6379] [-log-level debug] ...
+	收到SIGINT或者SIGTERM之后等待正在处理的请求完成再退出
+*/
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	fs := flag.NewFlagSet("apiserver", flag.ContinueOnError)
+	address := fs.String("address", ":8080", "address the api server listens on")
+	backend := fs.String("storage", "memory", "storage backend: memory or redis")
+	redisOpts := storage.RedisOptions{}
+	fs.StringVar(&redisOpts.Address, "redis-address", "127.0.0.1:6379", "redis address, used when -storage is redis")
+	fs.StringVar(&redisOpts.Password, "redis-password", "", "redis password")
+	fs.IntVar(&redisOpts.DB, "redis-db", 0, "redis database number")
+	fs.StringVar(&redisOpts.KeyPrefix, "redis-key-prefix", "minik8s", "prefix of all keys written to redis")
+	logConfig := logger.DefaultConfig()
+	logConfig.ApplyEnv()
+	logConfig.AddFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if err := logger.Configure(logConfig); err != nil {
+		fmt.Fprintln(os.Stderr, "apiserver: configure logger:", err)
+		return 2
+	}
+	log := logger.Named("apiserver")
+	defer log.Sync()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	var store storage.Storage
+	switch *backend {
+	case "memory":
+		store = storage.NewMemoryStorage()
+	case "redis":
+		var err error
+		if store, err = storage.NewRedisStorage(ctx, redisOpts); err != nil {
+			log.Errorln("create redis storage error: ", err)
+			return 1
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "apiserver: unknown storage backend %q\n", *backend)
+		return 2
+	}
+	defer store.Close()
+	server, err := apiserver.NewServer(ctx, store)
+	if err != nil {
+		log.Errorln("create apiserver error: ", err)
+		return 1
+	}
+	log.Infoln("apiserver starting with ", *backend, " storage")
+	if err := server.ListenAndServe(ctx, *address); err != nil {
+		log.Errorln("apiserver exited with error: ", err)
+		return 1
+	}
+	log.Infoln("apiserver stopped")
+	return 0
+}
@@ -0,0 +1,82 @@
+package apiserver
+
+import (
+	"minik8s/pkg/apis"
+	"net/http"
+)
+
+func (s *Server) handleNamespaces(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		items, err := s.registry.listNamespaces(r.Context())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeObject(w, r, http.StatusOK, &apis.NamespaceList{Kind: "NamespaceList", Items: items})
+	case http.MethodPost:
+		ns, err := decodeNamespace(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		created, err := s.registry.createNamespace(r.Context(), ns)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		K8sLogger.Infoln("namespace ", created.Name, " created")
+		writeObject(w, r, http.StatusCreated, created)
+	default:
+		writeError(w, r, NewMethodNotSupported(r.Method, r.URL.Path))
+	}
+}
+
+func (s *Server) handleNamespace(w http.ResponseWriter, r *http.Request, name string) {
+	switch r.Method {
+	case http.MethodGet:
+		ns, err := s.registry.getNamespace(r.Context(), name)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeObject(w, r, http.StatusOK, ns)
+	case http.MethodPut:
+		ns, err := decodeNamespace(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if ns.Name != name {
+			writeError(w, r, NewBadRequest("the name of the object %q does not match the name on the URL %q", ns.Name, name))
+			return
+		}
+		updated, err := s.registry.updateNamespace(r.Context(), ns)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeObject(w, r, http.StatusOK, updated)
+	case http.MethodDelete:
+		ns, err := s.registry.deleteNamespace(r.Context(), name)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		K8sLogger.Infoln("namespace ", name, " deleted")
+		writeObject(w, r, http.StatusOK, ns)
+	default:
+		writeError(w, r, NewMethodNotSupported(r.Method, r.URL.Path))
+	}
+}
+
+func decodeNamespace(r *http.Request) (*apis.Namespace, error) {
+	ns := &apis.Namespace{}
+	if err := decodeBody(r, ns); err != nil {
+		return nil, err
+	}
+	if err := validateNamespace(ns); err != nil {
+		return nil, NewInvalid("namespaces", ns.Name, err)
+	}
+	return ns, nil
+}
@@ -0,0 +1,102 @@
+package apiserver
+
+import (
+	"minik8s/pkg/apis"
+	"net/http"
+)
+
+// namespace为空的时候是 /api/v1/pods，只能列出
+func (s *Server) handlePods(w http.ResponseWriter, r *http.Request, namespace string) {
+	switch {
+	case r.Method == http.MethodGet:
+		items, err := s.registry.listPods(r.Context(), namespace)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeObject(w, r, http.StatusOK, &apis.PodList{Kind: "PodList", Items: items})
+	case r.Method == http.MethodPost && namespace != "":
+		s.createPod(w, r, namespace)
+	default:
+		writeError(w, r, NewMethodNotSupported(r.Method, r.URL.Path))
+	}
+}
+
+func (s *Server) handlePod(w http.ResponseWriter, r *http.Request, namespace, name string) {
+	switch r.Method {
+	case http.MethodGet:
+		pod, err := s.registry.getPod(r.Context(), namespace, name)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		writeObject(w, r, http.StatusOK, pod)
+	case http.MethodPut:
+		s.updatePod(w, r, namespace, name)
+	case http.MethodDelete:
+		pod, err := s.registry.deletePod(r.Context(), namespace, name)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		K8sLogger.Infoln("pod ", namespace, "/", name, " deleted")
+		writeObject(w, r, http.StatusOK, pod)
+	default:
+		writeError(w, r, NewMethodNotSupported(r.Method, r.URL.Path))
+	}
+}
+
+// 解析请求中的pod，namespace没写的时候用路径中的
+func decodePod(r *http.Request, namespace string) (*apis.Pod, error) {
+	pod := &apis.Pod{}
+	if err := decodeBody(r, pod); err != nil {
+		return nil, err
+	}
+	if pod.Namespace == "" {
+		pod.Namespace = namespace
+	}
+	if pod.Namespace != namespace {
+		return nil, NewBadRequest("the namespace of the provided object %q does not match the namespace sent on the request %q", pod.Namespace, namespace)
+	}
+	if err := validatePod(pod); err != nil {
+		return nil, NewInvalid("pods", pod.Name, err)
+	}
+	apis.SetPodDefaults(pod)
+	return pod, nil
+}
+
+// 新创建的pod还没有被调度和运行，客户端传过来的状态都丢掉
+func (s *Server) createPod(w http.ResponseWriter, r *http.Request, namespace string) {
+	pod, err := decodePod(r, namespace)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	pod.PodStatus = apis.PodStatus{Phase: apis.PodPending}
+	created, err := s.registry.createPod(r.Context(), pod)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	K8sLogger.Infoln("pod ", namespace, "/", created.Name, " created, uid ", created.UID)
+	writeObject(w, r, http.StatusCreated, created)
+}
+
+// 整个替换保存的pod，包括状态，kubelet通过它上报pod的状态
+func (s *Server) updatePod(w http.ResponseWriter, r *http.Request, namespace, name string) {
+	pod, err := decodePod(r, namespace)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if pod.Name != name {
+		writeError(w, r, NewBadRequest("the name of the object %q does not match the name on the URL %q", pod.Name, name))
+		return
+	}
+	updated, err := s.registry.updatePod(r.Context(), pod)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeObject(w, r, http.StatusOK, updated)
+}
@@ -0,0 +1,322 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"minik8s/pkg/apis"
+	"minik8s/pkg/storage"
+	"minik8s/pkg/uuid"
+	"strconv"
+	"time"
+)
+
+/*
+	把pod和namespace编码成json保存在storage中
+		/registry/namespaces/{name}
+		/registry/pods/{namespace}/{name}
+	uid、creationTimestamp由这里设置，客户端传过来的值会被覆盖
+	resourceVersion就是storage中的版本号，不保存在对象里面，读出来的时候再填上
+*/
+
+const (
+	namespacesPrefix = "/registry/namespaces/"
+	podsPrefix       = "/registry/pods/"
+)
+
+type registry struct {
+	store storage.Storage
+	now   func() time.Time
+}
+
+func newRegistry(store storage.Storage) *registry {
+	return &registry{store: store, now: time.Now}
+}
+
+func namespaceKey(name string) string {
+	return namespacesPrefix + name
+}
+
+// namespace为空的时候是所有pod的前缀，name为空的时候是namespace下所有pod的前缀
+func podKey(namespace, name string) string {
+	if namespace == "" {
+		return podsPrefix
+	}
+	return podsPrefix + namespace + "/" + name
+}
+
+// 把storage的错误转换成对应的http状态
+func storageError(err error, kind, name string) error {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return NewNotFound(kind, name)
+	case errors.Is(err, storage.ErrKeyExists):
+		return NewAlreadyExists(kind, name)
+	case errors.Is(err, storage.ErrConflict):
+		return NewConflict(kind, name, fmt.Errorf("the object has been modified; please apply your changes to the latest version and try again"))
+	}
+	return err
+}
+
+// 保存的对象里面不带resourceVersion
+func encode(meta *apis.ObjectMeta, obj interface{}) ([]byte, error) {
+	resourceVersion := meta.ResourceVersion
+	meta.ResourceVersion = ""
+	defer func() { meta.ResourceVersion = resourceVersion }()
+	return json.Marshal(obj)
+}
+
+func decode(kv *storage.KeyValue, meta *apis.ObjectMeta, obj interface{}) error {
+	if err := json.Unmarshal(kv.Value, obj); err != nil {
+		return fmt.Errorf("decode %s: %w", kv.Key, err)
+	}
+	meta.ResourceVersion = strconv.FormatUint(kv.ResourceVersion, 10)
+	return nil
+}
+
+func parseResourceVersion(kind, name, resourceVersion string) (uint64, error) {
+	if resourceVersion == "" {
+		return 0, nil
+	}
+	rv, err := strconv.ParseUint(resourceVersion, 10, 64)
+	if err != nil {
+		return 0, NewInvalid(kind, name, fmt.Errorf("invalid resourceVersion %q", resourceVersion))
+	}
+	return rv, nil
+}
+
+// 设置创建的时候由服务器决定的字段
+func (r *registry) initMeta(meta *apis.ObjectMeta) {
+	meta.UID = uuid.NewUID()
+	meta.CreationTimestamp = r.now().UTC().Truncate(time.Second)
+}
+
+// 更新的时候uid和创建时间不能改，返回更新时要比较的版本号
+// 客户端带了resourceVersion的时候用它，否则用读出来的版本号，保证读和写之间没有别人修改过
+func (r *registry) updateMeta(kind string, meta *apis.ObjectMeta, old *apis.ObjectMeta) (uint64, error) {
+	if meta.UID != "" && meta.UID != old.UID {
+		return 0, NewConflict(kind, meta.Name, fmt.Errorf("uid %s does not match %s", meta.UID, old.UID))
+	}
+	resourceVersion := meta.ResourceVersion
+	if resourceVersion == "" {
+		resourceVersion = old.ResourceVersion
+	}
+	meta.UID = old.UID
+	meta.CreationTimestamp = old.CreationTimestamp
+	return parseResourceVersion(kind, meta.Name, resourceVersion)
+}
+
+func (r *registry) createNamespace(ctx context.Context, ns *apis.Namespace) (*apis.Namespace, error) {
+	ns.Namespace = ""
+	ns.Kind = "Namespace"
+	ns.Status.Phase = apis.NamespaceActive
+	r.initMeta(&ns.ObjectMeta)
+	data, err := encode(&ns.ObjectMeta, ns)
+	if err != nil {
+		return nil, err
+	}
+	rv, err := r.store.Create(ctx, namespaceKey(ns.Name), data)
+	if err != nil {
+		return nil, storageError(err, "namespaces", ns.Name)
+	}
+	ns.ResourceVersion = strconv.FormatUint(rv, 10)
+	return ns, nil
+}
+
+func (r *registry) getNamespace(ctx context.Context, name string) (*apis.Namespace, error) {
+	kv, err := r.store.Get(ctx, namespaceKey(name))
+	if err != nil {
+		return nil, storageError(err, "namespaces", name)
+	}
+	ns := &apis.Namespace{}
+	if err := decode(kv, &ns.ObjectMeta, ns); err != nil {
+		return nil, err
+	}
+	return ns, nil
+}
+
+func (r *registry) listNamespaces(ctx context.Context) ([]apis.Namespace, error) {
+	kvs, _, err := r.store.List(ctx, namespacesPrefix)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]apis.Namespace, len(kvs))
+	for i := range kvs {
+		if err := decode(&kvs[i], &items[i].ObjectMeta, &items[i]); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// namespace只能修改labels和annotations
+func (r *registry) updateNamespace(ctx context.Context, ns *apis.Namespace) (*apis.Namespace, error) {
+	old, err := r.getNamespace(ctx, ns.Name)
+	if err != nil {
+		return nil, err
+	}
+	updated := *old
+	updated.UID = ns.UID
+	updated.ResourceVersion = ns.ResourceVersion
+	updated.Labels = ns.Labels
+	updated.Annotations = ns.Annotations
+	expected, err := r.updateMeta("namespaces", &updated.ObjectMeta, &old.ObjectMeta)
+	if err != nil {
+		return nil, err
+	}
+	data, err := encode(&updated.ObjectMeta, &updated)
+	if err != nil {
+		return nil, err
+	}
+	rv, err := r.store.Update(ctx, namespaceKey(ns.Name), data, expected)
+	if err != nil {
+		return nil, storageError(err, "namespaces", ns.Name)
+	}
+	updated.ResourceVersion = strconv.FormatUint(rv, 10)
+	return &updated, nil
+}
+
+// 删除namespace的时候连同里面的pod一起删除
+// 先把namespace标记为Terminating，之后createPod不会再往里面创建pod，再删除里面的pod和namespace本身
+func (r *registry) deleteNamespace(ctx context.Context, name string) (*apis.Namespace, error) {
+	if name == apis.NamespaceDefault {
+		return nil, NewForbidden("namespaces", name, fmt.Errorf("this namespace may not be deleted"))
+	}
+	ns, err := r.getNamespace(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if ns.Status.Phase != apis.NamespaceTerminating {
+		expected, err := parseResourceVersion("namespaces", name, ns.ResourceVersion)
+		if err != nil {
+			return nil, err
+		}
+		ns.Status.Phase = apis.NamespaceTerminating
+		data, err := encode(&ns.ObjectMeta, ns)
+		if err != nil {
+			return nil, err
+		}
+		rv, err := r.store.Update(ctx, namespaceKey(name), data, expected)
+		if err != nil {
+			return nil, storageError(err, "namespaces", name)
+		}
+		ns.ResourceVersion = strconv.FormatUint(rv, 10)
+	}
+	pods, _, err := r.store.List(ctx, podKey(name, ""))
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods {
+		if _, err := r.store.Delete(ctx, pod.Key, 0); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return nil, err
+		}
+	}
+	if _, err := r.store.Delete(ctx, namespaceKey(name), 0); err != nil {
+		return nil, storageError(err, "namespaces", name)
+	}
+	return ns, nil
+}
+
+// 正在删除的namespace里面不能创建新的对象
+func (r *registry) checkNamespaceActive(ctx context.Context, namespace string, kind string, name string) error {
+	ns, err := r.getNamespace(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	if ns.Status.Phase == apis.NamespaceTerminating {
+		return NewForbidden(kind, name, fmt.Errorf("unable to create new content in namespace %s because it is being terminated", namespace))
+	}
+	return nil
+}
+
+func (r *registry) createPod(ctx context.Context, pod *apis.Pod) (*apis.Pod, error) {
+	if err := r.checkNamespaceActive(ctx, pod.Namespace, "pods", pod.Name); err != nil {
+		return nil, err
+	}
+	r.initMeta(&pod.ObjectMeta)
+	data, err := encode(&pod.ObjectMeta, pod)
+	if err != nil {
+		return nil, err
+	}
+	key := podKey(pod.Namespace, pod.Name)
+	rv, err := r.store.Create(ctx, key, data)
+	if err != nil {
+		return nil, storageError(err, "pods", pod.Name)
+	}
+	// 检查和创建之间namespace可能开始删除了，删除的时候列出pod可能在创建之前，
+	// 所以创建之后再检查一次，namespace已经不可用的话撤销这次创建
+	if err := r.checkNamespaceActive(ctx, pod.Namespace, "pods", pod.Name); err != nil {
+		if _, delErr := r.store.Delete(ctx, key, rv); delErr != nil && !errors.Is(delErr, storage.ErrNotFound) && !errors.Is(delErr, storage.ErrConflict) {
+			return nil, delErr
+		}
+		return nil, err
+	}
+	pod.ResourceVersion = strconv.FormatUint(rv, 10)
+	return pod, nil
+}
+
+func (r *registry) getPod(ctx context.Context, namespace, name string) (*apis.Pod, error) {
+	kv, err := r.store.Get(ctx, podKey(namespace, name))
+	if err != nil {
+		return nil, storageError(err, "pods", name)
+	}
+	pod := &apis.Pod{}
+	if err := decode(kv, &pod.ObjectMeta, pod); err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+// namespace为空的时候列出所有namespace的pod
+func (r *registry) listPods(ctx context.Context, namespace string) ([]apis.Pod, error) {
+	if namespace != "" {
+		if _, err := r.getNamespace(ctx, namespace); err != nil {
+			return nil, err
+		}
+	}
+	kvs, _, err := r.store.List(ctx, podKey(namespace, ""))
+	if err != nil {
+		return nil, err
+	}
+	items := make([]apis.Pod, len(kvs))
+	for i := range kvs {
+		if err := decode(&kvs[i], &items[i].ObjectMeta, &items[i]); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+func (r *registry) updatePod(ctx context.Context, pod *apis.Pod) (*apis.Pod, error) {
+	old, err := r.getPod(ctx, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, err
+	}
+	expected, err := r.updateMeta("pods", &pod.ObjectMeta, &old.ObjectMeta)
+	if err != nil {
+		return nil, err
+	}
+	data, err := encode(&pod.ObjectMeta, pod)
+	if err != nil {
+		return nil, err
+	}
+	rv, err := r.store.Update(ctx, podKey(pod.Namespace, pod.Name), data, expected)
+	if err != nil {
+		return nil, storageError(err, "pods", pod.Name)
+	}
+	pod.ResourceVersion = strconv.FormatUint(rv, 10)
+	return pod, nil
+}
+
+func (r *registry) deletePod(ctx context.Context, namespace, name string) (*apis.Pod, error) {
+	kv, err := r.store.Delete(ctx, podKey(namespace, name), 0)
+	if err != nil {
+		return nil, storageError(err, "pods", name)
+	}
+	pod := &apis.Pod{}
+	if err := decode(kv, &pod.ObjectMeta, pod); err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
@@ -0,0 +1,103 @@
+package apiserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"minik8s/logger"
+	"minik8s/pkg/apis"
+	"minik8s/pkg/storage"
+	"net/http"
+	"strings"
+	"time"
+)
+
+/*
+	apiserver，对外提供pod和namespace的增删改查
+	路径和k8s保持一致:
+		/api/v1/namespaces                          GET列出 POST创建
+		/api/v1/namespaces/{namespace}              GET PUT DELETE
+		/api/v1/namespaces/{namespace}/pods         GET列出 POST创建
+		/api/v1/namespaces/{namespace}/pods/{name}  GET PUT DELETE
+		/api/v1/pods                                GET列出所有namespace的pod
+	请求体可以是json或者yaml（由Content-Type决定），Accept是yaml的时候返回yaml，否则返回json
+	对象保存在storage中，可以是内存或者redis
+	出错的时候返回apis.Status，状态码和k8s一致：不存在404、已存在或者版本冲突409、内容不合法422
+*/
+
+var (
+	K8sLogger = logger.Named("apiserver")
+)
+
+const apiPrefix = "/api/v1/"
+
+type Server struct {
+	mux      *http.ServeMux
+	registry *registry
+}
+
+// 对象保存在store中，store中还没有default namespace的时候创建它
+func NewServer(ctx context.Context, store storage.Storage) (*Server, error) {
+	s := &Server{
+		mux:      http.NewServeMux(),
+		registry: newRegistry(store),
+	}
+	// 和k8s一样，default namespace总是存在
+	_, err := s.registry.createNamespace(ctx, &apis.Namespace{ObjectMeta: apis.ObjectMeta{Name: apis.NamespaceDefault}})
+	if err != nil && !IsAlreadyExists(err) {
+		return nil, fmt.Errorf("create default namespace: %w", err)
+	}
+	s.mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	s.mux.HandleFunc(apiPrefix, s.handleAPI)
+	// 修改日志级别，只接受本机的请求
+	s.mux.Handle("/debug/loglevel", logger.LevelHandler())
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// 监听addr直到ctx结束，结束的时候等待正在处理的请求完成
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	srv := &http.Server{Addr: addr, Handler: s}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	K8sLogger.Infoln("apiserver listening on ", addr)
+	err := srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// 按照路径的段数分发到各个资源的handler
+func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, apiPrefix), "/"), "/")
+	for _, p := range parts {
+		if p == "" {
+			writeError(w, r, NewBadRequest("invalid path %s", r.URL.Path))
+			return
+		}
+	}
+	switch {
+	case len(parts) == 1 && parts[0] == "pods":
+		s.handlePods(w, r, "")
+	case len(parts) == 1 && parts[0] == "namespaces":
+		s.handleNamespaces(w, r)
+	case len(parts) == 2 && parts[0] == "namespaces":
+		s.handleNamespace(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "namespaces" && parts[2] == "pods":
+		s.handlePods(w, r, parts[1])
+	case len(parts) == 4 && parts[0] == "namespaces" && parts[2] == "pods":
+		s.handlePod(w, r, parts[1], parts[3])
+	default:
+		writeError(w, r, newStatusError(http.StatusNotFound, apis.StatusReasonNotFound, "the server could not find the requested resource %s", r.URL.Path))
+	}
+}
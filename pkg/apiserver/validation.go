@@ -0,0 +1,81 @@
+package apiserver
+
+import (
+	"fmt"
+	"minik8s/minik8sTypes"
+	"minik8s/pkg/apis"
+	"regexp"
+)
+
+var (
+	dns1123LabelRegexp     = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	dns1123SubdomainRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+)
+
+const (
+	dns1123LabelMaxLength     = 63
+	dns1123SubdomainMaxLength = 253
+)
+
+// namespace和容器名必须是dns label，比如 kube-system
+func validateDNS1123Label(what, value string) error {
+	if len(value) > dns1123LabelMaxLength || !dns1123LabelRegexp.MatchString(value) {
+		return fmt.Errorf("%s %q must consist of at most %d lower case alphanumeric characters or '-', and must start and end with an alphanumeric character", what, value, dns1123LabelMaxLength)
+	}
+	return nil
+}
+
+// pod名可以带'.'，比如 web.v1
+func validateDNS1123Subdomain(what, value string) error {
+	if len(value) > dns1123SubdomainMaxLength || !dns1123SubdomainRegexp.MatchString(value) {
+		return fmt.Errorf("%s %q must consist of at most %d lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character", what, value, dns1123SubdomainMaxLength)
+	}
+	return nil
+}
+
+func validateNamespace(ns *apis.Namespace) error {
+	if ns.Kind != "" && ns.Kind != "Namespace" {
+		return fmt.Errorf("unsupported kind %s", ns.Kind)
+	}
+	if ns.Name == "" {
+		return fmt.Errorf("metadata.name is required")
+	}
+	return validateDNS1123Label("metadata.name", ns.Name)
+}
+
+func validatePod(pod *apis.Pod) error {
+	if pod.Kind != "" && pod.Kind != "Pod" {
+		return fmt.Errorf("unsupported kind %s", pod.Kind)
+	}
+	if pod.Name == "" {
+		return fmt.Errorf("metadata.name is required")
+	}
+	if err := validateDNS1123Subdomain("metadata.name", pod.Name); err != nil {
+		return err
+	}
+	if len(pod.Spec.Containers) == 0 {
+		return fmt.Errorf("spec.containers must not be empty")
+	}
+	names := map[string]bool{}
+	for _, c := range append(append([]apis.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...) {
+		if c.Name == "" {
+			return fmt.Errorf("container name is required")
+		}
+		if err := validateDNS1123Label("container name", c.Name); err != nil {
+			return err
+		}
+		if names[c.Name] {
+			return fmt.Errorf("duplicate container name %s", c.Name)
+		}
+		names[c.Name] = true
+		if c.Image == "" {
+			return fmt.Errorf("container %s: image is required", c.Name)
+		}
+	}
+	switch pod.Spec.RestartPolicy {
+	case "", minik8sTypes.Minik8sRestartPolicyAlways, minik8sTypes.Minik8sRestartPolicyOnFailure, minik8sTypes.Minik8sRestartPolicyNever:
+	default:
+		return fmt.Errorf("unsupported restartPolicy %q", pod.Spec.RestartPolicy)
+	}
+	return nil
+}
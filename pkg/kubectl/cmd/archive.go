@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+	cp命令用到的tar包处理，和docker cp的格式一致：tar包里的根目录名是源路径的最后一段
+*/
+
+// 把本地的src（文件或者目录）打成tar包写到w，tar包里的根目录名是rootName
+func tarPath(src string, rootName string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(filepath.Join(rootName, rel))
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// 把tar包解压到dstDir下，根目录名从oldRoot改成newRoot
+// 跳出dstDir的路径、指向dstDir外面的符号链接、经过符号链接写文件都会报错
+func untar(r io.Reader, dstDir string, oldRoot string, newRoot string) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(header.Name, "/")
+		if name == oldRoot {
+			name = newRoot
+		} else if strings.HasPrefix(name, oldRoot+"/") {
+			name = newRoot + strings.TrimPrefix(name, oldRoot)
+		}
+		target := filepath.Join(dstDir, filepath.FromSlash(name))
+		if !withinDir(dstDir, target) {
+			return fmt.Errorf("invalid path %q in archive", header.Name)
+		}
+		// 前面的条目可能已经把某一级目录换成了符号链接，不能顺着符号链接写到dstDir外面
+		if err := checkNoSymlinkParents(dstDir, target); err != nil {
+			return fmt.Errorf("invalid path %q in archive: %w", header.Name, err)
+		}
+		mode := os.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				return fmt.Errorf("invalid path %q in archive: %s is a symlink", header.Name, target)
+			}
+			if err := os.MkdirAll(target, mode|0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			// 已经存在的符号链接直接替换掉，不能写到链接指向的文件里
+			if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				if err := os.Remove(target); err != nil {
+					return err
+				}
+			}
+			f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if filepath.IsAbs(header.Linkname) || !withinDir(dstDir, filepath.Join(filepath.Dir(target), header.Linkname)) {
+				return fmt.Errorf("invalid symlink %q -> %q in archive", header.Name, header.Linkname)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			os.Remove(target)
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		default:
+			// 设备文件之类的不处理
+			continue
+		}
+	}
+}
+
+func withinDir(dir string, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// 检查dir和path之间的每一级目录都不是符号链接，不存在的目录之后会被创建成普通目录
+func checkNoSymlinkParents(dir string, path string) error {
+	rel, err := filepath.Rel(dir, filepath.Dir(path))
+	if err != nil || rel == "." {
+		return err
+	}
+	current := dir
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%s is a symlink", current)
+		}
+	}
+	return nil
+}
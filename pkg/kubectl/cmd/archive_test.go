@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTarRoundTrip(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "conf")
+	os.MkdirAll(filepath.Join(src, "sub"), 0755)
+	os.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(src, "sub", "b.txt"), []byte("b"), 0600)
+	os.Symlink("a.txt", filepath.Join(src, "link"))
+
+	var buf bytes.Buffer
+	if err := tarPath(src, "conf", &buf); err != nil {
+		t.Fatal(err)
+	}
+	// 拷贝成一个新的名字
+	dst := t.TempDir()
+	if err := untar(bytes.NewReader(buf.Bytes()), dst, "conf", "renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "renamed", "sub", "b.txt")); err != nil || string(data) != "b" {
+		t.Fatalf("expected sub/b.txt to be copied, got %q (%v)", data, err)
+	}
+	if info, err := os.Stat(filepath.Join(dst, "renamed", "sub", "b.txt")); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("expected mode to be preserved, got %v (%v)", info.Mode(), err)
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "renamed", "link")); err != nil || link != "a.txt" {
+		t.Fatalf("expected symlink to be copied, got %q (%v)", link, err)
+	}
+}
+
+func TestUntarRejectsEscapingPaths(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "../evil", Mode: 0644, Size: 1, Typeflag: tar.TypeReg})
+	tw.Write([]byte("x"))
+	tw.Close()
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	os.Mkdir(dst, 0755)
+	if err := untar(&buf, dst, "evil", "evil"); err == nil {
+		t.Fatal("expected error for path escaping the destination")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dst), "evil")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing written outside destination, got %v", err)
+	}
+}
+
+// 先放一个指向外面的符号链接，再经过这个链接写文件
+func TestUntarRejectsSymlinkEscape(t *testing.T) {
+	outside := t.TempDir()
+	for _, linkname := range []string{outside, "../../" + filepath.Base(outside)} {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		tw.WriteHeader(&tar.Header{Name: "f/", Mode: 0755, Typeflag: tar.TypeDir})
+		tw.WriteHeader(&tar.Header{Name: "f/link", Linkname: linkname, Typeflag: tar.TypeSymlink})
+		tw.WriteHeader(&tar.Header{Name: "f/link/pwned", Mode: 0644, Size: 1, Typeflag: tar.TypeReg})
+		tw.Write([]byte("x"))
+		tw.Close()
+
+		dst := t.TempDir()
+		if err := untar(&buf, dst, "f", "f"); err == nil {
+			t.Fatalf("expected error for symlink to %q", linkname)
+		}
+		if _, err := os.Stat(filepath.Join(outside, "pwned")); !os.IsNotExist(err) {
+			t.Fatalf("expected nothing written outside destination, got %v", err)
+		}
+	}
+}
+
+// 目标目录中已经存在的符号链接也不能被用来写到外面
+func TestUntarRefusesToWriteThroughExistingSymlink(t *testing.T) {
+	outside := t.TempDir()
+	dst := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dst, "f")); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "f/pwned", Mode: 0644, Size: 1, Typeflag: tar.TypeReg})
+	tw.Write([]byte("x"))
+	tw.Close()
+
+	if err := untar(&buf, dst, "f", "f"); err == nil {
+		t.Fatal("expected error for writing through a symlink")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "pwned")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing written outside destination, got %v", err)
+	}
+}
+
+func TestSplitRemotePath(t *testing.T) {
+	cases := []struct {
+		arg    string
+		pod    string
+		path   string
+		remote bool
+	}{
+		{"nginx:/etc/nginx", "nginx", "/etc/nginx", true},
+		{"/tmp/a:b", "", "/tmp/a:b", false},
+		{"./local", "", "./local", false},
+		{":/x", "", ":/x", false},
+	}
+	for _, c := range cases {
+		pod, p, remote := splitRemotePath(c.arg)
+		if pod != c.pod || p != c.path || remote != c.remote {
+			t.Fatalf("%s: got %q %q %v", c.arg, pod, p, remote)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+)
+
+/*
+	minik8s的命令行工具，目前直接连接节点上的kubelet
+	用法：kubectl <command> [flags] [args]
+*/
+
+const DefaultKubeletAddress = "127.0.0.1:10250"
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) (int, error)
+}
+
+var commands = []command{
+	{"exec", "exec [-n namespace] -c container [-i] [-t] pod -- command [args...]", runExec},
+	{"attach", "attach [-n namespace] -c container [-i] [-t] pod", runAttach},
+	{"cp", "cp [-n namespace] -c container pod:/src /local/dst | /local/src pod:/dst", runCopy},
+	{"port-forward", "port-forward [-n namespace] [-address 127.0.0.1] pod [local:]remote...", runPortForward},
+}
+
+// 执行一个子命令，返回进程的退出码
+func Run(args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		return 2
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			code, err := c.run(args[1:])
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				if code == 0 {
+					code = 1
+				}
+			}
+			return code
+		}
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+	printUsage(os.Stderr)
+	return 2
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage:")
+	for _, c := range commands {
+		fmt.Fprintln(w, "  kubectl", c.usage)
+	}
+}
+
+// 所有连接kubelet的子命令共用的参数
+type podFlags struct {
+	kubelet   string
+	namespace string
+	container string
+}
+
+func (f *podFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.kubelet, "kubelet", DefaultKubeletAddress, "address of the kubelet")
+	fs.StringVar(&f.namespace, "n", "default", "namespace of the pod")
+}
+
+// 需要指定容器的子命令再注册-c
+func (f *podFlags) registerContainer(fs *flag.FlagSet) {
+	fs.StringVar(&f.container, "c", "", "name of the container")
+}
+
+// 拼出kubelet接口的地址，比如 ws://127.0.0.1:10250/exec/default/nginx/web
+func (f *podFlags) url(scheme string, endpoint string, pod string, query url.Values) string {
+	u := url.URL{
+		Scheme:   scheme,
+		Host:     f.kubelet,
+		Path:     path.Join("/", endpoint, f.namespace, pod, f.container),
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
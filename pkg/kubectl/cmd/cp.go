@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"minik8s/pkg/kubelet/cp"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+)
+
+// kubectl cp [-n namespace] -c container pod:/src/path /local/path
+// kubectl cp [-n namespace] -c container /local/path pod:/dst/path
+// 和docker cp一样：目标是已经存在的目录的时候拷贝到目录下面，否则拷贝成目标路径
+func runCopy(args []string) (int, error) {
+	fs := flag.NewFlagSet("cp", flag.ContinueOnError)
+	var pf podFlags
+	pf.register(fs)
+	pf.registerContainer(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2, err
+	}
+	usage := fmt.Errorf("usage: kubectl cp [-n namespace] -c container pod:/src /local/dst | /local/src pod:/dst")
+	if fs.NArg() != 2 || pf.container == "" {
+		return 2, usage
+	}
+	srcPod, srcPath, srcRemote := splitRemotePath(fs.Arg(0))
+	dstPod, dstPath, dstRemote := splitRemotePath(fs.Arg(1))
+	switch {
+	case srcRemote && !dstRemote:
+		return 0, copyFromPod(&pf, srcPod, srcPath, dstPath)
+	case !srcRemote && dstRemote:
+		return 0, copyToPod(&pf, srcPath, dstPod, dstPath)
+	default:
+		return 2, usage
+	}
+}
+
+// "pod:/path" 是pod中的路径，其他的都是本地路径
+func splitRemotePath(arg string) (pod string, p string, remote bool) {
+	i := strings.Index(arg, ":")
+	if i <= 0 || strings.ContainsAny(arg[:i], `/\`) {
+		return "", arg, false
+	}
+	return arg[:i], arg[i+1:], true
+}
+
+func copyFromPod(pf *podFlags, pod, srcPath, dstPath string) error {
+	resp, err := http.Get(pf.url("http", "cp", pod, url.Values{"path": {srcPath}}))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	stat, err := cp.DecodePathStat(resp.Header.Get(cp.PathStatHeader))
+	if err != nil {
+		return fmt.Errorf("invalid path stat from kubelet: %w", err)
+	}
+	dstInfo, err := os.Stat(dstPath)
+	switch {
+	case err == nil && dstInfo.IsDir():
+		// 拷贝到已经存在的目录下面
+		return untar(resp.Body, dstPath, stat.Name, stat.Name)
+	case err == nil && stat.Mode.IsDir():
+		return fmt.Errorf("cannot copy directory %s to file %s", srcPath, dstPath)
+	case err != nil && !os.IsNotExist(err):
+		return err
+	}
+	// 目标不存在或者是文件，拷贝成目标路径
+	return untar(resp.Body, filepath.Dir(dstPath), stat.Name, filepath.Base(dstPath))
+}
+
+func copyToPod(pf *podFlags, srcPath, pod, dstPath string) error {
+	srcInfo, err := os.Stat(srcPath)
+	if err != nil {
+		return err
+	}
+	dstDir, rootName := dstPath, filepath.Base(srcPath)
+	dstStat, err := statPodPath(pf, pod, dstPath)
+	switch {
+	case err == nil && dstStat.Mode.IsDir():
+		// 拷贝到已经存在的目录下面
+	case err == nil && srcInfo.IsDir():
+		return fmt.Errorf("cannot copy directory %s to file %s", srcPath, dstPath)
+	case err == nil || err == errRemoteNotFound:
+		dstDir, rootName = path.Dir(dstPath), path.Base(dstPath)
+	default:
+		return err
+	}
+
+	body, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(tarPath(srcPath, rootName, writer))
+	}()
+	req, err := http.NewRequest(http.MethodPut, pf.url("http", "cp", pod, url.Values{"path": {dstDir}}), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-tar")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+var errRemoteNotFound = errors.New("path not found in container")
+
+func statPodPath(pf *podFlags, pod, p string) (types.ContainerPathStat, error) {
+	resp, err := http.Head(pf.url("http", "cp", pod, url.Values{"path": {p}}))
+	if err != nil {
+		return types.ContainerPathStat{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && resp.Header.Get(cp.PathStatHeader) == "" {
+		// HEAD的响应没有body，容器不存在和路径不存在分不开，交给后面的PUT报错
+		return types.ContainerPathStat{}, errRemoteNotFound
+	}
+	if err := checkResponse(resp); err != nil {
+		return types.ContainerPathStat{}, err
+	}
+	return cp.DecodePathStat(resp.Header.Get(cp.PathStatHeader))
+}
+
+// 非2xx的响应把body里的错误信息返回
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"minik8s/pkg/kubelet/streaming"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/moby/term"
+)
+
+// kubectl exec [-n namespace] -c container [-i] [-t] pod -- command [args...]
+func runExec(args []string) (int, error) {
+	fs := flag.NewFlagSet("exec", flag.ContinueOnError)
+	var pf podFlags
+	pf.register(fs)
+	pf.registerContainer(fs)
+	stdin := fs.Bool("i", false, "pass stdin to the command")
+	tty := fs.Bool("t", false, "allocate a tty for the command")
+	if err := fs.Parse(args); err != nil {
+		return 2, err
+	}
+	// flag包遇到第一个非flag参数就停止解析，pod之后的--要跳过
+	rest := fs.Args()
+	if len(rest) < 2 || pf.container == "" {
+		return 2, fmt.Errorf("usage: kubectl exec [-n namespace] -c container [-i] [-t] pod -- command [args...]")
+	}
+	pod, command := rest[0], rest[1:]
+	if command[0] == "--" {
+		command = command[1:]
+	}
+	if len(command) == 0 {
+		return 2, fmt.Errorf("command is required")
+	}
+	query := url.Values{
+		"command": command,
+		"stdin":   {strconv.FormatBool(*stdin)},
+		"tty":     {strconv.FormatBool(*tty)},
+	}
+	return stream(pf.url("ws", "exec", pod, query), *stdin, *tty)
+}
+
+// kubectl attach [-n namespace] -c container [-i] [-t] pod
+func runAttach(args []string) (int, error) {
+	fs := flag.NewFlagSet("attach", flag.ContinueOnError)
+	var pf podFlags
+	pf.register(fs)
+	pf.registerContainer(fs)
+	stdin := fs.Bool("i", false, "pass stdin to the container")
+	tty := fs.Bool("t", false, "stdin is a tty, the container must be created with a tty")
+	if err := fs.Parse(args); err != nil {
+		return 2, err
+	}
+	if fs.NArg() != 1 || pf.container == "" {
+		return 2, fmt.Errorf("usage: kubectl attach [-n namespace] -c container [-i] [-t] pod")
+	}
+	query := url.Values{"stdin": {strconv.FormatBool(*stdin)}}
+	return stream(pf.url("ws", "attach", fs.Arg(0), query), *stdin, *tty)
+}
+
+// 连接kubelet并且把本地终端和远端对接起来，tty模式下把本地终端切换到raw模式并且同步窗口大小
+func stream(url string, stdin bool, tty bool) (int, error) {
+	conn, err := streaming.Dial(context.Background(), url)
+	if err != nil {
+		return 1, err
+	}
+	defer conn.Close()
+
+	streams := streaming.Streams{Stdout: os.Stdout, Stderr: os.Stderr}
+	if stdin {
+		streams.Stdin = os.Stdin
+	}
+	fd, isTerminal := term.GetFdInfo(os.Stdin)
+	if tty && isTerminal {
+		state, err := term.SetRawTerminal(fd)
+		if err != nil {
+			return 1, err
+		}
+		defer term.RestoreTerminal(fd, state)
+		streams.Resize = watchTerminalSize(fd)
+	}
+	return conn.Stream(streams)
+}
+
+// 启动的时候发送一次终端大小，之后每次收到SIGWINCH再发送
+func watchTerminalSize(fd uintptr) <-chan streaming.Resize {
+	resize := make(chan streaming.Resize, 1)
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	send := func() {
+		size, err := term.GetWinsize(fd)
+		if err != nil {
+			return
+		}
+		select {
+		case <-resize:
+		default:
+		}
+		resize <- streaming.Resize{Width: uint(size.Width), Height: uint(size.Height)}
+	}
+	send()
+	go func() {
+		for range winch {
+			send()
+		}
+	}()
+	return resize
+}
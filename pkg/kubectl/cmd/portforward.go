@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"minik8s/pkg/kubelet/portforward"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+)
+
+// kubectl port-forward [-n namespace] [-address 127.0.0.1] pod [local:]remote...
+// 一直运行到ctrl-c或者和kubelet的连接断开
+func runPortForward(args []string) (int, error) {
+	fs := flag.NewFlagSet("port-forward", flag.ContinueOnError)
+	var pf podFlags
+	pf.register(fs)
+	address := fs.String("address", "127.0.0.1", "local address to listen on")
+	if err := fs.Parse(args); err != nil {
+		return 2, err
+	}
+	if fs.NArg() < 2 {
+		return 2, fmt.Errorf("usage: kubectl port-forward [-n namespace] [-address 127.0.0.1] pod [local:]remote...")
+	}
+	pod := fs.Arg(0)
+	var mappings []portforward.PortMapping
+	for _, arg := range fs.Args()[1:] {
+		mapping, err := portforward.ParsePortMapping(arg)
+		if err != nil {
+			return 2, err
+		}
+		mappings = append(mappings, mapping)
+	}
+
+	client, err := portforward.Dial(context.Background(), pf.url("ws", "portForward", pod, nil), os.Stderr)
+	if err != nil {
+		return 1, err
+	}
+	defer client.Close()
+	for _, mapping := range mappings {
+		listener, err := net.Listen("tcp", net.JoinHostPort(*address, strconv.Itoa(mapping.Local)))
+		if err != nil {
+			return 1, err
+		}
+		defer listener.Close()
+		fmt.Printf("Forwarding from %s -> %d\n", listener.Addr(), mapping.Remote)
+		go client.Serve(listener, mapping.Remote)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- client.Run() }()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-done:
+		if err != nil {
+			return 1, fmt.Errorf("lost connection to kubelet: %w", err)
+		}
+		return 0, nil
+	case <-interrupt:
+		return 0, nil
+	}
+}
@@ -0,0 +1,10 @@
+package main
+
+import (
+	"minik8s/pkg/kubectl/cmd"
+	"os"
+)
+
+func main() {
+	os.Exit(cmd.Run(os.Args[1:]))
+}
@@ -0,0 +1,169 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"minik8s/logger"
+	"minik8s/pkg/apis"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+	这个文件负责把pod的spec保存到磁盘上
+	kubelet重启之后，容器上的标签只能告诉我们容器属于哪个pod，
+	但是pod的完整spec只能从这里的检查点中恢复
+*/
+
+var (
+	K8sLogger = logger.Named("checkpoint")
+)
+
+const checkpointFileSuffix = ".json"
+
+// 检查点格式的版本
+// 0: 没有version字段的老格式，pod没有json标签，ObjectMeta和PodStatus的字段直接平铺在pod里面
+// 1: pod按照json标签编码，ObjectMeta在metadata下面
+const (
+	legacyCheckpointVersion = 0
+	checkpointVersion       = 1
+)
+
+type podCheckpoint struct {
+	Version int `json:"version,omitempty"`
+	// 保留原始的字节，校验和按照写入时的编码计算，和pod结构体后来的变化无关
+	Pod      json.RawMessage `json:"pod"`
+	Checksum uint32          `json:"checksum"` // pod序列化之后的crc32，用来发现损坏的检查点
+}
+
+type CheckpointManager struct {
+	dir string
+}
+
+func NewCheckpointManager(dir string) *CheckpointManager {
+	return &CheckpointManager{dir: dir}
+}
+
+func (cm *CheckpointManager) podFile(uid string) string {
+	return filepath.Join(cm.dir, uid+checkpointFileSuffix)
+}
+
+// 保存pod的检查点，先写临时文件再rename，防止写到一半kubelet崩溃
+func (cm *CheckpointManager) SavePod(pod *apis.Pod) error {
+	if pod.UID == "" {
+		return fmt.Errorf("pod %s/%s has no uid", pod.Namespace, pod.Name)
+	}
+	if err := os.MkdirAll(cm.dir, 0755); err != nil {
+		K8sLogger.Errorln("SavePod error: ", err)
+		return err
+	}
+	podData, err := json.Marshal(pod)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(podCheckpoint{Version: checkpointVersion, Pod: podData, Checksum: crc32.ChecksumIEEE(podData)})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(cm.dir, "."+pod.UID+"-*")
+	if err != nil {
+		K8sLogger.Errorln("SavePod error: ", err)
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), cm.podFile(pod.UID))
+}
+
+// 删除pod的检查点，不存在也不算错误
+func (cm *CheckpointManager) RemovePod(uid string) error {
+	err := os.Remove(cm.podFile(uid))
+	if err != nil && !os.IsNotExist(err) {
+		K8sLogger.Errorln("RemovePod error: ", err)
+		return err
+	}
+	return nil
+}
+
+// 读取所有的pod检查点，损坏的检查点会被跳过
+func (cm *CheckpointManager) LoadPods() ([]*apis.Pod, error) {
+	entries, err := os.ReadDir(cm.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		K8sLogger.Errorln("LoadPods error: ", err)
+		return nil, err
+	}
+	var pods []*apis.Pod
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, checkpointFileSuffix) {
+			continue
+		}
+		pod, err := cm.loadPodFile(filepath.Join(cm.dir, name))
+		if err != nil {
+			K8sLogger.Warnln("skip corrupted checkpoint ", name, ": ", err)
+			continue
+		}
+		pods = append(pods, pod)
+	}
+	return pods, nil
+}
+
+func (cm *CheckpointManager) loadPodFile(path string) (*apis.Pod, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cp podCheckpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+	if len(cp.Pod) == 0 || string(cp.Pod) == "null" {
+		return nil, fmt.Errorf("checkpoint has no pod")
+	}
+	if crc32.ChecksumIEEE(cp.Pod) != cp.Checksum {
+		return nil, fmt.Errorf("checksum mismatch")
+	}
+	pod := &apis.Pod{}
+	switch cp.Version {
+	case checkpointVersion:
+		if err := json.Unmarshal(cp.Pod, pod); err != nil {
+			return nil, err
+		}
+	case legacyCheckpointVersion:
+		if err := decodeLegacyPod(cp.Pod, pod); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported checkpoint version %d", cp.Version)
+	}
+	return pod, nil
+}
+
+// 老格式中ObjectMeta的字段平铺在最外层，spec和kind用的是go的字段名
+// json解析字段名时不区分大小写，所以spec可以直接解析；状态由kubelet重新计算，不需要恢复
+func decodeLegacyPod(data []byte, pod *apis.Pod) error {
+	var fields struct {
+		Spec apis.PodSpec
+		Kind string
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &pod.ObjectMeta); err != nil {
+		return err
+	}
+	pod.Spec = fields.Spec
+	pod.Kind = fields.Kind
+	return nil
+}
@@ -0,0 +1,110 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"hash/crc32"
+	"minik8s/minik8sTypes"
+	"minik8s/pkg/apis"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveAndLoadPods(t *testing.T) {
+	cm := NewCheckpointManager(filepath.Join(t.TempDir(), "checkpoints"))
+	pod := &apis.Pod{
+		ObjectMeta: apis.ObjectMeta{Name: "testPod", Namespace: "default", UID: "uid-1"},
+		Spec: apis.PodSpec{
+			Containers: []apis.Container{{Name: "c1", Image: "nginx"}},
+		},
+	}
+	if err := cm.SavePod(pod); err != nil {
+		t.Fatal(err)
+	}
+	pods, err := cm.LoadPods()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 1 || pods[0].UID != "uid-1" || pods[0].Spec.Containers[0].Image != "nginx" {
+		t.Fatalf("unexpected pods: %+v", pods)
+	}
+	if err := cm.RemovePod("uid-1"); err != nil {
+		t.Fatal(err)
+	}
+	pods, err = cm.LoadPods()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 0 {
+		t.Fatalf("expected no pods, got %d", len(pods))
+	}
+}
+
+func TestLoadPodsSkipsCorrupted(t *testing.T) {
+	dir := t.TempDir()
+	cm := NewCheckpointManager(dir)
+	if err := cm.SavePod(&apis.Pod{ObjectMeta: apis.ObjectMeta{Name: "ok", UID: "uid-ok"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "uid-bad.json"), []byte(`{"pod":{"Name":"bad"},"checksum":1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pods, err := cm.LoadPods()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 1 || pods[0].UID != "uid-ok" {
+		t.Fatalf("unexpected pods: %+v", pods)
+	}
+}
+
+// 加上json标签之前写入的检查点：ObjectMeta的字段平铺在pod里面，没有version字段
+func TestLoadLegacyCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	podData := []byte(`{"Kind":"Pod","Name":"web","UID":"uid-legacy","Namespace":"default","Labels":{"app":"web"},` +
+		`"Spec":{"Containers":[{"Name":"c1","Image":"nginx"}],"RestartPolicy":"Always"},"podIP":"10.0.0.2"}`)
+	data, err := json.Marshal(map[string]any{
+		"pod":      json.RawMessage(podData),
+		"checksum": crc32.ChecksumIEEE(podData),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "uid-legacy.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	pods, err := NewCheckpointManager(dir).LoadPods()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 1 {
+		t.Fatalf("expected 1 pod, got %d", len(pods))
+	}
+	pod := pods[0]
+	if pod.Name != "web" || pod.UID != "uid-legacy" || pod.Namespace != "default" || pod.Labels["app"] != "web" {
+		t.Fatalf("unexpected metadata: %+v", pod.ObjectMeta)
+	}
+	if len(pod.Spec.Containers) != 1 || pod.Spec.Containers[0].Image != "nginx" || pod.Spec.RestartPolicy != minik8sTypes.Minik8sRestartPolicyAlways {
+		t.Fatalf("unexpected spec: %+v", pod.Spec)
+	}
+}
+
+func TestLoadPodsSkipsUnknownVersion(t *testing.T) {
+	dir := t.TempDir()
+	podData := []byte(`{"metadata":{"name":"future","uid":"uid-future"}}`)
+	data, _ := json.Marshal(map[string]any{
+		"version":  checkpointVersion + 1,
+		"pod":      json.RawMessage(podData),
+		"checksum": crc32.ChecksumIEEE(podData),
+	})
+	if err := os.WriteFile(filepath.Join(dir, "uid-future.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	pods, err := NewCheckpointManager(dir).LoadPods()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 0 {
+		t.Fatalf("expected no pods, got %+v", pods)
+	}
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"minik8s/logger"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// kubelet的配置
+type KubeletConfig struct {
+	// kubelet http服务监听的地址和端口（日志、exec等接口）
+	// 这些接口没有认证，能连上的人都可以在任何pod里执行命令、转发端口、读写文件，所以默认只监听本机
+	Address string `json:"address" yaml:"address"`
+	Port    int    `json:"port" yaml:"port"`
+	// prometheus指标（/metrics）监听的端口，地址和Address一样，0表示不暴露指标
+	MetricsPort int `json:"metricsPort" yaml:"metricsPort"`
+	// docker daemon的地址，比如unix:///var/run/docker.sock，为空表示使用DOCKER_HOST环境变量或者docker的默认地址
+	DockerHost string `json:"dockerHost" yaml:"dockerHost"`
+	// 单次docker请求（创建、启动、删除、查询容器等）的超时时间
+	RuntimeRequestTimeout time.Duration `json:"runtimeRequestTimeout" yaml:"runtimeRequestTimeout"`
+	// 多久检查一次docker daemon是否可用，不可用的时候会更频繁地重试
+	RuntimeHealthCheckPeriod time.Duration `json:"runtimeHealthCheckPeriod" yaml:"runtimeHealthCheckPeriod"`
+	// 节点名字，静态pod的名字会加上这个后缀
+	NodeName string `json:"nodeName" yaml:"nodeName"`
+	// kubelet的根目录，检查点、日志等文件都放在这个目录下
+	RootDir string `json:"rootDir" yaml:"rootDir"`
+	// 静态pod清单文件所在的目录，为空表示不启用静态pod
+	StaticPodPath string `json:"staticPodPath" yaml:"staticPodPath"`
+	// 多久检查一次静态pod目录
+	FileCheckFrequency time.Duration `json:"fileCheckFrequency" yaml:"fileCheckFrequency"`
+	// 多久同步一次所有pod的状态
+	SyncFrequency time.Duration `json:"syncFrequency" yaml:"syncFrequency"`
+	// apiserver的地址，比如http://127.0.0.1:8080，为空表示没有apiserver
+	APIServerAddress string `json:"apiServerAddress" yaml:"apiServerAddress"`
+	// 最多同时拉取多少个镜像，<=0表示不限制
+	MaxParallelImagePulls int `json:"maxParallelImagePulls" yaml:"maxParallelImagePulls"`
+	// 单次拉取镜像的超时时间
+	ImagePullTimeout time.Duration `json:"imagePullTimeout" yaml:"imagePullTimeout"`
+	// 镜像所在磁盘的使用率超过高水位开始回收镜像，回收到低水位以下为止
+	ImageGCHighThresholdPercent int `json:"imageGCHighThresholdPercent" yaml:"imageGCHighThresholdPercent"`
+	ImageGCLowThresholdPercent  int `json:"imageGCLowThresholdPercent" yaml:"imageGCLowThresholdPercent"`
+	// 镜像至少存在这么久才会被回收
+	ImageMinimumGCAge time.Duration `json:"imageMinimumGCAge" yaml:"imageMinimumGCAge"`
+	// 多久检查一次是否需要回收镜像
+	ImageGCPeriod time.Duration `json:"imageGCPeriod" yaml:"imageGCPeriod"`
+	// 每个pod的每个容器最多保留多少个退出的容器
+	MaxPerPodContainerCount int `json:"maxPerPodContainerCount" yaml:"maxPerPodContainerCount"`
+	// 退出的容器至少存在这么久才会被回收
+	MinimumContainerGCAge time.Duration `json:"minimumContainerGCAge" yaml:"minimumContainerGCAge"`
+	// 不属于任何已知pod的容器存在这么久之后才会被回收
+	OrphanedContainerGracePeriod time.Duration `json:"orphanedContainerGracePeriod" yaml:"orphanedContainerGracePeriod"`
+	// 多久回收一次容器
+	ContainerGCPeriod time.Duration `json:"containerGCPeriod" yaml:"containerGCPeriod"`
+	// 离线镜像tar包所在的目录，kubelet启动时会加载里面所有的镜像，为空表示不预加载
+	ImagePreloadDir string `json:"imagePreloadDir" yaml:"imagePreloadDir"`
+	// 单个容器日志文件的最大字节数，超过之后轮转
+	ContainerLogMaxSize int64 `json:"containerLogMaxSize" yaml:"containerLogMaxSize"`
+	// 每个容器最多保留多少个日志文件（包括正在写的）
+	ContainerLogMaxFiles int `json:"containerLogMaxFiles" yaml:"containerLogMaxFiles"`
+	// 多久采集一次节点和容器的资源使用情况
+	StatsPeriod time.Duration `json:"statsPeriod" yaml:"statsPeriod"`
+	// pause容器的镜像和pod级别的配置
+	Sandbox SandboxConfig `json:"sandbox" yaml:"sandbox"`
+	// kubelet自己的日志
+	Logging logger.Config `json:"logging" yaml:"logging"`
+}
+
+const (
+	DefaultPort        = 10250
+	DefaultMetricsPort = 10255
+	DefaultRootDir     = "/var/lib/minik8s"
+	CheckpointDirName  = "checkpoints"
+	SecretsDirName     = "secrets"
+	PodLogsDirName     = "pods"
+)
+
+func DefaultKubeletConfig() *KubeletConfig {
+	nodeName, err := os.Hostname()
+	if err != nil {
+		nodeName = "localhost"
+	}
+	return &KubeletConfig{
+		Address:                      "127.0.0.1",
+		Port:                         DefaultPort,
+		MetricsPort:                  DefaultMetricsPort,
+		RuntimeRequestTimeout:        2 * time.Minute,
+		RuntimeHealthCheckPeriod:     10 * time.Second,
+		NodeName:                     nodeName,
+		RootDir:                      DefaultRootDir,
+		FileCheckFrequency:           20 * time.Second,
+		SyncFrequency:                10 * time.Second,
+		MaxParallelImagePulls:        2,
+		ImagePullTimeout:             5 * time.Minute,
+		ImageGCHighThresholdPercent:  85,
+		ImageGCLowThresholdPercent:   80,
+		ImageMinimumGCAge:            2 * time.Minute,
+		ImageGCPeriod:                5 * time.Minute,
+		MaxPerPodContainerCount:      1,
+		MinimumContainerGCAge:        time.Minute,
+		OrphanedContainerGracePeriod: time.Minute,
+		ContainerGCPeriod:            time.Minute,
+		ContainerLogMaxSize:          10 * 1024 * 1024,
+		ContainerLogMaxFiles:         5,
+		StatsPeriod:                  10 * time.Second,
+		Sandbox:                      DefaultSandboxConfig(),
+		Logging:                      logger.DefaultConfig(),
+	}
+}
+
+// pod检查点所在的目录 <root>/checkpoints
+func (c *KubeletConfig) CheckpointDir() string {
+	return filepath.Join(c.RootDir, CheckpointDirName)
+}
+
+// 镜像拉取secret所在的目录 <root>/secrets
+func (c *KubeletConfig) SecretsDir() string {
+	return filepath.Join(c.RootDir, SecretsDirName)
+}
+
+// pod日志所在的目录 <root>/pods，每个pod一个子目录
+func (c *KubeletConfig) PodLogsDir() string {
+	return filepath.Join(c.RootDir, PodLogsDirName)
+}
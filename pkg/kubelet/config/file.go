@@ -0,0 +1,151 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------
+// 这个文件处理kubelet的配置文件和命令行参数
+// 配置文件是带版本的yaml，时间用"10s"、"5m"这样的格式，没有写的字段保持默认值
+// 优先级：命令行参数 > 环境变量（只有日志的配置） > 配置文件 > 默认值
+// -----------------------------------------------------
+
+const (
+	ConfigAPIVersion = "kubelet.minik8s.io/v1alpha1"
+	ConfigKind       = "KubeletConfiguration"
+)
+
+// 配置文件的格式
+type KubeletConfigFile struct {
+	APIVersion    string `yaml:"apiVersion"`
+	Kind          string `yaml:"kind"`
+	KubeletConfig `yaml:",inline"`
+}
+
+// 读取配置文件，文件中不认识的字段会报错，避免拼错的字段被悄悄忽略
+func LoadConfigFile(path string) (*KubeletConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read kubelet config file: %w", err)
+	}
+	file := KubeletConfigFile{KubeletConfig: *DefaultKubeletConfig()}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("parse kubelet config file %s: %w", path, err)
+	}
+	if file.APIVersion != ConfigAPIVersion {
+		return nil, fmt.Errorf("kubelet config file %s: unsupported apiVersion %q, expected %q", path, file.APIVersion, ConfigAPIVersion)
+	}
+	if file.Kind != ConfigKind {
+		return nil, fmt.Errorf("kubelet config file %s: unsupported kind %q, expected %q", path, file.Kind, ConfigKind)
+	}
+	return &file.KubeletConfig, nil
+}
+
+// 注册命令行参数，默认值是c当前的值，解析之后直接写回c
+func (c *KubeletConfig) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Address, "address", c.Address, "address the kubelet server listens on")
+	fs.IntVar(&c.Port, "port", c.Port, "port of the kubelet server (logs, exec, attach, port-forward, cp, stats)")
+	fs.IntVar(&c.MetricsPort, "metrics-port", c.MetricsPort, "port of the prometheus metrics endpoint, 0 disables it")
+	fs.StringVar(&c.DockerHost, "docker-host", c.DockerHost, "docker daemon address, defaults to DOCKER_HOST or the local socket")
+	fs.DurationVar(&c.RuntimeRequestTimeout, "runtime-request-timeout", c.RuntimeRequestTimeout, "timeout of a single docker request, except long running ones like logs and exec")
+	fs.DurationVar(&c.RuntimeHealthCheckPeriod, "runtime-health-check-period", c.RuntimeHealthCheckPeriod, "how often to ping the docker daemon")
+	fs.StringVar(&c.NodeName, "node-name", c.NodeName, "name of this node, defaults to the hostname")
+	fs.StringVar(&c.RootDir, "root-dir", c.RootDir, "directory for checkpoints, secrets and pod logs")
+	fs.StringVar(&c.StaticPodPath, "pod-manifest-path", c.StaticPodPath, "directory of static pod manifests, empty disables static pods")
+	fs.DurationVar(&c.FileCheckFrequency, "file-check-frequency", c.FileCheckFrequency, "how often to check the static pod directory")
+	fs.DurationVar(&c.SyncFrequency, "sync-frequency", c.SyncFrequency, "how often to sync all pods")
+	fs.StringVar(&c.APIServerAddress, "api-server", c.APIServerAddress, "api server address, e.g. http://127.0.0.1:8080")
+	fs.StringVar(&c.Sandbox.Image, "pod-infra-container-image", c.Sandbox.Image, "pause image used for pod sandboxes")
+	fs.StringVar(&c.Sandbox.RegistryMirror, "pod-infra-registry-mirror", c.Sandbox.RegistryMirror, "registry mirror the pause image is pulled from")
+	fs.IntVar(&c.ImageGCHighThresholdPercent, "image-gc-high-threshold", c.ImageGCHighThresholdPercent, "disk usage percent above which image garbage collection runs")
+	fs.IntVar(&c.ImageGCLowThresholdPercent, "image-gc-low-threshold", c.ImageGCLowThresholdPercent, "disk usage percent image garbage collection frees down to")
+	fs.IntVar(&c.MaxPerPodContainerCount, "maximum-dead-containers-per-container", c.MaxPerPodContainerCount, "exited instances to keep per container")
+	fs.DurationVar(&c.MinimumContainerGCAge, "minimum-container-ttl-duration", c.MinimumContainerGCAge, "minimum age of an exited container before it is collected")
+	fs.StringVar(&c.ImagePreloadDir, "image-preload-dir", c.ImagePreloadDir, "directory of image tarballs to load at startup")
+	c.Logging.AddFlags(fs)
+}
+
+// 检查配置，所有的错误一起返回
+func (c *KubeletConfig) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	check(c.Port > 0 && c.Port < 65536, "port must be between 1 and 65535, got %d", c.Port)
+	check(c.MetricsPort >= 0 && c.MetricsPort < 65536, "metricsPort must be between 0 and 65535, got %d", c.MetricsPort)
+	check(c.MetricsPort == 0 || c.MetricsPort != c.Port, "metricsPort must differ from port %d", c.Port)
+	check(c.RuntimeRequestTimeout > 0, "runtimeRequestTimeout must be positive, got %v", c.RuntimeRequestTimeout)
+	check(c.RuntimeHealthCheckPeriod > 0, "runtimeHealthCheckPeriod must be positive, got %v", c.RuntimeHealthCheckPeriod)
+	check(c.NodeName != "", "nodeName must not be empty")
+	check(filepath.IsAbs(c.RootDir), "rootDir must be an absolute path, got %q", c.RootDir)
+	check(c.FileCheckFrequency > 0, "fileCheckFrequency must be positive, got %v", c.FileCheckFrequency)
+	check(c.SyncFrequency > 0, "syncFrequency must be positive, got %v", c.SyncFrequency)
+	check(c.ImagePullTimeout > 0, "imagePullTimeout must be positive, got %v", c.ImagePullTimeout)
+	check(c.ImageGCHighThresholdPercent >= 0 && c.ImageGCHighThresholdPercent <= 100,
+		"imageGCHighThresholdPercent must be between 0 and 100, got %d", c.ImageGCHighThresholdPercent)
+	check(c.ImageGCLowThresholdPercent >= 0 && c.ImageGCLowThresholdPercent <= c.ImageGCHighThresholdPercent,
+		"imageGCLowThresholdPercent must be between 0 and imageGCHighThresholdPercent (%d), got %d", c.ImageGCHighThresholdPercent, c.ImageGCLowThresholdPercent)
+	check(c.ImageGCPeriod > 0, "imageGCPeriod must be positive, got %v", c.ImageGCPeriod)
+	check(c.ImageMinimumGCAge >= 0, "imageMinimumGCAge must not be negative, got %v", c.ImageMinimumGCAge)
+	check(c.MaxPerPodContainerCount >= 0, "maxPerPodContainerCount must not be negative, got %d", c.MaxPerPodContainerCount)
+	check(c.MinimumContainerGCAge >= 0, "minimumContainerGCAge must not be negative, got %v", c.MinimumContainerGCAge)
+	check(c.OrphanedContainerGracePeriod >= 0, "orphanedContainerGracePeriod must not be negative, got %v", c.OrphanedContainerGracePeriod)
+	check(c.ContainerGCPeriod > 0, "containerGCPeriod must be positive, got %v", c.ContainerGCPeriod)
+	check(c.ContainerLogMaxSize > 0, "containerLogMaxSize must be positive, got %d", c.ContainerLogMaxSize)
+	check(c.ContainerLogMaxFiles >= 1, "containerLogMaxFiles must be at least 1, got %d", c.ContainerLogMaxFiles)
+	check(c.StatsPeriod > 0, "statsPeriod must be positive, got %v", c.StatsPeriod)
+	if err := c.Sandbox.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Logging.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("logging: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// 解析命令行参数得到最终的配置
+// 先读配置文件（没有指定-config的时候用默认配置），再用环境变量覆盖日志配置，最后把命令行上显式设置的参数覆盖上去
+func Load(fs *flag.FlagSet, args []string) (*KubeletConfig, error) {
+	configPath := fs.String("config", "", "path to the kubelet configuration file")
+	DefaultKubeletConfig().AddFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	config := DefaultKubeletConfig()
+	if *configPath != "" {
+		var err error
+		if config, err = LoadConfigFile(*configPath); err != nil {
+			return nil, err
+		}
+	}
+	config.Logging.ApplyEnv()
+	overrides := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	config.AddFlags(overrides)
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		if err == nil && overrides.Lookup(f.Name) != nil {
+			err = overrides.Set(f.Name, f.Value.String())
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid kubelet configuration:\n%w", err)
+	}
+	return config, nil
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "kubelet.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	path := writeConfigFile(t, `apiVersion: kubelet.minik8s.io/v1alpha1
+kind: KubeletConfiguration
+nodeName: node1
+dockerHost: tcp://127.0.0.1:2375
+rootDir: /tmp/minik8s
+syncFrequency: 30s
+imageGCHighThresholdPercent: 90
+sandbox:
+  image: registry.k8s.io/pause:3.9
+  registryMirror: registry.aliyuncs.com/google_containers
+logging:
+  level: debug
+`)
+	cfg, err := LoadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.NodeName != "node1" || cfg.DockerHost != "tcp://127.0.0.1:2375" || cfg.RootDir != "/tmp/minik8s" {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+	if cfg.SyncFrequency != 30*time.Second || cfg.ImageGCHighThresholdPercent != 90 {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+	if cfg.Sandbox.PauseImage() != "registry.aliyuncs.com/google_containers/pause:3.9" || cfg.Logging.Level != "debug" {
+		t.Fatalf("unexpected sandbox or logging config %+v %+v", cfg.Sandbox, cfg.Logging)
+	}
+	// 没有写的字段保持默认值
+	if cfg.Port != DefaultPort || cfg.ImageGCLowThresholdPercent != 80 || !cfg.Logging.Stderr {
+		t.Fatalf("defaults not kept %+v", cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	cases := map[string]string{
+		"unsupported apiVersion":                            "apiVersion: v0\nkind: KubeletConfiguration\n",
+		"unsupported kind":                                  "apiVersion: kubelet.minik8s.io/v1alpha1\nkind: Pod\n",
+		"field nodeNmae not found":                          "apiVersion: kubelet.minik8s.io/v1alpha1\nkind: KubeletConfiguration\nnodeNmae: node1\n",
+		"cannot unmarshal !!str `often` into time.Duration": "apiVersion: kubelet.minik8s.io/v1alpha1\nkind: KubeletConfiguration\nsyncFrequency: often\n",
+	}
+	for want, content := range cases {
+		_, err := LoadConfigFile(writeConfigFile(t, content))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error containing %q, got %v", want, err)
+		}
+	}
+}
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	cfg := DefaultKubeletConfig()
+	cfg.Port = 0
+	cfg.RootDir = "relative"
+	cfg.ImageGCLowThresholdPercent = 95
+	cfg.Logging.Format = "xml"
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, want := range []string{"port must be", "rootDir must be an absolute path", "imageGCLowThresholdPercent", "logging: unknown log format"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestLoadFlagsOverrideFile(t *testing.T) {
+	path := writeConfigFile(t, `apiVersion: kubelet.minik8s.io/v1alpha1
+kind: KubeletConfiguration
+nodeName: from-file
+port: 10260
+logging:
+  level: debug
+`)
+	t.Setenv("MINIK8S_LOG_LEVEL", "warn")
+	fs := flag.NewFlagSet("kubelet", flag.ContinueOnError)
+	cfg, err := Load(fs, []string{"-config", path, "-node-name", "from-flag", "-sync-frequency", "1m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.NodeName != "from-flag" || cfg.Port != 10260 || cfg.SyncFrequency != time.Minute {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+	// 环境变量覆盖配置文件
+	if cfg.Logging.Level != "warn" {
+		t.Fatalf("unexpected log level %q", cfg.Logging.Level)
+	}
+
+	fs = flag.NewFlagSet("kubelet", flag.ContinueOnError)
+	fs.SetOutput(new(strings.Builder))
+	if _, err := Load(fs, []string{"-port", "70000"}); err == nil || !strings.Contains(err.Error(), "port must be") {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"minik8s/minik8sTypes"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+)
+
+// pause容器（沙箱）的配置
+type SandboxConfig struct {
+	// pause镜像
+	Image string `json:"image" yaml:"image"`
+	// pause镜像的拉取策略
+	ImagePullPolicy minik8sTypes.ImagePullPolicyType `json:"imagePullPolicy" yaml:"imagePullPolicy"`
+	// 镜像仓库的镜像地址，比如registry.aliyuncs.com/google_containers，
+	// 设置之后pause镜像会从这里拉取：registry.k8s.io/pause:3.9 -> registry.aliyuncs.com/google_containers/pause:3.9
+	RegistryMirror string `json:"registryMirror" yaml:"registryMirror"`
+	// pod级别的内核参数，比如net.ipv4.ip_forward
+	Sysctls map[string]string `json:"sysctls" yaml:"sysctls"`
+	// dns服务器、搜索域和选项
+	DNS        []string `json:"dns" yaml:"dns"`
+	DNSSearch  []string `json:"dnsSearch" yaml:"dnsSearch"`
+	DNSOptions []string `json:"dnsOptions" yaml:"dnsOptions"`
+	// pod中所有容器的cgroup父目录
+	CgroupParent string `json:"cgroupParent" yaml:"cgroupParent"`
+	// /dev/shm的大小，单位是字节，0表示使用docker的默认值
+	ShmSize int64 `json:"shmSize" yaml:"shmSize"`
+}
+
+func DefaultSandboxConfig() SandboxConfig {
+	return SandboxConfig{
+		Image:           minik8sTypes.Minik8sPauseImage,
+		ImagePullPolicy: minik8sTypes.IfNotPresent,
+	}
+}
+
+// 真正要使用的pause镜像，配置了仓库镜像地址的话会替换掉原来的仓库
+func (s *SandboxConfig) PauseImage() string {
+	if s.RegistryMirror == "" {
+		return s.Image
+	}
+	named, err := reference.ParseNormalizedNamed(s.Image)
+	if err != nil {
+		return s.Image
+	}
+	image := strings.TrimSuffix(s.RegistryMirror, "/") + "/" + lastPathComponent(reference.Path(named))
+	if tagged, ok := named.(reference.Tagged); ok {
+		image += ":" + tagged.Tag()
+	}
+	if digested, ok := named.(reference.Digested); ok {
+		image += "@" + digested.Digest().String()
+	}
+	return image
+}
+
+func lastPathComponent(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+func (s *SandboxConfig) Validate() error {
+	if s.Image == "" {
+		return fmt.Errorf("sandbox image must not be empty")
+	}
+	if _, err := reference.ParseNormalizedNamed(s.PauseImage()); err != nil {
+		return fmt.Errorf("invalid sandbox image %q: %v", s.PauseImage(), err)
+	}
+	switch s.ImagePullPolicy {
+	case minik8sTypes.Always, minik8sTypes.IfNotPresent, minik8sTypes.Never:
+	default:
+		return fmt.Errorf("invalid sandbox image pull policy %q, must be one of Always, IfNotPresent, Never", s.ImagePullPolicy)
+	}
+	for key := range s.Sysctls {
+		if key == "" || strings.ContainsAny(key, " =") {
+			return fmt.Errorf("invalid sandbox sysctl %q", key)
+		}
+	}
+	if s.ShmSize < 0 {
+		return fmt.Errorf("sandbox shm size must not be negative, got %d", s.ShmSize)
+	}
+	return nil
+}
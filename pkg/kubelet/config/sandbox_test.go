@@ -0,0 +1,44 @@
+package config
+
+import (
+	"minik8s/minik8sTypes"
+	"testing"
+)
+
+func TestPauseImageWithMirror(t *testing.T) {
+	cases := []struct {
+		image, mirror, want string
+	}{
+		{"registry.k8s.io/pause:3.9", "", "registry.k8s.io/pause:3.9"},
+		{"registry.k8s.io/pause:3.9", "registry.aliyuncs.com/google_containers", "registry.aliyuncs.com/google_containers/pause:3.9"},
+		{"k8s.gcr.io/pause:3.1", "mirror.local:5000/", "mirror.local:5000/pause:3.1"},
+	}
+	for _, c := range cases {
+		s := SandboxConfig{Image: c.image, RegistryMirror: c.mirror}
+		if got := s.PauseImage(); got != c.want {
+			t.Errorf("PauseImage(%q, %q) = %q, want %q", c.image, c.mirror, got, c.want)
+		}
+	}
+}
+
+func TestValidateSandboxConfig(t *testing.T) {
+	s := DefaultSandboxConfig()
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	s.ImagePullPolicy = "Sometimes"
+	if err := s.Validate(); err == nil {
+		t.Fatal("expected invalid pull policy error")
+	}
+	s = DefaultSandboxConfig()
+	s.ShmSize = -1
+	if err := s.Validate(); err == nil {
+		t.Fatal("expected invalid shm size error")
+	}
+	s = DefaultSandboxConfig()
+	s.ImagePullPolicy = minik8sTypes.Never
+	s.Image = "Not A Valid Image"
+	if err := s.Validate(); err == nil {
+		t.Fatal("expected invalid image error")
+	}
+}
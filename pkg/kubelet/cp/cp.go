@@ -0,0 +1,110 @@
+package cp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"minik8s/minik8sTypes"
+	containermanager "minik8s/pkg/kubelet/runtime/containerManager"
+	runtimeerrors "minik8s/pkg/kubelet/runtime/runtimeErrors"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+)
+
+/*
+	在本地和pod的容器之间拷贝文件，内容都是tar流
+	按照pod的uid和容器名找到容器正在运行的当前实例
+*/
+
+var (
+	ErrContainerNotFound = errors.New("container not found")
+	ErrPathNotFound      = errors.New("path not found in container")
+)
+
+type Copier struct {
+	cm *containermanager.ContainerManager
+}
+
+func NewCopier(cm *containermanager.ContainerManager) *Copier {
+	return &Copier{cm: cm}
+}
+
+// 获取容器中一个路径的信息
+func (c *Copier) Stat(ctx context.Context, podUID, containerName, path string) (types.ContainerPathStat, error) {
+	containerID, err := c.findContainer(ctx, podUID, containerName)
+	if err != nil {
+		return types.ContainerPathStat{}, err
+	}
+	stat, err := c.cm.StatContainerPath(ctx, containerID, path)
+	return stat, convertError(err, path)
+}
+
+// 把容器中的路径打成tar包读出来，tar包里的根目录名是路径的最后一段
+func (c *Copier) CopyFrom(ctx context.Context, podUID, containerName, path string) (io.ReadCloser, types.ContainerPathStat, error) {
+	containerID, err := c.findContainer(ctx, podUID, containerName)
+	if err != nil {
+		return nil, types.ContainerPathStat{}, err
+	}
+	rc, stat, err := c.cm.CopyFromContainer(ctx, containerID, path)
+	return rc, stat, convertError(err, path)
+}
+
+// 把tar包解压到容器中已经存在的目录dir下
+func (c *Copier) CopyTo(ctx context.Context, podUID, containerName, dir string, content io.Reader) error {
+	containerID, err := c.findContainer(ctx, podUID, containerName)
+	if err != nil {
+		return err
+	}
+	return convertError(c.cm.CopyToContainer(ctx, containerID, dir, content), dir)
+}
+
+func convertError(err error, path string) error {
+	if err != nil && errors.Is(err, runtimeerrors.ErrNotFound) {
+		return fmt.Errorf("%w: %s", ErrPathNotFound, path)
+	}
+	return err
+}
+
+// 找到容器正在运行的当前实例，已经退出的旧实例不会被找到
+// 按uid查找，同名的旧pod还没被回收的容器不会被找到；同一秒创建的实例按照attempt区分新旧
+func (c *Copier) findContainer(ctx context.Context, podUID, containerName string) (string, error) {
+	filter := filters.NewArgs()
+	filter.Add("label", minik8sTypes.KubernetesPodUIDLabel+"="+podUID)
+	filter.Add("label", minik8sTypes.Minik8sPodTypeLabel+"="+minik8sTypes.Minik8sGenericPodType)
+	filter.Add("label", minik8sTypes.LabelsContainerName+"="+containerName)
+	filter.Add("status", "running")
+	res, err := c.cm.ListContainerWithOpts(ctx, types.ContainerListOptions{Filters: filter})
+	if err != nil {
+		return "", err
+	}
+	if len(res) == 0 {
+		return "", fmt.Errorf("%w: %s in pod %s", ErrContainerNotFound, containerName, podUID)
+	}
+	containermanager.SortByAttempt(res)
+	return res[0].ID, nil
+}
+
+// http接口中用这个头返回路径的信息，内容是base64编码的json
+const PathStatHeader = "X-Minik8s-Container-Path-Stat"
+
+func EncodePathStat(stat types.ContainerPathStat) (string, error) {
+	data, err := json.Marshal(stat)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+func DecodePathStat(value string) (types.ContainerPathStat, error) {
+	var stat types.ContainerPathStat
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return stat, err
+	}
+	err = json.Unmarshal(data, &stat)
+	return stat, err
+}
@@ -0,0 +1,132 @@
+package cp
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"minik8s/minik8sTypes"
+	"minik8s/pkg/kubelet/dockerClient/dockertest"
+	containermanager "minik8s/pkg/kubelet/runtime/containerManager"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+// 模拟docker daemon：容器有两个实例，只有/etc/app.conf这一个文件，记录拷贝进去的内容
+type fakeCopyDaemon struct {
+	archiveIDs []string // 每次访问archive接口用的容器id
+	putPath    string
+	putBody    []byte
+}
+
+func (d *fakeCopyDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/containers/json"):
+		// 只找pod1正在运行的app容器
+		filters := r.URL.Query().Get("filters")
+		if !strings.Contains(filters, "containerName=app") || !strings.Contains(filters, minik8sTypes.KubernetesPodUIDLabel+"=pod1") || !strings.Contains(filters, "running") {
+			w.Write([]byte("[]"))
+			return
+		}
+		// 两个实例在同一秒创建，只能按照attempt区分新旧
+		json.NewEncoder(w).Encode([]types.Container{
+			{ID: "old", Created: 1, Labels: map[string]string{minik8sTypes.KubernetesContainerAttemptLabel: "0"}},
+			{ID: "new", Created: 1, Labels: map[string]string{minik8sTypes.KubernetesContainerAttemptLabel: "1"}},
+		})
+	case strings.HasSuffix(r.URL.Path, "/archive"):
+		id := strings.Split(strings.TrimPrefix(r.URL.Path, "/v"+dockertest.APIVersion+"/containers/"), "/")[0]
+		d.archiveIDs = append(d.archiveIDs, id)
+		path := r.URL.Query().Get("path")
+		if r.Method == http.MethodPut {
+			d.putPath = path
+			d.putBody, _ = io.ReadAll(r.Body)
+			return
+		}
+		if path != "/etc/app.conf" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Could not find the file ` + path + ` in container"}`))
+			return
+		}
+		stat, _ := json.Marshal(types.ContainerPathStat{Name: "app.conf", Size: 5, Mode: 0644})
+		w.Header().Set("X-Docker-Container-Path-Stat", base64.StdEncoding.EncodeToString(stat))
+		if r.Method == http.MethodHead {
+			return
+		}
+		tw := tar.NewWriter(w)
+		tw.WriteHeader(&tar.Header{Name: "app.conf", Mode: 0644, Size: 5, Typeflag: tar.TypeReg})
+		tw.Write([]byte("hello"))
+		tw.Close()
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestCopier(t *testing.T, daemon http.Handler) *Copier {
+	return NewCopier(containermanager.NewContainerManager(dockertest.NewClient(t, daemon)))
+}
+
+func TestCopyFromNewestInstance(t *testing.T) {
+	daemon := &fakeCopyDaemon{}
+	c := newTestCopier(t, daemon)
+	rc, stat, err := c.CopyFrom(context.Background(), "pod1", "app", "/etc/app.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if stat.Name != "app.conf" || stat.Size != 5 {
+		t.Fatalf("unexpected stat %+v", stat)
+	}
+	tr := tar.NewReader(rc)
+	if _, err := tr.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(tr); string(data) != "hello" {
+		t.Fatalf("unexpected content %q", data)
+	}
+	if len(daemon.archiveIDs) != 1 || daemon.archiveIDs[0] != "new" {
+		t.Fatalf("expected the newest instance to be used, got %v", daemon.archiveIDs)
+	}
+}
+
+func TestCopyTo(t *testing.T) {
+	daemon := &fakeCopyDaemon{}
+	c := newTestCopier(t, daemon)
+	if err := c.CopyTo(context.Background(), "pod1", "app", "/tmp", bytes.NewReader([]byte("tar content"))); err != nil {
+		t.Fatal(err)
+	}
+	if daemon.putPath != "/tmp" || string(daemon.putBody) != "tar content" {
+		t.Fatalf("unexpected copy to %q: %q", daemon.putPath, daemon.putBody)
+	}
+}
+
+func TestCopyErrors(t *testing.T) {
+	c := newTestCopier(t, &fakeCopyDaemon{})
+	if _, err := c.Stat(context.Background(), "pod1", "app", "/missing"); !errors.Is(err, ErrPathNotFound) {
+		t.Fatalf("expected ErrPathNotFound, got %v", err)
+	}
+	if _, err := c.Stat(context.Background(), "pod1", "other", "/etc/app.conf"); !errors.Is(err, ErrContainerNotFound) {
+		t.Fatalf("expected ErrContainerNotFound, got %v", err)
+	}
+}
+
+func TestPathStatRoundTrip(t *testing.T) {
+	stat := types.ContainerPathStat{Name: "etc", Size: 4096, Mode: 0755 | 1<<31, LinkTarget: "/etc"}
+	value, err := EncodePathStat(stat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodePathStat(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != stat.Name || got.Size != stat.Size || got.Mode != stat.Mode || got.LinkTarget != stat.LinkTarget {
+		t.Fatalf("expected %+v, got %+v", stat, got)
+	}
+}
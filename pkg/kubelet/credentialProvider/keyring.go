@@ -0,0 +1,159 @@
+package credentialprovider
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types/registry"
+)
+
+/*
+	私有仓库的认证信息
+	格式和docker的~/.docker/config.json一样（也就是k8s中kubernetes.io/dockerconfigjson类型的secret）：
+	{"auths": {"registry.example.com": {"username": "u", "password": "p"}}}
+	keyring根据镜像所在的仓库地址找到对应的认证信息
+*/
+
+const (
+	// docker hub在docker配置文件中的各种写法
+	defaultRegistryHost = "docker.io"
+	dockerHubIndexHost  = "index.docker.io"
+	dockerHubIndexURL   = "https://index.docker.io/v1/"
+)
+
+type DockerConfigJSON struct {
+	Auths map[string]DockerConfigEntry `json:"auths"`
+}
+
+type DockerConfigEntry struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	Auth          string `json:"auth,omitempty"` // base64(username:password)
+	Email         string `json:"email,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+}
+
+// 解析docker配置文件的内容，同时兼容没有外层auths的旧格式(.dockercfg)
+func ParseDockerConfigJSON(data []byte) (DockerConfigJSON, error) {
+	config := DockerConfigJSON{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return DockerConfigJSON{}, err
+	}
+	if config.Auths == nil {
+		legacy := map[string]DockerConfigEntry{}
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return DockerConfigJSON{}, err
+		}
+		config.Auths = legacy
+	}
+	for key, entry := range config.Auths {
+		if entry.Auth != "" && entry.Username == "" && entry.Password == "" {
+			username, password, err := decodeAuth(entry.Auth)
+			if err != nil {
+				return DockerConfigJSON{}, fmt.Errorf("invalid auth for %s: %w", key, err)
+			}
+			entry.Username = username
+			entry.Password = password
+			config.Auths[key] = entry
+		}
+	}
+	return config, nil
+}
+
+func decodeAuth(auth string) (string, string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(auth)
+	if err != nil {
+		return "", "", err
+	}
+	username, password, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return "", "", fmt.Errorf("auth is not in username:password form")
+	}
+	return username, password, nil
+}
+
+type keyringEntry struct {
+	host   string // 仓库的host，带端口
+	path   string // 仓库地址中host后面的路径前缀，一般为空
+	config registry.AuthConfig
+}
+
+type Keyring struct {
+	entries []keyringEntry
+}
+
+func NewKeyring() *Keyring {
+	return &Keyring{}
+}
+
+// 把一个docker配置中的所有认证信息加入keyring
+func (k *Keyring) Add(config DockerConfigJSON) {
+	for key, entry := range config.Auths {
+		host, path := parseRegistryKey(key)
+		k.entries = append(k.entries, keyringEntry{
+			host: host,
+			path: path,
+			config: registry.AuthConfig{
+				Username:      entry.Username,
+				Password:      entry.Password,
+				Email:         entry.Email,
+				IdentityToken: entry.IdentityToken,
+				ServerAddress: key,
+			},
+		})
+	}
+	// 路径越长的匹配越精确，放在前面
+	sort.SliceStable(k.entries, func(i, j int) bool {
+		return len(k.entries[i].path) > len(k.entries[j].path)
+	})
+}
+
+// 找到镜像对应的所有认证信息，越精确的匹配越靠前
+func (k *Keyring) Lookup(image string) ([]registry.AuthConfig, bool) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return nil, false
+	}
+	host := normalizeHost(reference.Domain(named))
+	repoPath := reference.Path(named)
+	var configs []registry.AuthConfig
+	for _, entry := range k.entries {
+		if entry.host != host {
+			continue
+		}
+		if entry.path != "" && repoPath != entry.path && !strings.HasPrefix(repoPath, entry.path+"/") {
+			continue
+		}
+		configs = append(configs, entry.config)
+	}
+	return configs, len(configs) > 0
+}
+
+// 配置文件中的key可能是 registry.example.com、registry.example.com:5000/team、https://registry.example.com/v1/ 等形式
+func parseRegistryKey(key string) (host string, path string) {
+	if strings.Contains(key, "://") {
+		if u, err := url.Parse(key); err == nil {
+			key = u.Host + u.Path
+		}
+	}
+	key = strings.TrimSuffix(key, "/")
+	host, path, _ = strings.Cut(key, "/")
+	// https://index.docker.io/v1/ 这种地址中的/v1是api版本，不是路径
+	if path == "v1" || path == "v2" {
+		path = ""
+	}
+	return normalizeHost(host), path
+}
+
+func normalizeHost(host string) string {
+	host = strings.ToLower(host)
+	if host == dockerHubIndexHost || host == "registry-1.docker.io" {
+		return defaultRegistryHost
+	}
+	return host
+}
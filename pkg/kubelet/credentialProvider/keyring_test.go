@@ -0,0 +1,48 @@
+package credentialprovider
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+)
+
+const testDockerConfig = `{
+	"auths": {
+		"registry.example.com:5000": {"auth": "%s"},
+		"registry.example.com:5000/team": {"username": "team", "password": "teampass"},
+		"https://index.docker.io/v1/": {"username": "hub", "password": "hubpass"}
+	}
+}`
+
+func TestKeyringLookup(t *testing.T) {
+	auth := base64.StdEncoding.EncodeToString([]byte("user:pass"))
+	config, err := ParseDockerConfigJSON([]byte(fmt.Sprintf(testDockerConfig, auth)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring := NewKeyring()
+	keyring.Add(config)
+
+	auths, ok := keyring.Lookup("registry.example.com:5000/team/app:v1")
+	if !ok || len(auths) != 2 {
+		t.Fatalf("expected 2 credentials, got %+v", auths)
+	}
+	// 更精确的路径匹配排在前面
+	if auths[0].Username != "team" || auths[1].Username != "user" || auths[1].Password != "pass" {
+		t.Fatalf("unexpected credential order: %+v", auths)
+	}
+
+	auths, ok = keyring.Lookup("registry.example.com:5000/other/app")
+	if !ok || len(auths) != 1 || auths[0].Username != "user" {
+		t.Fatalf("unexpected credentials: %+v", auths)
+	}
+
+	auths, ok = keyring.Lookup("nginx:latest")
+	if !ok || auths[0].Username != "hub" {
+		t.Fatalf("expected docker hub credentials, got %+v", auths)
+	}
+
+	if _, ok := keyring.Lookup("quay.io/coreos/etcd"); ok {
+		t.Fatal("unexpected credentials for quay.io")
+	}
+}
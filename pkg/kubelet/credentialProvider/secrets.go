@@ -0,0 +1,56 @@
+package credentialprovider
+
+import (
+	"fmt"
+	"minik8s/logger"
+	"minik8s/pkg/apis"
+	"os"
+	"path/filepath"
+)
+
+var (
+	K8sLogger = logger.Named("credentials")
+)
+
+// 根据名字获取imagePullSecrets引用的docker配置
+type SecretGetter interface {
+	GetDockerConfig(namespace string, name string) (DockerConfigJSON, error)
+}
+
+// 现在还没有apiserver中的secret，secret以文件的形式放在kubelet的目录下：
+// <secretsDir>/<namespace>/<name>.json，文件内容就是docker的config.json
+type fileSecretGetter struct {
+	dir string
+}
+
+func NewFileSecretGetter(dir string) SecretGetter {
+	return &fileSecretGetter{dir: dir}
+}
+
+func (g *fileSecretGetter) GetDockerConfig(namespace string, name string) (DockerConfigJSON, error) {
+	if namespace == "" || name == "" || filepath.Base(name) != name || filepath.Base(namespace) != namespace {
+		return DockerConfigJSON{}, fmt.Errorf("invalid secret %s/%s", namespace, name)
+	}
+	data, err := os.ReadFile(filepath.Join(g.dir, namespace, name+".json"))
+	if err != nil {
+		return DockerConfigJSON{}, err
+	}
+	return ParseDockerConfigJSON(data)
+}
+
+// 根据pod的imagePullSecrets构造keyring，找不到的secret只打日志，不影响拉取（可能是公开的镜像）
+func KeyringForPod(getter SecretGetter, pod *apis.Pod) *Keyring {
+	keyring := NewKeyring()
+	if getter == nil {
+		return keyring
+	}
+	for _, secret := range pod.Spec.ImagePullSecrets {
+		config, err := getter.GetDockerConfig(pod.Namespace, secret.Name)
+		if err != nil {
+			K8sLogger.Warnln("get image pull secret ", pod.Namespace, "/", secret.Name, " error: ", err)
+			continue
+		}
+		keyring.Add(config)
+	}
+	return keyring
+}
@@ -8,11 +8,21 @@ import (
 
 var (
 	dclient   *client.Client
-	K8sLogger = logger.K8sLogger
+	K8sLogger = logger.Named("dockerclient")
 )
 
 func NewDockerClient() (*client.Client, error) {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	return NewDockerClientWithHost("")
+}
+
+// 连接指定地址的docker daemon，host为空的时候使用DOCKER_HOST环境变量或者docker的默认地址
+// 之后GetDockerClient返回的都是这个客户端
+func NewDockerClientWithHost(host string) (*client.Client, error) {
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	if host != "" {
+		opts = append(opts, client.WithHost(host))
+	}
+	cli, err := client.NewClientWithOpts(opts...)
 	if err != nil {
 		K8sLogger.Error("NewDockerClient error: ", err)
 		return nil, err
@@ -21,12 +31,14 @@ func NewDockerClient() (*client.Client, error) {
 	return dclient, nil
 }
 
+// 返回之前创建的客户端，还没有创建过的时候按环境变量创建一个
+// 环境变量有问题的时候退回到docker的默认地址，保证不会返回nil，daemon是否可用由HealthMonitor检查
 func GetDockerClient() *client.Client {
 	if dclient == nil {
 		_, err := NewDockerClient()
 		if err != nil {
-			K8sLogger.Error("GetDockerClient error: ", err)
-			return nil
+			K8sLogger.Error("GetDockerClient error: ", err, ", falling back to the default docker host")
+			dclient, _ = client.NewClientWithOpts(client.WithAPIVersionNegotiation())
 		}
 	}
 	return dclient
@@ -0,0 +1,43 @@
+package dockertest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/docker/client"
+)
+
+/*
+	测试用的假docker daemon
+	daemon是一个http.Handler，按照docker的api返回需要的内容，测试结束的时候自动关闭
+*/
+
+// 固定api版本，不和假daemon协商
+const APIVersion = "1.43"
+
+// 启动一个假daemon，返回连接到它的docker客户端
+func NewClient(t testing.TB, daemon http.Handler) *client.Client {
+	t.Helper()
+	server := httptest.NewServer(daemon)
+	t.Cleanup(server.Close)
+	return newClient(t, server.Listener.Addr().String())
+}
+
+// 返回一个连接不上的docker客户端，用来模拟daemon不可用
+func NewUnreachableClient(t testing.TB) *client.Client {
+	t.Helper()
+	server := httptest.NewServer(http.NotFoundHandler())
+	addr := server.Listener.Addr().String()
+	server.Close()
+	return newClient(t, addr)
+}
+
+func newClient(t testing.TB, addr string) *client.Client {
+	c, err := client.NewClientWithOpts(client.WithHost("tcp://"+addr), client.WithVersion(APIVersion))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
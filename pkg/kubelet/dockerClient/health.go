@@ -0,0 +1,161 @@
+package dockerclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	runtimeerrors "minik8s/pkg/kubelet/runtime/runtimeErrors"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+/*
+	定期ping docker daemon，记录daemon是否可用
+	daemon不可用的时候按指数退避重试，恢复之后关掉旧的空闲连接并重新协商api版本（daemon可能升级了），
+	kubelet根据这里的状态决定要不要同步pod，/healthz也会报告这个状态
+*/
+
+const (
+	DefaultHealthCheckPeriod   = 10 * time.Second
+	DefaultHealthCheckTimeout  = 5 * time.Second
+	DefaultReconnectBackOff    = time.Second
+	DefaultReconnectMaxBackOff = 30 * time.Second
+)
+
+var ErrRuntimeUnhealthy = errors.New("container runtime is unhealthy")
+
+// docker daemon的健康状态
+type HealthStatus struct {
+	Healthy             bool      `json:"healthy"`
+	LastChecked         time.Time `json:"lastChecked"`
+	LastError           string    `json:"lastError,omitempty"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	APIVersion          string    `json:"apiVersion"`
+}
+
+type HealthMonitor struct {
+	client         *client.Client
+	period         time.Duration
+	timeout        time.Duration
+	initialBackOff time.Duration
+	maxBackOff     time.Duration
+
+	lock   sync.RWMutex
+	status HealthStatus
+	// daemon可用的时候是关闭的，WaitHealthy在上面等待
+	healthyCh chan struct{}
+}
+
+func NewHealthMonitor(c *client.Client, period time.Duration, timeout time.Duration) *HealthMonitor {
+	return &HealthMonitor{
+		client:         c,
+		period:         period,
+		timeout:        timeout,
+		initialBackOff: DefaultReconnectBackOff,
+		maxBackOff:     DefaultReconnectMaxBackOff,
+		healthyCh:      make(chan struct{}),
+	}
+}
+
+// ping一次daemon并更新状态
+func (m *HealthMonitor) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	ping, err := m.client.Ping(ctx)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	wasHealthy := m.status.Healthy
+	m.status.LastChecked = time.Now()
+	if err != nil {
+		m.status.Healthy = false
+		m.status.LastError = err.Error()
+		m.status.ConsecutiveFailures++
+		if wasHealthy {
+			K8sLogger.Errorln("docker daemon became unhealthy: ", err)
+			m.healthyCh = make(chan struct{})
+		}
+		return err
+	}
+	if !wasHealthy {
+		// daemon重启之后旧的keep-alive连接已经断了，重新协商一下api版本
+		m.client.Close()
+		m.client.NegotiateAPIVersionPing(ping)
+		if m.status.ConsecutiveFailures > 0 {
+			K8sLogger.Infoln("reconnected to docker daemon after ", m.status.ConsecutiveFailures, " failed checks")
+		}
+		close(m.healthyCh)
+	}
+	m.status.Healthy = true
+	m.status.LastError = ""
+	m.status.ConsecutiveFailures = 0
+	m.status.APIVersion = m.client.ClientVersion()
+	return nil
+}
+
+// 一直检查直到ctx结束，daemon不可用的时候检查间隔从initialBackOff开始翻倍，最多maxBackOff
+func (m *HealthMonitor) Run(ctx context.Context) {
+	backOff := m.initialBackOff
+	for {
+		interval := m.period
+		if err := m.Check(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			interval = backOff
+			backOff *= 2
+			if backOff > m.maxBackOff {
+				backOff = m.maxBackOff
+			}
+		} else {
+			backOff = m.initialBackOff
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+func (m *HealthMonitor) Healthy() bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.status.Healthy
+}
+
+func (m *HealthMonitor) Status() HealthStatus {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.status
+}
+
+// daemon可用的时候返回nil，否则返回带上最后一次错误的ErrRuntimeUnhealthy
+// 返回的错误同时也是runtimeerrors.ErrRuntimeUnavailable
+func (m *HealthMonitor) Err() error {
+	status := m.Status()
+	if status.Healthy {
+		return nil
+	}
+	if status.LastChecked.IsZero() {
+		return runtimeerrors.New(runtimeerrors.ErrRuntimeUnavailable, fmt.Errorf("%w: not checked yet", ErrRuntimeUnhealthy), "")
+	}
+	return runtimeerrors.New(runtimeerrors.ErrRuntimeUnavailable, fmt.Errorf("%w: %s", ErrRuntimeUnhealthy, status.LastError), "")
+}
+
+// 等到daemon可用或者ctx结束
+func (m *HealthMonitor) WaitHealthy(ctx context.Context) error {
+	m.lock.RLock()
+	ch := m.healthyCh
+	m.lock.RUnlock()
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,102 @@
+package dockerclient
+
+import (
+	"context"
+	"errors"
+	"minik8s/pkg/kubelet/dockerClient/dockertest"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	runtimeerrors "minik8s/pkg/kubelet/runtime/runtimeErrors"
+)
+
+// 模拟一个可以"重启"的docker daemon，down的时候/_ping返回500
+type flakyDaemon struct {
+	down  atomic.Bool
+	pings atomic.Int32
+}
+
+func (d *flakyDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.pings.Add(1)
+	if d.down.Load() {
+		http.Error(w, "daemon is restarting", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Api-Version", "1.43")
+	w.Write([]byte("OK"))
+}
+
+func newTestMonitor(t *testing.T, daemon http.Handler) *HealthMonitor {
+	c := dockertest.NewClient(t, daemon)
+	return NewHealthMonitor(c, 20*time.Millisecond, time.Second)
+}
+
+func TestHealthMonitorCheck(t *testing.T) {
+	daemon := &flakyDaemon{}
+	m := newTestMonitor(t, daemon)
+	if err := m.Err(); !errors.Is(err, ErrRuntimeUnhealthy) {
+		t.Fatalf("expected unhealthy before the first check, got %v", err)
+	}
+	if err := m.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if status := m.Status(); !status.Healthy || status.APIVersion != "1.43" {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	daemon.down.Store(true)
+	m.Check(context.Background())
+	m.Check(context.Background())
+	status := m.Status()
+	if status.Healthy || status.ConsecutiveFailures != 2 || status.LastError == "" {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if err := m.Err(); !errors.Is(err, ErrRuntimeUnhealthy) || !errors.Is(err, runtimeerrors.ErrRuntimeUnavailable) {
+		t.Fatalf("expected ErrRuntimeUnhealthy, got %v", err)
+	}
+
+	daemon.down.Store(false)
+	if err := m.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if status := m.Status(); !status.Healthy || status.ConsecutiveFailures != 0 {
+		t.Fatalf("unexpected status after recovery %+v", status)
+	}
+}
+
+func TestHealthMonitorReconnects(t *testing.T) {
+	daemon := &flakyDaemon{}
+	daemon.down.Store(true)
+	m := newTestMonitor(t, daemon)
+	m.initialBackOff = 5 * time.Millisecond
+	m.maxBackOff = 20 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx)
+		close(done)
+	}()
+
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	if err := m.WaitHealthy(waitCtx); err == nil {
+		t.Fatal("daemon is down, WaitHealthy should time out")
+	}
+	waitCancel()
+	if daemon.pings.Load() < 2 {
+		t.Fatalf("expected retries while the daemon is down, got %d pings", daemon.pings.Load())
+	}
+
+	daemon.down.Store(false)
+	waitCtx, waitCancel = context.WithTimeout(context.Background(), 2*time.Second)
+	defer waitCancel()
+	if err := m.WaitHealthy(waitCtx); err != nil {
+		t.Fatal("monitor did not reconnect: ", err)
+	}
+	if !m.Healthy() {
+		t.Fatal("expected healthy after reconnect")
+	}
+	cancel()
+	<-done
+}
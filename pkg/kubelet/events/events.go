@@ -0,0 +1,99 @@
+package events
+
+import (
+	"fmt"
+	"minik8s/pkg/apis"
+	"sync"
+	"time"
+)
+
+/*
+	kubelet在处理pod的时候产生的事件，比如拉取镜像、创建容器等
+	现在只保存在内存里面，每个pod只保留最近的若干条
+*/
+
+const (
+	EventTypeNormal  = "Normal"
+	EventTypeWarning = "Warning"
+)
+
+// 事件的原因，和k8s保持一致
+const (
+	// 镜像相关
+	PullingImage            = "Pulling"
+	PulledImage             = "Pulled"
+	FailedToPullImage       = "ErrImagePull"
+	ErrImageNeverPullPolicy = "ErrImageNeverPull"
+	BackOffPullImage        = "BackOff"
+
+	// pod同步相关
+	FailedSync = "FailedSync"
+)
+
+const maxEventsPerPod = 64
+
+type Event struct {
+	Type      string    `json:"type" yaml:"type"`
+	Reason    string    `json:"reason" yaml:"reason"`
+	Message   string    `json:"message" yaml:"message"`
+	Timestamp time.Time `json:"timestamp" yaml:"timestamp"`
+	// 事件所属的pod
+	PodName      string `json:"podName" yaml:"podName"`
+	PodNamespace string `json:"podNamespace" yaml:"podNamespace"`
+	PodUID       string `json:"podUID" yaml:"podUID"`
+}
+
+type EventRecorder interface {
+	Event(pod *apis.Pod, eventType, reason, message string)
+	Eventf(pod *apis.Pod, eventType, reason, messageFmt string, args ...interface{})
+	// 获取一个pod的所有事件，按时间顺序
+	GetPodEvents(podUID string) []Event
+	// pod被删除之后清理它的事件
+	RemovePodEvents(podUID string)
+}
+
+type recorder struct {
+	lock   sync.RWMutex
+	events map[string][]Event // pod uid -> 事件
+}
+
+func NewRecorder() EventRecorder {
+	return &recorder{
+		events: map[string][]Event{},
+	}
+}
+
+func (r *recorder) Event(pod *apis.Pod, eventType, reason, message string) {
+	event := Event{
+		Type:         eventType,
+		Reason:       reason,
+		Message:      message,
+		Timestamp:    time.Now(),
+		PodName:      pod.Name,
+		PodNamespace: pod.Namespace,
+		PodUID:       pod.UID,
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	events := append(r.events[pod.UID], event)
+	if len(events) > maxEventsPerPod {
+		events = events[len(events)-maxEventsPerPod:]
+	}
+	r.events[pod.UID] = events
+}
+
+func (r *recorder) Eventf(pod *apis.Pod, eventType, reason, messageFmt string, args ...interface{}) {
+	r.Event(pod, eventType, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r *recorder) GetPodEvents(podUID string) []Event {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return append([]Event{}, r.events[podUID]...)
+}
+
+func (r *recorder) RemovePodEvents(podUID string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.events, podUID)
+}
@@ -1,5 +1,384 @@
 package kubelet
 
-func syncPods() {
+import (
+	"context"
+	"errors"
+	"fmt"
+	"minik8s/logger"
+	"minik8s/pkg/apis"
+	"minik8s/pkg/kubelet/config"
+	dockerclient "minik8s/pkg/kubelet/dockerClient"
+	"minik8s/pkg/kubelet/events"
+	"minik8s/pkg/kubelet/metrics"
+	"minik8s/pkg/kubelet/prober"
+	"minik8s/pkg/kubelet/runtime"
+	containermanager "minik8s/pkg/kubelet/runtime/containerManager"
+	imagemanager "minik8s/pkg/kubelet/runtime/imageManager"
+	runtimeerrors "minik8s/pkg/kubelet/runtime/runtimeErrors"
+	"minik8s/pkg/kubelet/server"
+	staticpod "minik8s/pkg/kubelet/staticPod"
+	"minik8s/pkg/kubelet/stats"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
 
+var (
+	K8sLogger = logger.Named("kubelet")
+)
+
+type Kubelet struct {
+	config         *config.KubeletConfig
+	runtimeManager runtime.RuntimeManager
+	recorder       events.EventRecorder
+	imageManager   *imagemanager.ImageManager
+	imageGCManager *imagemanager.ImageGCManager
+	server         *server.Server
+	statsProvider  *stats.Provider
+	runtimeHealth  *dockerclient.HealthMonitor
+	probeManager   *prober.Manager
+
+	// 静态pod的来源，没有配置静态pod目录的时候为nil
+	staticPodSource *staticpod.Source
+	// 没有配置apiserver的时候为nil
+	mirrorClient staticpod.MirrorClient
+
+	podLock sync.RWMutex
+	pods    map[string]*apis.Pod // pod uid -> pod
+	mirrors map[string]bool      // 已经在apiserver中创建了mirror pod的静态pod uid
+}
+
+func NewKubelet(cfg *config.KubeletConfig) (*Kubelet, error) {
+	if err := cfg.Sandbox.Validate(); err != nil {
+		K8sLogger.Errorln("NewKubelet error: ", err)
+		return nil, err
+	}
+	// 先按配置连接docker，之后各个组件通过GetDockerClient拿到的都是这个客户端
+	dockerClient, err := dockerclient.NewDockerClientWithHost(cfg.DockerHost)
+	if err != nil {
+		K8sLogger.Errorln("NewKubelet error: ", err)
+		return nil, err
+	}
+	runtimeHealth := dockerclient.NewHealthMonitor(dockerClient, cfg.RuntimeHealthCheckPeriod, dockerclient.DefaultHealthCheckTimeout)
+	recorder := events.NewRecorder()
+	imageManager := imagemanager.NewImageManager(dockerClient)
+	imageGCManager, err := imagemanager.NewImageGCManager(
+		imageManager,
+		imagemanager.ImageGCPolicy{
+			HighThresholdPercent: cfg.ImageGCHighThresholdPercent,
+			LowThresholdPercent:  cfg.ImageGCLowThresholdPercent,
+			MinAge:               cfg.ImageMinimumGCAge,
+		},
+		cfg.Sandbox.PauseImage(),
+	)
+	if err != nil {
+		K8sLogger.Errorln("NewKubelet error: ", err)
+		return nil, err
+	}
+	containerManager := containermanager.NewContainerManager(dockerClient)
+	containerManager.SetRequestTimeout(cfg.RuntimeRequestTimeout)
+	statsProvider := stats.NewProvider(containerManager, cfg.NodeName)
+	if err := metrics.Register(stats.NewMetricsCollector(statsProvider)); err != nil {
+		K8sLogger.Errorln("NewKubelet error: ", err)
+		return nil, err
+	}
+	k := &Kubelet{
+		config:         cfg,
+		runtimeManager: runtime.NewRuntimeManager(cfg, recorder),
+		recorder:       recorder,
+		imageManager:   imageManager,
+		imageGCManager: imageGCManager,
+		statsProvider:  statsProvider,
+		runtimeHealth:  runtimeHealth,
+		probeManager:   prober.NewManager(),
+		pods:           map[string]*apis.Pod{},
+		mirrors:        map[string]bool{},
+	}
+	k.server = server.NewServer(containerManager, statsProvider, runtimeHealth.Err, k.podUID)
+	if cfg.StaticPodPath != "" {
+		k.staticPodSource = staticpod.NewSource(cfg.StaticPodPath, cfg.NodeName, cfg.FileCheckFrequency)
+	}
+	if cfg.APIServerAddress != "" {
+		k.mirrorClient = staticpod.NewMirrorClient(cfg.APIServerAddress)
+	}
+	return k, nil
+}
+
+// 启动kubelet，等docker可用之后先从上次运行留下的容器和检查点中恢复pod，然后进入同步循环直到ctx结束
+func (k *Kubelet) Run(ctx context.Context) error {
+	// 所有的后台循环都在ctx结束（或者启动失败）的时候退出，Run等它们都退出之后才返回
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	goLoop := func(loop func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			loop()
+		}()
+	}
+
+	goLoop(func() { k.runtimeHealth.Run(ctx) })
+	// 恢复pod的时候就会开始收集日志，退出的时候要等日志都写到文件里
+	goLoop(func() { k.runtimeManager.RunLogCollector(ctx) })
+	if !k.runtimeHealth.Healthy() {
+		K8sLogger.Infoln("waiting for docker daemon to become available")
+	}
+	if err := k.runtimeHealth.WaitHealthy(ctx); err != nil {
+		// 还没连上docker就被要求退出了
+		return nil
+	}
+
+	pauseImage := k.config.Sandbox.PauseImage()
+	// 离线环境下先从tar包加载镜像，pause镜像必须在本地才能创建pod
+	if k.config.ImagePreloadDir != "" {
+		err := k.imageManager.PreloadImages(ctx, k.config.ImagePreloadDir, []string{pauseImage})
+		if err != nil {
+			K8sLogger.Errorln("preload images error: ", err)
+		}
+	}
+	// 检查pause镜像已经在本地或者可以拉取，否则任何pod都创建不了
+	if err := k.imageManager.PullImage(ctx, k.config.Sandbox.ImagePullPolicy, pauseImage); err != nil {
+		K8sLogger.Errorln("sandbox image ", pauseImage, " is not available: ", err)
+		return fmt.Errorf("sandbox image %s is not available: %w", pauseImage, err)
+	}
+	pods, err := k.runtimeManager.RecoverPods(ctx)
+	if err != nil {
+		K8sLogger.Errorln("recover pods error: ", err)
+		return err
+	}
+	k.podLock.Lock()
+	for _, pod := range pods {
+		k.pods[pod.UID] = pod
+	}
+	k.podLock.Unlock()
+	K8sLogger.Infoln("kubelet recovered ", len(pods), " pods")
+
+	goLoop(func() { k.imageGCManager.Start(ctx, k.config.ImageGCPeriod) })
+	goLoop(func() {
+		addr := net.JoinHostPort(k.config.Address, strconv.Itoa(k.config.Port))
+		if err := k.server.ListenAndServe(ctx, addr); err != nil {
+			K8sLogger.Errorln("kubelet server error: ", err)
+		}
+	})
+	goLoop(func() { k.containerGCLoop(ctx) })
+	goLoop(func() { k.statsProvider.Start(ctx, k.config.StatsPeriod) })
+	goLoop(func() { k.probeManager.Run(ctx) })
+	if k.config.MetricsPort != 0 {
+		goLoop(func() {
+			addr := net.JoinHostPort(k.config.Address, strconv.Itoa(k.config.MetricsPort))
+			if err := metrics.ListenAndServe(ctx, addr); err != nil {
+				K8sLogger.Errorln("kubelet metrics server error: ", err)
+			}
+		})
+	}
+
+	staticPodUpdates := make(chan []*apis.Pod)
+	if k.staticPodSource != nil {
+		goLoop(func() { k.staticPodSource.Run(ctx, staticPodUpdates) })
+	}
+	k.syncLoop(ctx, staticPodUpdates)
+	K8sLogger.Infoln("kubelet stopping, waiting for background loops to exit")
+	return nil
+}
+
+func (k *Kubelet) syncLoop(ctx context.Context, staticPodUpdates <-chan []*apis.Pod) {
+	ticker := time.NewTicker(k.config.SyncFrequency)
+	defer ticker.Stop()
+	for {
+		select {
+		case pods := <-staticPodUpdates:
+			k.handleStaticPods(ctx, pods)
+		case update := <-k.probeManager.Updates():
+			k.handleProbeUpdate(ctx, update)
+		case <-ticker.C:
+			k.syncPods(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// 定期回收退出的容器和孤儿容器
+func (k *Kubelet) containerGCLoop(ctx context.Context) {
+	policy := runtime.ContainerGCPolicy{
+		MinAge:             k.config.MinimumContainerGCAge,
+		MaxPerPodContainer: k.config.MaxPerPodContainerCount,
+		OrphanGracePeriod:  k.config.OrphanedContainerGracePeriod,
+	}
+	ticker := time.NewTicker(k.config.ContainerGCPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := k.runtimeManager.GarbageCollect(ctx, policy); err != nil {
+				K8sLogger.Errorln("container garbage collection error: ", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// 静态pod目录发生了变化，pods是目录中当前所有的pod
+func (k *Kubelet) handleStaticPods(ctx context.Context, pods []*apis.Pod) {
+	desired := map[string]*apis.Pod{}
+	for _, pod := range pods {
+		desired[pod.UID] = pod
+	}
+	k.podLock.Lock()
+	var added, removed []*apis.Pod
+	for uid, pod := range k.pods {
+		if staticpod.IsStaticPod(pod) && desired[uid] == nil {
+			removed = append(removed, pod)
+			delete(k.pods, uid)
+		}
+	}
+	for uid, pod := range desired {
+		if k.pods[uid] == nil {
+			added = append(added, pod)
+			k.pods[uid] = pod
+		}
+	}
+	k.podLock.Unlock()
+
+	for _, pod := range removed {
+		K8sLogger.Infoln("static pod removed: ", pod.Namespace, "/", pod.Name)
+		k.probeManager.RemovePod(pod.UID)
+		if err := k.runtimeManager.KillPod(ctx, pod); err != nil {
+			K8sLogger.Errorln("kill static pod error: ", err)
+		}
+		k.deleteMirrorPod(pod)
+	}
+	for _, pod := range added {
+		K8sLogger.Infoln("static pod added: ", pod.Namespace, "/", pod.Name)
+		k.syncPod(ctx, pod)
+	}
+}
+
+// 定期同步所有的pod，已经存在的容器不会被重复创建
+// docker不可用的时候跳过这一轮，等重新连上之后再同步
+func (k *Kubelet) syncPods(ctx context.Context) {
+	if err := k.runtimeHealth.Err(); err != nil {
+		K8sLogger.Warnln("skipping pod sync: ", err)
+		return
+	}
+	k.podLock.RLock()
+	pods := make([]*apis.Pod, 0, len(k.pods))
+	for _, pod := range k.pods {
+		// 已经失败的pod不再同步
+		if pod.Phase == apis.PodFailed {
+			continue
+		}
+		pods = append(pods, pod)
+	}
+	k.podLock.RUnlock()
+	for _, pod := range pods {
+		if ctx.Err() != nil {
+			return
+		}
+		k.syncPod(ctx, pod)
+	}
+}
+
+// 按照namespace和名字找到节点上的pod，给kubelet的http服务用
+func (k *Kubelet) podUID(namespace, name string) (string, bool) {
+	k.podLock.RLock()
+	defer k.podLock.RUnlock()
+	for uid, pod := range k.pods {
+		if pod.Namespace == namespace && pod.Name == name {
+			return uid, true
+		}
+	}
+	return "", false
+}
+
+func (k *Kubelet) syncPod(ctx context.Context, pod *apis.Pod) {
+	err := k.runtimeManager.SyncPod(ctx, pod)
+	if ctx.Err() == nil {
+		k.updatePodStatus(ctx, pod)
+	}
+	if err != nil {
+		k.handleSyncError(ctx, pod, err)
+	}
+	if staticpod.IsStaticPod(pod) {
+		k.createMirrorPod(pod)
+	}
+}
+
+// 根据错误的类别决定怎么处理同步失败的pod
+func (k *Kubelet) handleSyncError(ctx context.Context, pod *apis.Pod, err error) {
+	log := logger.WithPod(K8sLogger, pod.Namespace, pod.Name, pod.UID)
+	switch {
+	case errors.Is(err, context.Canceled) && ctx.Err() != nil:
+		// kubelet正在退出
+		log.Infoln("sync pod cancelled: ", err)
+	case !runtimeerrors.IsRetryable(err):
+		// pod的配置有问题，重试也不会成功，直接标记为失败
+		log.Errorln("sync pod failed, giving up: ", err)
+		k.recorder.Eventf(pod, events.EventTypeWarning, events.FailedSync, "Error syncing pod: %v", err)
+		k.podLock.Lock()
+		pod.Phase = apis.PodFailed
+		k.podLock.Unlock()
+	case errors.Is(err, runtimeerrors.ErrRuntimeUnavailable):
+		// 立刻检查一次docker，不可用的话之后的同步会被跳过，直到重新连上
+		log.Warnln("sync pod error, container runtime unavailable: ", err)
+		k.runtimeHealth.Check(ctx)
+	case errors.Is(err, runtimeerrors.ErrImagePullFailed):
+		// 拉取镜像的事件已经记录过了，imagePuller会退避，下一轮同步的时候重试
+		log.Warnln("sync pod error, will retry after image pull back-off: ", err)
+	default:
+		log.Errorln("sync pod error, will retry: ", err)
+		k.recorder.Eventf(pod, events.EventTypeWarning, events.FailedSync, "Error syncing pod: %v", err)
+	}
+}
+
+// apiserver可能比kubelet晚启动，所以创建失败的mirror pod会在下一次同步的时候重试
+func (k *Kubelet) createMirrorPod(pod *apis.Pod) {
+	if k.mirrorClient == nil {
+		return
+	}
+	k.podLock.RLock()
+	done := k.mirrors[pod.UID]
+	k.podLock.RUnlock()
+	if done {
+		return
+	}
+	if err := k.mirrorClient.CreateMirrorPod(pod); err != nil {
+		K8sLogger.Errorln("create mirror pod error: ", err)
+		return
+	}
+	k.podLock.Lock()
+	k.mirrors[pod.UID] = true
+	k.podLock.Unlock()
+}
+
+func (k *Kubelet) deleteMirrorPod(pod *apis.Pod) {
+	k.podLock.Lock()
+	delete(k.mirrors, pod.UID)
+	k.podLock.Unlock()
+	if k.mirrorClient == nil {
+		return
+	}
+	if err := k.mirrorClient.DeleteMirrorPod(pod); err != nil {
+		K8sLogger.Errorln("delete mirror pod error: ", err)
+	}
+}
+
+// 获取一个pod上记录的事件（拉取镜像等）
+func (k *Kubelet) GetPodEvents(podUID string) []events.Event {
+	return k.recorder.GetPodEvents(podUID)
+}
+
+// 获取一个pod的状态，资源使用情况是上一次同步的时候写进去的
+func (k *Kubelet) GetPodStatus(podUID string) (apis.PodStatus, bool) {
+	k.podLock.RLock()
+	defer k.podLock.RUnlock()
+	pod, ok := k.pods[podUID]
+	if !ok {
+		return apis.PodStatus{}, false
+	}
+	return pod.PodStatus, true
 }
@@ -0,0 +1,120 @@
+package logs
+
+import (
+	"context"
+	"io"
+	containermanager "minik8s/pkg/kubelet/runtime/containerManager"
+	"strconv"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// 把容器的输出持续写到CRI格式的日志文件中，容器退出之后自动结束
+type Collector struct {
+	cm       *containermanager.ContainerManager
+	maxSize  int64
+	maxFiles int
+
+	// 所有收集的ctx都从这里派生，StopAll的时候一起取消
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	lock    sync.Mutex
+	running map[string]context.CancelFunc // 容器id -> 停止收集
+}
+
+func NewCollector(cm *containermanager.ContainerManager, maxSize int64, maxFiles int) *Collector {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Collector{
+		cm:       cm,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+		ctx:      ctx,
+		cancel:   cancel,
+		running:  map[string]context.CancelFunc{},
+	}
+}
+
+// 一直运行到ctx结束，然后停止所有的收集
+func (c *Collector) Run(ctx context.Context) {
+	<-ctx.Done()
+	c.StopAll()
+}
+
+// 停止所有的收集，等所有的日志都写到文件里之后才返回，之后Start不会再开始收集
+func (c *Collector) StopAll() {
+	c.lock.Lock()
+	c.cancel()
+	c.lock.Unlock()
+	c.wg.Wait()
+}
+
+// 开始收集一个容器的日志，已经在收集的容器不会重复收集
+func (c *Collector) Start(containerID string, path string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.running[containerID]; ok || c.ctx.Err() != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(c.ctx)
+	c.running[containerID] = cancel
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer c.Stop(containerID)
+		if err := c.collect(ctx, containerID, path); err != nil && ctx.Err() == nil {
+			K8sLogger.Errorln("collect logs of container ", containerID, " error: ", err)
+		}
+	}()
+}
+
+func (c *Collector) Stop(containerID string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if cancel, ok := c.running[containerID]; ok {
+		cancel()
+		delete(c.running, containerID)
+	}
+}
+
+func (c *Collector) collect(ctx context.Context, containerID string, path string) error {
+	inspect, err := c.cm.InspectContainer(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	// kubelet重启之后接着上次写到的位置继续写
+	after := lastTimestamp(path)
+	opts := types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+		Timestamps: true,
+	}
+	if !after.IsZero() {
+		opts.Since = strconv.FormatInt(after.Unix(), 10)
+	}
+	rc, err := c.cm.GetContainerLogsWithOpts(ctx, containerID, opts)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	file, err := OpenRotatingFile(path, c.maxSize, c.maxFiles)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	stdout := newCRILineWriter(StreamStdout, file, true, after)
+	stderr := newCRILineWriter(StreamStderr, file, true, after)
+	if inspect.Config != nil && inspect.Config.Tty {
+		_, err = io.Copy(stdout, rc)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, rc)
+	}
+	stdout.Flush()
+	stderr.Flush()
+	return err
+}
@@ -0,0 +1,70 @@
+package logs
+
+import (
+	"encoding/json"
+	"minik8s/pkg/kubelet/dockerClient/dockertest"
+	containermanager "minik8s/pkg/kubelet/runtime/containerManager"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// 容器一直在运行，输出了一行完整的日志和一行没有换行的日志
+func runningContainerDaemon() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/json"):
+			json.NewEncoder(w).Encode(types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{ID: "c1"},
+				Config:            &container.Config{Tty: false},
+			})
+		case strings.HasSuffix(r.URL.Path, "/logs"):
+			stdcopy.NewStdWriter(w, stdcopy.Stdout).Write([]byte("2023-01-01T00:00:00Z first\n2023-01-01T00:00:01Z partial"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+// 退出的时候停止所有的收集，没有换行的最后一行也要写到文件里
+func TestCollectorStopAllFlushes(t *testing.T) {
+	c := NewCollector(containermanager.NewContainerManager(dockertest.NewClient(t, runningContainerDaemon())), 0, 1)
+	path := filepath.Join(t.TempDir(), "web", "0.log")
+	c.Start("c1", path)
+	// 完整的一行写到文件里之后，没有换行的一行还在缓冲中
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if data, _ := os.ReadFile(path); len(data) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("collector did not follow the logs")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.StopAll()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "2023-01-01T00:00:00Z stdout F first\n2023-01-01T00:00:01Z stdout F partial\n" {
+		t.Fatalf("unexpected log file %q", data)
+	}
+	// 停止之后不会再开始收集
+	c.Start("c2", filepath.Join(t.TempDir(), "other", "0.log"))
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.running) != 0 {
+		t.Fatalf("expected no running collectors, got %v", c.running)
+	}
+}
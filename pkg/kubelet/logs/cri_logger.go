@@ -0,0 +1,225 @@
+package logs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+	CRI格式的日志文件，每一行是：
+	2016-10-06T00:17:09.669794202Z stdout F log message
+	时间戳 流(stdout/stderr) 标记(F完整的一行，P被截断的一部分) 内容
+	文件超过最大大小之后轮转：0.log -> 0.log.1 -> 0.log.2 ...，最多保留maxFiles个文件
+*/
+
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+
+	tagFull    = "F"
+	tagPartial = "P"
+
+	// 一行日志超过这个长度就切成多个P行
+	maxLogLineSize = 16 * 1024
+)
+
+type RotatingFile struct {
+	lock     sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+// 以追加的方式打开日志文件，maxSize<=0表示不轮转
+func OpenRotatingFile(path string, maxSize int64, maxFiles int) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	rf := &RotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *RotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rf.file = f
+	rf.size = info.Size()
+	return nil
+}
+
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+	if rf.maxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// 把当前文件改名为.1，原来的.1改名为.2，以此类推，超过maxFiles的删除
+func (rf *RotatingFile) rotate() error {
+	if err := rf.file.Close(); err != nil {
+		return err
+	}
+	if rf.maxFiles > 1 {
+		os.Remove(rotatedName(rf.path, rf.maxFiles-1))
+		for i := rf.maxFiles - 2; i >= 1; i-- {
+			os.Rename(rotatedName(rf.path, i), rotatedName(rf.path, i+1))
+		}
+		if err := os.Rename(rf.path, rotatedName(rf.path, 1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(rf.path); err != nil {
+		return err
+	}
+	return rf.open()
+}
+
+func (rf *RotatingFile) Close() error {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+	return rf.file.Close()
+}
+
+func rotatedName(path string, i int) string {
+	return path + "." + strconv.Itoa(i)
+}
+
+// 删除一个日志文件以及它所有轮转出来的文件
+func RemoveLogFiles(path string) error {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return err
+	}
+	for _, m := range append(matches, path) {
+		if err := os.Remove(m); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// 把一个流的输出按行转成CRI格式写到文件中
+// docker开启timestamps之后每一行前面都有RFC3339Nano格式的时间戳，没有的话用当前时间
+type criLineWriter struct {
+	stream      string
+	out         *RotatingFile
+	timestamped bool
+	after       time.Time // 时间戳不晚于这个时间的行会被丢掉，用来在kubelet重启之后避免重复
+	buf         []byte
+}
+
+func newCRILineWriter(stream string, out *RotatingFile, timestamped bool, after time.Time) *criLineWriter {
+	return &criLineWriter{stream: stream, out: out, timestamped: timestamped, after: after}
+}
+
+func (w *criLineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := w.buf[:i]
+		// 太长的行切成多个P行，最后一段是F
+		for len(line) > maxLogLineSize {
+			if err := w.writeLine(line[:maxLogLineSize], tagPartial); err != nil {
+				return 0, err
+			}
+			line = line[maxLogLineSize:]
+		}
+		if err := w.writeLine(line, tagFull); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[i+1:]
+	}
+	// 还没有换行的部分太长了也先写一部分出去
+	for len(w.buf) > maxLogLineSize {
+		if err := w.writeLine(w.buf[:maxLogLineSize], tagPartial); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[maxLogLineSize:]
+	}
+	return len(p), nil
+}
+
+// 流结束的时候把最后不完整的一行写出去
+func (w *criLineWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	err := w.writeLine(w.buf, tagFull)
+	w.buf = nil
+	return err
+}
+
+func (w *criLineWriter) writeLine(line []byte, tag string) error {
+	ts := time.Now()
+	if w.timestamped {
+		if i := bytes.IndexByte(line, ' '); i > 0 {
+			if parsed, err := time.Parse(time.RFC3339Nano, string(line[:i])); err == nil {
+				ts = parsed
+				line = line[i+1:]
+			}
+		}
+	}
+	if !w.after.IsZero() && !ts.After(w.after) {
+		return nil
+	}
+	// tty的输出每行结尾是\r\n
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	_, err := fmt.Fprintf(w.out, "%s %s %s %s\n", ts.UTC().Format(time.RFC3339Nano), w.stream, tag, line)
+	return err
+}
+
+// 读取日志文件最后一行的时间戳，文件不存在或者为空返回零值
+func lastTimestamp(path string) time.Time {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return time.Time{}
+	}
+	size := int64(2 * maxLogLineSize)
+	if info.Size() < size {
+		size = info.Size()
+	}
+	buf := make([]byte, size)
+	if _, err := f.ReadAt(buf, info.Size()-size); err != nil {
+		return time.Time{}
+	}
+	buf = bytes.TrimSuffix(buf, []byte("\n"))
+	if i := bytes.LastIndexByte(buf, '\n'); i >= 0 {
+		buf = buf[i+1:]
+	}
+	if i := bytes.IndexByte(buf, ' '); i > 0 {
+		if ts, err := time.Parse(time.RFC3339Nano, string(buf[:i])); err == nil {
+			return ts
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,109 @@
+package logs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCRILineWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "web", "0.log")
+	file, err := OpenRotatingFile(path, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := newCRILineWriter(StreamStdout, file, true, time.Time{})
+	// 一行分两次写入，tty的\r\n，最后没有换行的一行
+	w.Write([]byte("2023-01-01T00:00:00.000000001Z hel"))
+	w.Write([]byte("lo\r\n2023-01-01T00:00:01Z world\n"))
+	w.Write([]byte("2023-01-01T00:00:02Z tail"))
+	w.Flush()
+	long := newCRILineWriter(StreamStderr, file, false, time.Time{})
+	long.Write([]byte(strings.Repeat("x", maxLogLineSize+1) + "\n"))
+	file.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	want := []string{
+		"2023-01-01T00:00:00.000000001Z stdout F hello",
+		"2023-01-01T00:00:01Z stdout F world",
+		"2023-01-01T00:00:02Z stdout F tail",
+	}
+	if len(lines) != 5 {
+		t.Fatalf("expected 5 lines, got %d: %q", len(lines), data)
+	}
+	for i, line := range want {
+		if lines[i] != line {
+			t.Fatalf("line %d: expected %q, got %q", i, line, lines[i])
+		}
+	}
+	if !strings.Contains(lines[3], " stderr P ") || !strings.HasSuffix(lines[4], " stderr F x") {
+		t.Fatalf("expected long line to be split into P and F, got %q and %q", lines[3][:40], lines[4])
+	}
+	if ts := lastTimestamp(path); ts.IsZero() || ts.Year() < 2024 {
+		t.Fatalf("unexpected last timestamp %v", ts)
+	}
+}
+
+func TestCRILineWriterSkipsWrittenLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "0.log")
+	file, err := OpenRotatingFile(path, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, _ := time.Parse(time.RFC3339Nano, "2023-01-01T00:00:01Z")
+	w := newCRILineWriter(StreamStdout, file, true, after)
+	w.Write([]byte("2023-01-01T00:00:00Z a\n2023-01-01T00:00:01Z b\n2023-01-01T00:00:02Z c\n"))
+	file.Close()
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "2023-01-01T00:00:02Z stdout F c\n" {
+		t.Fatalf("expected only lines after the last written one, got %q", data)
+	}
+	if ts := lastTimestamp(path); !ts.Equal(after.Add(time.Second)) {
+		t.Fatalf("expected last timestamp %v, got %v", after.Add(time.Second), ts)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "0.log")
+	file, err := OpenRotatingFile(path, 10, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n", "dddddddd\n"} {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file.Close()
+
+	expect := map[string]string{
+		"0.log":   "dddddddd\n",
+		"0.log.1": "cccccccc\n",
+		"0.log.2": "bbbbbbbb\n",
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != len(expect) {
+		t.Fatalf("expected %d files, got %d", len(expect), len(entries))
+	}
+	for name, content := range expect {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(data) != content {
+			t.Fatalf("%s: expected %q, got %q (%v)", name, content, data, err)
+		}
+	}
+
+	if err := RemoveLogFiles(path); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expected all log files removed, %d left", len(entries))
+	}
+}
@@ -0,0 +1,174 @@
+package logs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"minik8s/logger"
+	"minik8s/minik8sTypes"
+	containermanager "minik8s/pkg/kubelet/runtime/containerManager"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+/*
+	容器日志服务
+	根据pod的uid和容器名找到容器，把docker的日志流拆成stdout和stderr，
+	支持follow、tailLines、sinceSeconds、limitBytes、timestamps和查看上一个实例的日志
+*/
+
+var (
+	K8sLogger = logger.Named("logs")
+)
+
+var (
+	ErrContainerNotFound  = errors.New("container not found")
+	ErrNoPreviousInstance = errors.New("previous terminated container not found")
+)
+
+type LogOptions struct {
+	Follow       bool   // 持续输出新的日志
+	TailLines    *int64 // 只输出最后几行
+	SinceSeconds *int64 // 只输出最近多少秒的日志
+	LimitBytes   *int64 // 最多输出多少字节
+	Timestamps   bool   // 每一行前面加上时间戳
+	Previous     bool   // 输出容器上一个实例（已经退出的）的日志
+}
+
+type LogService struct {
+	cm *containermanager.ContainerManager
+}
+
+func NewLogService(cm *containermanager.ContainerManager) *LogService {
+	return &LogService{cm: cm}
+}
+
+// 把容器日志写到stdout和stderr中，follow的时候一直阻塞到ctx结束或者容器退出
+func (s *LogService) GetContainerLogs(ctx context.Context, podUID, containerName string, opts LogOptions, stdout, stderr io.Writer) error {
+	containerID, err := s.findContainer(ctx, podUID, containerName, opts.Previous)
+	if err != nil {
+		return err
+	}
+	return s.GetContainerLogsByID(ctx, containerID, opts, stdout, stderr)
+}
+
+func (s *LogService) GetContainerLogsByID(ctx context.Context, containerID string, opts LogOptions, stdout, stderr io.Writer) error {
+	inspect, err := s.cm.InspectContainer(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	dockerOpts := types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     opts.Follow,
+		Timestamps: opts.Timestamps,
+		Tail:       "all",
+	}
+	if opts.TailLines != nil {
+		dockerOpts.Tail = strconv.FormatInt(*opts.TailLines, 10)
+	}
+	if opts.SinceSeconds != nil {
+		dockerOpts.Since = strconv.FormatInt(time.Now().Unix()-*opts.SinceSeconds, 10)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	rc, err := s.cm.GetContainerLogsWithOpts(ctx, containerID, dockerOpts)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if opts.LimitBytes != nil {
+		limiter := &limitedWriters{remaining: *opts.LimitBytes, cancel: cancel}
+		stdout = limiter.wrap(stdout)
+		stderr = limiter.wrap(stderr)
+	}
+	// 开了tty的容器只有一个原始的输出流，没有stdout/stderr的帧头
+	if inspect.Config != nil && inspect.Config.Tty {
+		_, err = io.Copy(stdout, rc)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, rc)
+	}
+	if errors.Is(err, errLimitReached) || (err != nil && ctx.Err() != nil) {
+		return nil
+	}
+	return err
+}
+
+// 找到pod中某个容器最新的实例，previous为true的时候找上一个实例
+// 上一个实例是容器被重新创建之前留下的已退出容器，容器没有被重新创建过的时候返回ErrNoPreviousInstance
+// 按uid查找，同名的pod被删掉重建之后，旧pod还没被回收的容器不会混进来
+func (s *LogService) findContainer(ctx context.Context, podUID, containerName string, previous bool) (string, error) {
+	filter := filters.NewArgs()
+	filter.Add("label", minik8sTypes.KubernetesPodUIDLabel+"="+podUID)
+	filter.Add("label", minik8sTypes.Minik8sPodTypeLabel+"="+minik8sTypes.Minik8sGenericPodType)
+	filter.Add("label", minik8sTypes.LabelsContainerName+"="+containerName)
+	containers, err := s.cm.ListContainerWithOpts(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filter,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(containers) == 0 {
+		return "", fmt.Errorf("%w: %s in pod %s", ErrContainerNotFound, containerName, podUID)
+	}
+	containermanager.SortByAttempt(containers)
+	if previous {
+		if len(containers) < 2 {
+			return "", fmt.Errorf("%w: %s in pod %s", ErrNoPreviousInstance, containerName, podUID)
+		}
+		return containers[1].ID, nil
+	}
+	return containers[0].ID, nil
+}
+
+var errLimitReached = errors.New("log limit reached")
+
+// stdout和stderr共享同一个字节数限制
+type limitedWriters struct {
+	lock      sync.Mutex
+	remaining int64
+	cancel    context.CancelFunc
+}
+
+func (l *limitedWriters) wrap(w io.Writer) io.Writer {
+	return &limitedWriter{w: w, limiter: l}
+}
+
+type limitedWriter struct {
+	w       io.Writer
+	limiter *limitedWriters
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	l := lw.limiter
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.remaining <= 0 {
+		l.cancel()
+		return 0, errLimitReached
+	}
+	truncated := false
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+		truncated = true
+	}
+	n, err := lw.w.Write(p)
+	l.remaining -= int64(n)
+	if err != nil {
+		return n, err
+	}
+	if truncated || l.remaining <= 0 {
+		l.cancel()
+		return n, errLimitReached
+	}
+	return n, nil
+}
@@ -0,0 +1,112 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"minik8s/minik8sTypes"
+	"minik8s/pkg/kubelet/dockerClient/dockertest"
+	containermanager "minik8s/pkg/kubelet/runtime/containerManager"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// 模拟docker daemon：两个实例，旧的实例日志是"old"，新的实例日志是stdout和stderr各一行
+// 两个实例在同一秒创建，只能按照attempt标签区分新旧
+type fakeLogDaemon struct {
+	lastQuery   map[string]string
+	listFilters string
+}
+
+func (d *fakeLogDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/containers/json"):
+		d.listFilters = r.URL.Query().Get("filters")
+		json.NewEncoder(w).Encode([]types.Container{
+			{ID: "old", Created: 1, Labels: map[string]string{minik8sTypes.KubernetesContainerAttemptLabel: "0"}},
+			{ID: "new", Created: 1, Labels: map[string]string{minik8sTypes.KubernetesContainerAttemptLabel: "1"}},
+		})
+	case strings.HasSuffix(r.URL.Path, "/json"):
+		json.NewEncoder(w).Encode(types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{ID: "x"},
+			Config:            &container.Config{Tty: false},
+		})
+	case strings.HasSuffix(r.URL.Path, "/logs"):
+		d.lastQuery = map[string]string{}
+		for k := range r.URL.Query() {
+			d.lastQuery[k] = r.URL.Query().Get(k)
+		}
+		stdout := stdcopy.NewStdWriter(w, stdcopy.Stdout)
+		stderr := stdcopy.NewStdWriter(w, stdcopy.Stderr)
+		if strings.Contains(r.URL.Path, "/old/") {
+			stdout.Write([]byte("old\n"))
+			return
+		}
+		stdout.Write([]byte("hello stdout\n"))
+		stderr.Write([]byte("hello stderr\n"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestLogService(t *testing.T, daemon http.Handler) *LogService {
+	c := dockertest.NewClient(t, daemon)
+	return NewLogService(containermanager.NewContainerManager(c))
+}
+
+func TestGetContainerLogsDemultiplexes(t *testing.T) {
+	daemon := &fakeLogDaemon{}
+	s := newTestLogService(t, daemon)
+	var stdout, stderr bytes.Buffer
+	tail := int64(10)
+	err := s.GetContainerLogs(context.Background(), "pod1", "nginx", LogOptions{TailLines: &tail, Timestamps: true}, &stdout, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "hello stdout\n" || stderr.String() != "hello stderr\n" {
+		t.Fatalf("unexpected output stdout=%q stderr=%q", stdout.String(), stderr.String())
+	}
+	if daemon.lastQuery["tail"] != "10" || daemon.lastQuery["timestamps"] != "1" {
+		t.Fatalf("unexpected docker query %v", daemon.lastQuery)
+	}
+	// 按uid查找，同名的旧pod留下的容器不会混进来
+	if !strings.Contains(daemon.listFilters, minik8sTypes.KubernetesPodUIDLabel+"=pod1") {
+		t.Fatalf("expected containers to be filtered by pod uid, got %s", daemon.listFilters)
+	}
+}
+
+func TestGetContainerLogsPreviousAndLimit(t *testing.T) {
+	s := newTestLogService(t, &fakeLogDaemon{})
+	var out bytes.Buffer
+	if err := s.GetContainerLogs(context.Background(), "pod1", "nginx", LogOptions{Previous: true}, &out, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "old\n" {
+		t.Fatalf("unexpected previous logs %q", out.String())
+	}
+
+	out.Reset()
+	limit := int64(8)
+	if err := s.GetContainerLogs(context.Background(), "pod1", "nginx", LogOptions{LimitBytes: &limit}, &out, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "hello st" {
+		t.Fatalf("expected output limited to 8 bytes, got %q", out.String())
+	}
+}
+
+func TestGetContainerLogsNotFound(t *testing.T) {
+	s := newTestLogService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	}))
+	err := s.GetContainerLogs(context.Background(), "pod1", "nginx", LogOptions{}, &bytes.Buffer{}, &bytes.Buffer{})
+	if !errors.Is(err, ErrContainerNotFound) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
@@ -1 +1,58 @@
 package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"minik8s/logger"
+	"minik8s/pkg/kubelet"
+	"minik8s/pkg/kubelet/config"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+/*
+	kubelet的入口
+	kubelet -config /etc/minik8s/kubelet.yaml [-node-name node1] [-log-level debug] ...
+	收到SIGINT或者SIGTERM之后停止所有的循环，等它们退出之后再刷新日志退出
+*/
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	fs := flag.NewFlagSet("kubelet", flag.ContinueOnError)
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintln(os.Stderr, "kubelet:", err)
+		return 2
+	}
+	if err := logger.Configure(cfg.Logging); err != nil {
+		fmt.Fprintln(os.Stderr, "kubelet: configure logger:", err)
+		return 2
+	}
+	log := logger.Named("kubelet")
+	defer log.Sync()
+
+	k, err := kubelet.NewKubelet(cfg)
+	if err != nil {
+		log.Errorln("create kubelet error: ", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	log.Infoln("kubelet starting on node ", cfg.NodeName)
+	if err := k.Run(ctx); err != nil {
+		log.Errorln("kubelet exited with error: ", err)
+		return 1
+	}
+	log.Infoln("kubelet stopped")
+	return 0
+}
@@ -0,0 +1,167 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"minik8s/logger"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+/*
+	kubelet的prometheus指标，名字尽量和k8s的kubelet保持一致
+	所有指标都注册在包内的Registry上，通过单独的端口以prometheus文本格式暴露在/metrics
+*/
+
+var (
+	K8sLogger = logger.Named("metrics")
+)
+
+const kubeletSubsystem = "kubelet"
+
+// 运行时操作的类型，作为operation_type标签
+const (
+	OperationCreateContainer = "create_container"
+	OperationStartContainer  = "start_container"
+	OperationRemoveContainer = "remove_container"
+	OperationPullImage       = "pull_image"
+)
+
+var (
+	RuntimeOperations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: kubeletSubsystem,
+			Name:      "runtime_operations_total",
+			Help:      "Cumulative number of runtime operations by operation type.",
+		},
+		[]string{"operation_type"},
+	)
+	RuntimeOperationsErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: kubeletSubsystem,
+			Name:      "runtime_operations_errors_total",
+			Help:      "Cumulative number of runtime operation errors by operation type.",
+		},
+		[]string{"operation_type"},
+	)
+	RuntimeOperationsDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: kubeletSubsystem,
+			Name:      "runtime_operations_duration_seconds",
+			Help:      "Duration in seconds of runtime operations. Broken down by operation type.",
+			Buckets:   prometheus.ExponentialBuckets(.005, 2.5, 14),
+		},
+		[]string{"operation_type"},
+	)
+	// 从kubelet第一次看到pod的spec到pod所有容器都启动的时间
+	PodStartDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Subsystem: kubeletSubsystem,
+			Name:      "pod_start_duration_seconds",
+			Help:      "Duration in seconds from kubelet seeing a pod for the first time to the pod starting to run.",
+			Buckets:   []float64{0.5, 1, 2, 3, 4, 5, 6, 8, 10, 20, 30, 45, 60, 120, 180, 240, 300, 360, 480, 600},
+		},
+	)
+	RunningPods = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Subsystem: kubeletSubsystem,
+			Name:      "running_pods",
+			Help:      "Number of pods that have a running pod sandbox.",
+		},
+	)
+	RunningContainers = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Subsystem: kubeletSubsystem,
+			Name:      "running_containers",
+			Help:      "Number of running containers, not including pod sandboxes.",
+		},
+	)
+	ImagePullBytes = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Subsystem: kubeletSubsystem,
+			Name:      "image_pull_bytes_total",
+			Help:      "Cumulative number of bytes of successfully pulled image layers.",
+		},
+	)
+	ImagePullFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Subsystem: kubeletSubsystem,
+			Name:      "image_pull_failures_total",
+			Help:      "Cumulative number of failed image pulls.",
+		},
+	)
+)
+
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(
+		RuntimeOperations,
+		RuntimeOperationsErrors,
+		RuntimeOperationsDuration,
+		PodStartDuration,
+		RunningPods,
+		RunningContainers,
+		ImagePullBytes,
+		ImagePullFailures,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// 注册其他组件自己实现的指标，比如容器的cpu和内存
+// 同一个进程里可能创建多个kubelet（比如测试），已经注册过相同指标的旧collector会被新的替换掉
+func Register(cs ...prometheus.Collector) error {
+	for _, c := range cs {
+		err := Registry.Register(c)
+		var registered prometheus.AlreadyRegisteredError
+		if errors.As(err, &registered) {
+			Registry.Unregister(registered.ExistingCollector)
+			err = Registry.Register(c)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 记录一次运行时操作，start是操作开始的时间，err不为nil表示操作失败
+func ObserveRuntimeOperation(operation string, start time.Time, err error) {
+	RuntimeOperations.WithLabelValues(operation).Inc()
+	RuntimeOperationsDuration.WithLabelValues(operation).Observe(SinceInSeconds(start))
+	if err != nil {
+		RuntimeOperationsErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+func SinceInSeconds(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// 在addr上暴露/metrics直到ctx结束
+func ListenAndServe(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	K8sLogger.Infoln("kubelet metrics listening on ", addr)
+	err := srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
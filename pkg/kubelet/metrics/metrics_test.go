@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func scrape(t *testing.T) string {
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestObserveRuntimeOperation(t *testing.T) {
+	ObserveRuntimeOperation(OperationStartContainer, time.Now().Add(-time.Second), nil)
+	ObserveRuntimeOperation(OperationStartContainer, time.Now(), errors.New("boom"))
+	ImagePullBytes.Add(1024)
+	RunningPods.Set(3)
+
+	body := scrape(t)
+	for _, want := range []string{
+		`kubelet_runtime_operations_total{operation_type="start_container"} 2`,
+		`kubelet_runtime_operations_errors_total{operation_type="start_container"} 1`,
+		`kubelet_runtime_operations_duration_seconds_count{operation_type="start_container"} 2`,
+		`kubelet_image_pull_bytes_total 1024`,
+		`kubelet_running_pods 3`,
+		`# TYPE kubelet_pod_start_duration_seconds histogram`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics output does not contain %q", want)
+		}
+	}
+}
+
+type constCollector struct {
+	value float64
+}
+
+var testGaugeDesc = prometheus.NewDesc("kubelet_test_value", "Value reported by the test collector.", nil, nil)
+
+func (c constCollector) Describe(ch chan<- *prometheus.Desc) { ch <- testGaugeDesc }
+
+func (c constCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(testGaugeDesc, prometheus.GaugeValue, c.value)
+}
+
+// 第二个kubelet注册相同的指标不能失败，而且之后暴露的是新的collector的数据
+func TestRegisterReplacesExistingCollector(t *testing.T) {
+	if err := Register(constCollector{value: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register(constCollector{value: 2}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Registry.Unregister(constCollector{}) })
+	body := scrape(t)
+	if !strings.Contains(body, "kubelet_test_value 2") || strings.Contains(body, "kubelet_test_value 1") {
+		t.Fatalf("expected the second collector to be exported, got:\n%s", body)
+	}
+}
@@ -0,0 +1,183 @@
+package kubelet
+
+import (
+	"context"
+	"fmt"
+	"minik8s/logger"
+	"minik8s/minik8sTypes"
+	"minik8s/pkg/apis"
+	"minik8s/pkg/kubelet/prober"
+	"time"
+)
+
+/*
+	pod的状态由kubelet在每次同步之后生成
+	容器的状态来自运行时，配置了readinessProbe的容器是否ready来自探测结果，
+	phase根据容器的状态和重启策略计算，参照k8s的getPhase，
+	condition根据init容器和容器的ready情况计算，没有变化的condition保留原来的时间
+*/
+
+// 根据docker中容器的状态更新pod的状态
+func (k *Kubelet) updatePodStatus(ctx context.Context, pod *apis.Pod) {
+	status, err := k.runtimeManager.GetPodStatus(ctx, pod)
+	if err != nil {
+		logger.WithPod(K8sLogger, pod.Namespace, pod.Name, pod.UID).Warnln("update pod status error: ", err)
+		return
+	}
+	k.probeManager.UpdatePod(pod, status.PodIP, status.ContainerStatuses)
+	// 成功退出的init容器算作就绪
+	for i := range status.InitContainerStatuses {
+		s := &status.InitContainerStatuses[i]
+		s.Ready = s.IsSucceeded()
+	}
+	// 没有就绪探针的容器运行起来就算就绪，有探针的以探针的结果为准
+	for i, c := range pod.Spec.Containers {
+		s := &status.ContainerStatuses[i]
+		s.Ready = s.State.Running != nil
+		if c.ReadinessProbe != nil {
+			s.Ready = false
+			if s.Started {
+				s.Ready, _ = k.probeManager.IsReady(s.ContainerID)
+			}
+		}
+	}
+	status.Phase = getPhase(&pod.Spec, status.InitContainerStatuses, status.ContainerStatuses)
+	status.UpdateTime = time.Now()
+	// 资源使用情况来自最近一次采样，和容器状态一起写进pod
+	if podStats, ok := k.statsProvider.GetPodStats(pod.UID); ok {
+		status.CpuPercent = podStats.CPU.Percent
+		status.MemPercent = podStats.Memory.Percent
+	}
+
+	k.podLock.Lock()
+	defer k.podLock.Unlock()
+	// handleSyncError标记为失败的pod不会再重试，之后的状态更新（比如探针结果变化）不能把phase改回去
+	if pod.Phase == apis.PodFailed {
+		status.Phase = apis.PodFailed
+	}
+	// 保留之前的condition（包括PodScheduled），在此基础上更新
+	status.Conditions = append([]apis.PodCondition(nil), pod.Conditions...)
+	generatePodConditions(&pod.Spec, &status)
+	pod.PodStatus = status
+}
+
+// 容器的就绪状态发生了变化，马上更新pod的状态，不用等下一次同步
+func (k *Kubelet) handleProbeUpdate(ctx context.Context, update prober.Update) {
+	k.podLock.RLock()
+	pod, ok := k.pods[update.PodUID]
+	k.podLock.RUnlock()
+	if !ok {
+		return
+	}
+	logger.WithPod(K8sLogger, pod.Namespace, pod.Name, pod.UID).Infoln("container ", update.ContainerID, " readiness changed to ", update.Ready)
+	k.updatePodStatus(ctx, pod)
+}
+
+// 根据容器的状态计算pod的phase
+func getPhase(spec *apis.PodSpec, initStatuses []apis.ContainerStatus, statuses []apis.ContainerStatus) apis.PodPhase {
+	// init容器没有全部成功之前pod是Pending，失败并且不会重试的时候pod失败
+	for _, s := range initStatuses {
+		if s.IsSucceeded() {
+			continue
+		}
+		if spec.RestartPolicy == minik8sTypes.Minik8sRestartPolicyNever && s.State.Terminated != nil {
+			return apis.PodFailed
+		}
+		return apis.PodPending
+	}
+	waiting, running, stopped, succeeded := 0, 0, 0, 0
+	for _, s := range statuses {
+		switch {
+		case s.State.Running != nil:
+			running++
+		case s.State.Terminated != nil:
+			stopped++
+			if s.State.Terminated.ExitCode == 0 {
+				succeeded++
+			}
+		case s.LastTerminationState.Terminated != nil:
+			// 退出之后正在重启
+			stopped++
+			if s.LastTerminationState.Terminated.ExitCode == 0 {
+				succeeded++
+			}
+		default:
+			waiting++
+		}
+	}
+	switch {
+	case waiting > 0:
+		return apis.PodPending
+	case running > 0:
+		return apis.PodRunning
+	case stopped > 0:
+		// 所有的容器都退出了，没有设置重启策略的时候和Always一样会被重启
+		switch spec.RestartPolicy {
+		case minik8sTypes.Minik8sRestartPolicyNever:
+			if stopped == succeeded {
+				return apis.PodSucceeded
+			}
+			return apis.PodFailed
+		case minik8sTypes.Minik8sRestartPolicyOnFailure:
+			if stopped == succeeded {
+				return apis.PodSucceeded
+			}
+			// 失败的容器会被重启
+			return apis.PodRunning
+		default:
+			return apis.PodRunning
+		}
+	default:
+		return apis.PodPending
+	}
+}
+
+// 在status原有的condition上更新PodScheduled、Initialized、ContainersReady和Ready，status的phase要先计算好
+func generatePodConditions(spec *apis.PodSpec, status *apis.PodStatus) {
+	// 现在还没有调度器，pod到了这个节点上就算调度完成，由kubelet设置，已经有的PodScheduled保持不变
+	if status.GetCondition(apis.PodScheduled) == nil {
+		status.SetCondition(apis.PodCondition{Type: apis.PodScheduled, Status: apis.ConditionTrue})
+	}
+
+	var incomplete []string
+	for i, c := range spec.InitContainers {
+		if i >= len(status.InitContainerStatuses) || !status.InitContainerStatuses[i].IsSucceeded() {
+			incomplete = append(incomplete, c.Name)
+		}
+	}
+	initialized := apis.PodCondition{Type: apis.PodInitialized, Status: apis.ConditionTrue}
+	if len(incomplete) > 0 {
+		initialized.Status = apis.ConditionFalse
+		initialized.Reason = apis.PodReasonContainersNotInit
+		initialized.Message = fmt.Sprintf("containers with incomplete status: %v", incomplete)
+	}
+	status.SetCondition(initialized)
+
+	var unready []string
+	for i, c := range spec.Containers {
+		if i >= len(status.ContainerStatuses) || !status.ContainerStatuses[i].Ready {
+			unready = append(unready, c.Name)
+		}
+	}
+	containersReady := apis.PodCondition{Type: apis.ContainersReady, Status: apis.ConditionTrue}
+	switch {
+	case initialized.Status == apis.ConditionFalse:
+		// init容器没有全部成功之前普通容器不会运行，也就不可能就绪
+		containersReady.Status = apis.ConditionFalse
+		containersReady.Reason = apis.PodReasonContainersNotInit
+		containersReady.Message = initialized.Message
+	case status.Phase == apis.PodSucceeded:
+		containersReady.Status = apis.ConditionFalse
+		containersReady.Reason = apis.PodReasonPodCompleted
+	case len(unready) > 0:
+		containersReady.Status = apis.ConditionFalse
+		containersReady.Reason = apis.PodReasonContainersNotReady
+		containersReady.Message = fmt.Sprintf("containers with unready status: %v", unready)
+	}
+	status.SetCondition(containersReady)
+
+	// 没有readiness gate，pod是否ready只取决于容器是否都ready
+	ready := containersReady
+	ready.Type = apis.PodReady
+	status.SetCondition(ready)
+}
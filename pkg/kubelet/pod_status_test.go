@@ -0,0 +1,163 @@
+package kubelet
+
+import (
+	"context"
+	"minik8s/minik8sTypes"
+	"minik8s/pkg/apis"
+	"minik8s/pkg/kubelet/prober"
+	"minik8s/pkg/kubelet/runtime"
+	"minik8s/pkg/kubelet/stats"
+	"testing"
+)
+
+func runningStatus() apis.ContainerStatus {
+	return apis.ContainerStatus{State: apis.ContainerState{Running: &apis.ContainerStateRunning{}}}
+}
+
+func terminatedStatus(exitCode int) apis.ContainerStatus {
+	return apis.ContainerStatus{State: apis.ContainerState{Terminated: &apis.ContainerStateTerminated{ExitCode: exitCode}}}
+}
+
+func waitingStatus(lastExitCode *int) apis.ContainerStatus {
+	s := apis.ContainerStatus{State: apis.ContainerState{Waiting: &apis.ContainerStateWaiting{Reason: apis.ContainerCreating}}}
+	if lastExitCode != nil {
+		s.LastTerminationState.Terminated = &apis.ContainerStateTerminated{ExitCode: *lastExitCode}
+	}
+	return s
+}
+
+func TestGetPhase(t *testing.T) {
+	one := 1
+	cases := []struct {
+		name          string
+		restartPolicy minik8sTypes.RestartPolicy
+		initStatuses  []apis.ContainerStatus
+		statuses      []apis.ContainerStatus
+		want          apis.PodPhase
+	}{
+		{"no containers", "", nil, nil, apis.PodPending},
+		{"creating", "", nil, []apis.ContainerStatus{runningStatus(), waitingStatus(nil)}, apis.PodPending},
+		{"all running", "", nil, []apis.ContainerStatus{runningStatus(), runningStatus()}, apis.PodRunning},
+		{"one exited", minik8sTypes.Minik8sRestartPolicyNever, nil, []apis.ContainerStatus{runningStatus(), terminatedStatus(1)}, apis.PodRunning},
+		{"restarting", "", nil, []apis.ContainerStatus{waitingStatus(&one)}, apis.PodRunning},
+		{"always", minik8sTypes.Minik8sRestartPolicyAlways, nil, []apis.ContainerStatus{terminatedStatus(0)}, apis.PodRunning},
+		{"never succeeded", minik8sTypes.Minik8sRestartPolicyNever, nil, []apis.ContainerStatus{terminatedStatus(0), terminatedStatus(0)}, apis.PodSucceeded},
+		{"never failed", minik8sTypes.Minik8sRestartPolicyNever, nil, []apis.ContainerStatus{terminatedStatus(0), terminatedStatus(2)}, apis.PodFailed},
+		{"on failure succeeded", minik8sTypes.Minik8sRestartPolicyOnFailure, nil, []apis.ContainerStatus{terminatedStatus(0)}, apis.PodSucceeded},
+		{"init running", "", []apis.ContainerStatus{runningStatus()}, []apis.ContainerStatus{waitingStatus(nil)}, apis.PodPending},
+		{"init failed never", minik8sTypes.Minik8sRestartPolicyNever, []apis.ContainerStatus{terminatedStatus(0), terminatedStatus(1)}, []apis.ContainerStatus{waitingStatus(nil)}, apis.PodFailed},
+		{"init failed restarting", minik8sTypes.Minik8sRestartPolicyOnFailure, []apis.ContainerStatus{terminatedStatus(1)}, []apis.ContainerStatus{waitingStatus(nil)}, apis.PodPending},
+		{"init done", "", []apis.ContainerStatus{terminatedStatus(0)}, []apis.ContainerStatus{runningStatus()}, apis.PodRunning},
+		{"on failure restarting", minik8sTypes.Minik8sRestartPolicyOnFailure, nil, []apis.ContainerStatus{terminatedStatus(137)}, apis.PodRunning},
+	}
+	for _, c := range cases {
+		spec := &apis.PodSpec{RestartPolicy: c.restartPolicy}
+		if got := getPhase(spec, c.initStatuses, c.statuses); got != c.want {
+			t.Errorf("%s: expected %s, got %s", c.name, c.want, got)
+		}
+	}
+}
+
+func TestGeneratePodConditions(t *testing.T) {
+	spec := &apis.PodSpec{
+		InitContainers: []apis.Container{{Name: "init"}},
+		Containers:     []apis.Container{{Name: "web"}, {Name: "sidecar"}},
+	}
+	notReady := runningStatus()
+	ready := runningStatus()
+	ready.Ready = true
+	status := &apis.PodStatus{
+		Phase:                 apis.PodRunning,
+		InitContainerStatuses: []apis.ContainerStatus{terminatedStatus(0)},
+		ContainerStatuses:     []apis.ContainerStatus{ready, notReady},
+	}
+	generatePodConditions(spec, status)
+	expect := func(conditionType apis.PodConditionType, want apis.ConditionStatus, reason string) *apis.PodCondition {
+		t.Helper()
+		c := status.GetCondition(conditionType)
+		if c == nil || c.Status != want || c.Reason != reason || c.LastTransitionTime.IsZero() {
+			t.Fatalf("unexpected %s condition %+v", conditionType, c)
+		}
+		return c
+	}
+	expect(apis.PodScheduled, apis.ConditionTrue, "")
+	expect(apis.PodInitialized, apis.ConditionTrue, "")
+	if c := expect(apis.ContainersReady, apis.ConditionFalse, apis.PodReasonContainersNotReady); c.Message != "containers with unready status: [sidecar]" {
+		t.Fatalf("unexpected message %q", c.Message)
+	}
+	readyCondition := *expect(apis.PodReady, apis.ConditionFalse, apis.PodReasonContainersNotReady)
+	initialized := *status.GetCondition(apis.PodInitialized)
+	if status.IsReady() {
+		t.Fatal("pod should not be ready")
+	}
+
+	// 状态没有变化的condition保留原来的时间
+	status.ContainerStatuses[1].Ready = true
+	generatePodConditions(spec, status)
+	if c := expect(apis.PodReady, apis.ConditionTrue, ""); c.LastTransitionTime.Before(readyCondition.LastTransitionTime) {
+		t.Fatalf("transition time went backwards %+v", c)
+	}
+	if c := status.GetCondition(apis.PodInitialized); !c.LastTransitionTime.Equal(initialized.LastTransitionTime) {
+		t.Fatalf("unchanged condition got a new transition time %+v", c)
+	}
+	if !status.IsReady() || len(status.Conditions) != 4 {
+		t.Fatalf("unexpected conditions %+v", status.Conditions)
+	}
+
+	// 已经有的PodScheduled不会被覆盖
+	scheduled := &apis.PodStatus{Phase: apis.PodSucceeded, ContainerStatuses: []apis.ContainerStatus{terminatedStatus(0), terminatedStatus(0)}}
+	scheduled.SetCondition(apis.PodCondition{Type: apis.PodScheduled, Status: apis.ConditionTrue, Reason: "Bound"})
+	generatePodConditions(&apis.PodSpec{Containers: spec.Containers}, scheduled)
+	if c := scheduled.GetCondition(apis.PodScheduled); c.Reason != "Bound" {
+		t.Fatalf("PodScheduled was overwritten %+v", c)
+	}
+	if c := scheduled.GetCondition(apis.PodReady); c.Status != apis.ConditionFalse || c.Reason != apis.PodReasonPodCompleted {
+		t.Fatalf("completed pod should not be ready %+v", c)
+	}
+
+	// init容器没有成功退出
+	pending := &apis.PodStatus{InitContainerStatuses: []apis.ContainerStatus{waitingStatus(nil)}}
+	generatePodConditions(spec, pending)
+	if c := pending.GetCondition(apis.PodInitialized); c.Status != apis.ConditionFalse || c.Message != "containers with incomplete status: [init]" {
+		t.Fatalf("unexpected Initialized condition %+v", c)
+	}
+	// init容器没有完成的时候pod不能就绪
+	for _, conditionType := range []apis.PodConditionType{apis.ContainersReady, apis.PodReady} {
+		if c := pending.GetCondition(conditionType); c.Status != apis.ConditionFalse || c.Reason != apis.PodReasonContainersNotInit {
+			t.Fatalf("unexpected %s condition %+v", conditionType, c)
+		}
+	}
+}
+
+// 只实现GetPodStatus的运行时，其他方法不会被调用
+type fakeStatusRuntime struct {
+	runtime.RuntimeManager
+	status apis.PodStatus
+}
+
+func (r *fakeStatusRuntime) GetPodStatus(ctx context.Context, pod *apis.Pod) (apis.PodStatus, error) {
+	return r.status, nil
+}
+
+// 同步失败被标记为Failed的pod，之后的状态更新不能把phase改回去
+func TestUpdatePodStatusKeepsFailed(t *testing.T) {
+	k := &Kubelet{
+		runtimeManager: &fakeStatusRuntime{status: apis.PodStatus{ContainerStatuses: []apis.ContainerStatus{runningStatus()}}},
+		statsProvider:  stats.NewProvider(nil, "node"),
+		probeManager:   prober.NewManager(),
+		pods:           map[string]*apis.Pod{},
+	}
+	pod := &apis.Pod{
+		ObjectMeta: apis.ObjectMeta{Name: "web", Namespace: "default", UID: "uid1"},
+		Spec:       apis.PodSpec{Containers: []apis.Container{{Name: "web"}}},
+	}
+	k.updatePodStatus(context.Background(), pod)
+	if pod.Phase != apis.PodRunning {
+		t.Fatalf("expected Running, got %s", pod.Phase)
+	}
+	pod.Phase = apis.PodFailed
+	k.updatePodStatus(context.Background(), pod)
+	if pod.Phase != apis.PodFailed {
+		t.Fatalf("expected Failed to be kept, got %s", pod.Phase)
+	}
+}
@@ -0,0 +1,119 @@
+package portforward
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+/*
+	客户端（命令行）这边的端口转发：在本地监听端口，每接受一个连接就在websocket上打开一个流
+*/
+
+// 本地端口到pod端口的映射
+type PortMapping struct {
+	Local  int
+	Remote int
+}
+
+// 解析 "8080:80" 或者 "80"（本地和pod用同一个端口）
+func ParsePortMapping(s string) (PortMapping, error) {
+	local, remote := s, s
+	for i := 0; i < len(s); i++ {
+		if s[i] == ':' {
+			local, remote = s[:i], s[i+1:]
+			break
+		}
+	}
+	l, err := strconv.Atoi(local)
+	if err != nil || l < 0 || l > 65535 {
+		return PortMapping{}, fmt.Errorf("invalid local port in %q", s)
+	}
+	r, err := strconv.Atoi(remote)
+	if err != nil || r <= 0 || r > 65535 {
+		return PortMapping{}, fmt.Errorf("invalid remote port in %q", s)
+	}
+	return PortMapping{Local: l, Remote: r}, nil
+}
+
+type Client struct {
+	s      *session
+	nextID uint32
+	// 远端报错（比如端口连不上）的时候写到这里
+	errOut io.Writer
+}
+
+// 连接kubelet的端口转发接口，url形如 ws://127.0.0.1:10250/portForward/default/nginx
+func Dial(ctx context.Context, url string, errOut io.Writer) (*Client, error) {
+	dialer := websocket.Dialer{ReadBufferSize: bufferSize, WriteBufferSize: bufferSize}
+	ws, resp, err := dialer.DialContext(ctx, url, nil)
+	if err != nil {
+		if resp != nil {
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			return nil, fmt.Errorf("%s: %s", resp.Status, body)
+		}
+		return nil, err
+	}
+	return &Client{s: newSession(ws), errOut: errOut}, nil
+}
+
+// 把一个本地连接转发到pod的端口
+func (c *Client) Forward(conn net.Conn, remotePort int) error {
+	id := atomic.AddUint32(&c.nextID, 1)
+	st, err := c.s.addStream(id)
+	if err != nil || !c.s.attach(st, conn) {
+		conn.Close()
+		return err
+	}
+	if err := c.s.writeFrame(frameOpen, id, []byte(strconv.Itoa(remotePort))); err != nil {
+		c.s.removeStream(st)
+		return err
+	}
+	go c.s.pump(st)
+	return nil
+}
+
+// 接受listener上的连接并且转发到pod的端口，listener关闭的时候返回
+func (c *Client) Serve(listener net.Listener, remotePort int) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		if err := c.Forward(conn, remotePort); err != nil {
+			return err
+		}
+	}
+}
+
+// 处理kubelet发来的帧，直到连接断开
+func (c *Client) Run() error {
+	defer c.s.close()
+	for {
+		t, id, payload, err := c.s.readFrame()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return nil
+			}
+			return err
+		}
+		if c.s.handleStreamFrame(t, id, payload) || t != frameError {
+			continue
+		}
+		fmt.Fprintf(c.errOut, "port forward stream %d error: %s\n", id, payload)
+		if st := c.s.getStream(id); st != nil {
+			c.s.removeStream(st)
+		}
+	}
+}
+
+func (c *Client) Close() error {
+	c.s.close()
+	return nil
+}
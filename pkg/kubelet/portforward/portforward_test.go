@@ -0,0 +1,243 @@
+package portforward
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 模拟pod中的服务：把收到的数据原样写回去，对端半关闭之后关闭连接
+func startEchoServer(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener
+}
+
+// 返回本地监听的地址，连接会被转发到pod的80端口，也就是echo服务
+func startPortForward(t *testing.T, errOut io.Writer) string {
+	echo := startEchoServer(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Serve(w, r, func(port int) (net.Conn, error) {
+			if port != 80 {
+				return nil, fmt.Errorf("connection refused")
+			}
+			return net.Dial("tcp", echo.Addr().String())
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := Dial(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http"), errOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	go client.Run()
+	return forwardLocal(t, client)
+}
+
+func forwardLocal(t *testing.T, client *Client) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go client.Serve(listener, 80)
+	return listener.Addr().String()
+}
+
+func TestPortForwardConcurrentStreams(t *testing.T) {
+	addr := startPortForward(t, io.Discard)
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+			msg := bytes.Repeat([]byte{byte('a' + i)}, 100*1024)
+			go func() {
+				conn.Write(msg)
+				// 半关闭之后echo服务才会结束，能读到完整的数据
+				conn.(*net.TCPConn).CloseWrite()
+			}()
+			got, err := io.ReadAll(conn)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if !bytes.Equal(got, msg) {
+				errs <- fmt.Errorf("stream %d: expected %d bytes echoed, got %d", i, len(msg), len(got))
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestPortForwardDialError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Serve(w, r, func(port int) (net.Conn, error) {
+			return nil, fmt.Errorf("connection refused")
+		})
+	}))
+	defer server.Close()
+	var errOut syncBuffer
+	client, err := Dial(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http"), &errOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	go client.Run()
+	addr := forwardLocal(t, client)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	// 远端连接失败之后本地连接会被关闭
+	if _, err := io.ReadAll(conn); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(errOut.String(), "connection refused") {
+		t.Fatalf("expected dial error to be reported, got %q", errOut.String())
+	}
+}
+
+// 一个端口连得很慢的时候不能卡住其他的流，连接建立之前发来的数据也不能丢
+func TestPortForwardSlowDial(t *testing.T) {
+	echo := startEchoServer(t)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Serve(w, r, func(port int) (net.Conn, error) {
+			if port == 81 {
+				<-release
+			}
+			return net.Dial("tcp", echo.Addr().String())
+		})
+	}))
+	defer server.Close()
+	client, err := Dial(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http"), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	go client.Run()
+
+	slowLocal, slowRemote := net.Pipe()
+	defer slowLocal.Close()
+	if err := client.Forward(slowRemote, 81); err != nil {
+		t.Fatal(err)
+	}
+	slowLocal.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := slowLocal.Write([]byte("early")); err != nil {
+		t.Fatal(err)
+	}
+
+	fastLocal, fastRemote := net.Pipe()
+	defer fastLocal.Close()
+	if err := client.Forward(fastRemote, 80); err != nil {
+		t.Fatal(err)
+	}
+	fastLocal.SetDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 4)
+	if _, err := fastLocal.Write([]byte("fast")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(fastLocal, buf); err != nil || string(buf) != "fast" {
+		t.Fatalf("expected fast stream to be echoed while another dial is pending, got %q, %v", buf, err)
+	}
+
+	close(release)
+	buf = make([]byte, 5)
+	if _, err := io.ReadFull(slowLocal, buf); err != nil || string(buf) != "early" {
+		t.Fatalf("expected data sent before the dial finished to be echoed, got %q, %v", buf, err)
+	}
+}
+
+func TestParsePortMapping(t *testing.T) {
+	cases := map[string]PortMapping{
+		"80":      {Local: 80, Remote: 80},
+		"8080:80": {Local: 8080, Remote: 80},
+		"0:80":    {Local: 0, Remote: 80},
+	}
+	for s, want := range cases {
+		got, err := ParsePortMapping(s)
+		if err != nil || got != want {
+			t.Fatalf("%s: expected %+v, got %+v (%v)", s, want, got, err)
+		}
+	}
+	for _, s := range []string{"", "a:80", "80:", "80:70000"} {
+		if _, err := ParsePortMapping(s); err == nil {
+			t.Fatalf("%s: expected error", s)
+		}
+	}
+}
+
+type syncBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
+
+func TestPortForwardRejectsBrowserOrigin(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Serve(w, r, func(port int) (net.Conn, error) {
+			return nil, fmt.Errorf("should not dial")
+		})
+	}))
+	defer server.Close()
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), http.Header{"Origin": {"http://evil.example.com"}})
+	if err == nil {
+		t.Fatal("expected the upgrade to be rejected")
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %v", resp)
+	}
+}
@@ -0,0 +1,240 @@
+package portforward
+
+import (
+	"encoding/binary"
+	"fmt"
+	"minik8s/logger"
+	"net"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+/*
+	端口转发的协议，跑在一条websocket上，一条websocket上可以同时有多个tcp连接（流）
+	每个websocket二进制消息是一帧：1个字节的类型 + 4个字节的流id（大端） + 数据
+	open   客户端 -> kubelet，数据是要连接的pod端口（十进制字符串）
+	data   双向，流上的数据
+	close  双向，发送方不会再写数据了（半关闭），两边都发过close之后流结束
+	error  kubelet -> 客户端，数据是错误信息（比如端口连不上），流直接结束
+*/
+
+var (
+	K8sLogger = logger.Named("portforward")
+)
+
+type frameType byte
+
+const (
+	frameOpen  frameType = 1
+	frameData  frameType = 2
+	frameClose frameType = 3
+	frameError frameType = 4
+
+	frameHeaderSize = 5
+	bufferSize      = 32 * 1024
+	// 连接建立之前一个流最多缓存多少数据，超过之后流直接结束
+	maxPendingBytes = 1024 * 1024
+)
+
+// 一条websocket上的所有流
+type session struct {
+	ws        *websocket.Conn
+	writeLock sync.Mutex
+
+	lock    sync.Mutex
+	streams map[uint32]*stream
+}
+
+// 一个流对应本地的一个tcp连接
+// 连接是异步建立的，建立之前对端发来的数据先缓存在pending里面
+type stream struct {
+	id uint32
+
+	lock         sync.Mutex
+	conn         net.Conn // 连接建立之前为nil
+	pending      [][]byte
+	pendingBytes int
+	localDone    bool // 本地连接已经读完，已经给对端发过close
+	remoteDone   bool // 对端发过close
+}
+
+func newSession(ws *websocket.Conn) *session {
+	return &session{ws: ws, streams: map[uint32]*stream{}}
+}
+
+func (s *session) writeFrame(t frameType, id uint32, payload []byte) error {
+	frame := make([]byte, frameHeaderSize, frameHeaderSize+len(payload))
+	frame[0] = byte(t)
+	binary.BigEndian.PutUint32(frame[1:], id)
+	frame = append(frame, payload...)
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	return s.ws.WriteMessage(websocket.BinaryMessage, frame)
+}
+
+func (s *session) readFrame() (frameType, uint32, []byte, error) {
+	for {
+		messageType, data, err := s.ws.ReadMessage()
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		if messageType != websocket.BinaryMessage || len(data) < frameHeaderSize {
+			continue
+		}
+		return frameType(data[0]), binary.BigEndian.Uint32(data[1:]), data[frameHeaderSize:], nil
+	}
+}
+
+func (s *session) addStream(id uint32) (*stream, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.streams[id]; ok {
+		return nil, fmt.Errorf("stream %d already exists", id)
+	}
+	st := &stream{id: id}
+	s.streams[id] = st
+	return st, nil
+}
+
+// 连接建立之后把缓存的数据写进去，对端已经发过close的话关闭写方向
+// 流已经被删除（比如会话结束了）的时候返回false，调用方负责关闭连接
+func (s *session) attach(st *stream, conn net.Conn) bool {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	if s.getStream(st.id) != st {
+		return false
+	}
+	st.conn = conn
+	for _, data := range st.pending {
+		if _, err := conn.Write(data); err != nil {
+			break
+		}
+	}
+	st.pending = nil
+	st.pendingBytes = 0
+	if st.remoteDone {
+		closeWrite(conn)
+	}
+	return true
+}
+
+func (s *session) getStream(id uint32) *stream {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.streams[id]
+}
+
+func (s *session) removeStream(st *stream) {
+	s.lock.Lock()
+	if s.streams[st.id] == st {
+		delete(s.streams, st.id)
+	}
+	s.lock.Unlock()
+	st.closeConn()
+}
+
+func (st *stream) closeConn() {
+	st.lock.Lock()
+	conn := st.conn
+	st.lock.Unlock()
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+func closeWrite(conn net.Conn) {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+	}
+}
+
+// 把本地连接读到的数据发给对端，读完之后发送close
+func (s *session) pump(st *stream) {
+	buf := make([]byte, bufferSize)
+	for {
+		n, err := st.conn.Read(buf)
+		if n > 0 {
+			if werr := s.writeFrame(frameData, st.id, buf[:n]); werr != nil {
+				s.removeStream(st)
+				return
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	if s.getStream(st.id) == nil {
+		// 已经因为对端的error或者会话结束被删除了
+		return
+	}
+	s.writeFrame(frameClose, st.id, nil)
+	st.lock.Lock()
+	st.localDone = true
+	done := st.remoteDone
+	st.lock.Unlock()
+	if done {
+		s.removeStream(st)
+	}
+}
+
+// 处理对端发来的data和close帧，其他类型返回false交给调用方处理
+func (s *session) handleStreamFrame(t frameType, id uint32, payload []byte) bool {
+	switch t {
+	case frameData:
+		st := s.getStream(id)
+		if st == nil {
+			return true
+		}
+		st.lock.Lock()
+		if st.conn == nil {
+			// 连接还没有建立，先缓存起来，payload是这一帧独占的，可以直接保存
+			st.pending = append(st.pending, payload)
+			st.pendingBytes += len(payload)
+			overflow := st.pendingBytes > maxPendingBytes
+			st.lock.Unlock()
+			if overflow {
+				s.writeFrame(frameError, id, []byte("too much data before the connection was established"))
+				s.removeStream(st)
+			}
+			return true
+		}
+		_, err := st.conn.Write(payload)
+		st.lock.Unlock()
+		if err != nil {
+			s.writeFrame(frameClose, id, nil)
+			s.removeStream(st)
+		}
+		return true
+	case frameClose:
+		st := s.getStream(id)
+		if st == nil {
+			return true
+		}
+		st.lock.Lock()
+		st.remoteDone = true
+		done := st.localDone
+		// 对端不会再发数据了，关闭本地连接的写方向，连接还没建立的话等建立之后再关
+		if st.conn != nil {
+			closeWrite(st.conn)
+		}
+		st.lock.Unlock()
+		if done {
+			s.removeStream(st)
+		}
+		return true
+	}
+	return false
+}
+
+// 会话结束的时候关闭所有的流
+func (s *session) close() {
+	s.lock.Lock()
+	streams := s.streams
+	s.streams = map[uint32]*stream{}
+	s.lock.Unlock()
+	for _, st := range streams {
+		st.closeConn()
+	}
+	s.ws.Close()
+}
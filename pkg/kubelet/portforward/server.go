@@ -0,0 +1,141 @@
+package portforward
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"minik8s/minik8sTypes"
+	containermanager "minik8s/pkg/kubelet/runtime/containerManager"
+	"minik8s/pkg/kubelet/streaming"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/gorilla/websocket"
+)
+
+/*
+	kubelet这边的端口转发
+	pod的端口只在pause容器上声明，没有映射到宿主机，但是宿主机可以直接访问pause容器的ip，
+	所以每个流都是从kubelet连接 podIP:port
+*/
+
+var (
+	ErrPodNotFound = errors.New("pod sandbox not found")
+	ErrNoPodIP     = errors.New("pod has no ip address")
+)
+
+const dialTimeout = 5 * time.Second
+
+// 连接pod中某个端口的函数
+type DialFunc func(port int) (net.Conn, error)
+
+type Forwarder struct {
+	cm *containermanager.ContainerManager
+}
+
+func NewForwarder(cm *containermanager.ContainerManager) *Forwarder {
+	return &Forwarder{cm: cm}
+}
+
+// 返回一个连接pod端口的函数，pod不存在或者没有ip的时候返回错误
+func (f *Forwarder) PodDialer(ctx context.Context, namespace, podName string) (DialFunc, error) {
+	ip, err := f.podIP(ctx, namespace, podName)
+	if err != nil {
+		return nil, err
+	}
+	return func(port int) (net.Conn, error) {
+		return net.DialTimeout("tcp", net.JoinHostPort(ip, strconv.Itoa(port)), dialTimeout)
+	}, nil
+}
+
+// pod的ip就是pause容器的ip
+func (f *Forwarder) podIP(ctx context.Context, namespace, podName string) (string, error) {
+	filter := filters.NewArgs()
+	filter.Add("label", minik8sTypes.KubernetesPodNameLabel+"="+podName)
+	filter.Add("label", minik8sTypes.KubernetesPodNamespaceLabel+"="+namespace)
+	filter.Add("label", minik8sTypes.Minik8sPodTypeLabel+"="+minik8sTypes.Minik8sPausePodType)
+	filter.Add("status", "running")
+	res, err := f.cm.ListContainerWithOpts(ctx, types.ContainerListOptions{Filters: filter})
+	if err != nil {
+		return "", err
+	}
+	if len(res) == 0 {
+		return "", fmt.Errorf("%w: %s/%s", ErrPodNotFound, namespace, podName)
+	}
+	inspect, err := f.cm.InspectContainer(ctx, res[0].ID)
+	if err != nil {
+		return "", err
+	}
+	if inspect.NetworkSettings != nil {
+		if inspect.NetworkSettings.IPAddress != "" {
+			return inspect.NetworkSettings.IPAddress, nil
+		}
+		for _, network := range inspect.NetworkSettings.Networks {
+			if network.IPAddress != "" {
+				return network.IPAddress, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: %s/%s", ErrNoPodIP, namespace, podName)
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  bufferSize,
+	WriteBufferSize: bufferSize,
+	// 和exec一样没有认证，拒绝浏览器发起的跨站请求，防止网页借用户的网络连进pod
+	CheckOrigin: streaming.NoBrowserOrigin,
+}
+
+// 升级成websocket之后处理客户端打开的所有流，直到客户端断开
+func Serve(w http.ResponseWriter, r *http.Request, dial DialFunc) {
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		K8sLogger.Errorln("upgrade to websocket error: ", err)
+		return
+	}
+	s := newSession(ws)
+	defer s.close()
+	for {
+		t, id, payload, err := s.readFrame()
+		if err != nil {
+			return
+		}
+		if s.handleStreamFrame(t, id, payload) || t != frameOpen {
+			continue
+		}
+		port, err := strconv.Atoi(string(payload))
+		if err != nil || port <= 0 || port > 65535 {
+			s.writeFrame(frameError, id, []byte(fmt.Sprintf("invalid port %q", payload)))
+			continue
+		}
+		// 先登记流，连接在后台建立，这样一个连不上的端口不会卡住其他流
+		st, err := s.addStream(id)
+		if err != nil {
+			s.writeFrame(frameError, id, []byte(err.Error()))
+			continue
+		}
+		go s.connect(st, port, dial)
+	}
+}
+
+// 连接pod的端口，连上之后开始转发，连不上的话告诉对端这个流失败了
+func (s *session) connect(st *stream, port int, dial DialFunc) {
+	conn, err := dial(port)
+	if err != nil {
+		K8sLogger.Warnln("port forward dial port ", port, " error: ", err)
+		if s.getStream(st.id) == st {
+			s.writeFrame(frameError, st.id, []byte(err.Error()))
+			s.removeStream(st)
+		}
+		return
+	}
+	if !s.attach(st, conn) {
+		conn.Close()
+		return
+	}
+	s.pump(st)
+}
@@ -0,0 +1,84 @@
+package prober
+
+import (
+	"context"
+	"fmt"
+	"minik8s/logger"
+	"minik8s/pkg/apis"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	就绪探测（readinessProbe）
+	每个配置了readinessProbe的运行中的容器有一个worker，按照periodSeconds定期探测，
+	连续成功successThreshold次之后ready，连续失败failureThreshold次之后不ready
+	容器的新实例从不ready重新开始探测
+	现在只支持httpGet，返回2xx、3xx算成功
+*/
+
+var (
+	K8sLogger = logger.Named("prober")
+)
+
+// 和k8s的默认值保持一致
+const (
+	DefaultPeriodSeconds    = 10
+	DefaultTimeoutSeconds   = 1
+	DefaultSuccessThreshold = 1
+	DefaultFailureThreshold = 3
+)
+
+// 按照probe的配置探测一次，host为空的时候使用pod的ip
+func runProbe(ctx context.Context, client *http.Client, probe *apis.Probe, podIP string) error {
+	action := probe.Handler.HttpGet
+	if action == nil {
+		return fmt.Errorf("probe has no supported handler")
+	}
+	timeout := time.Duration(probe.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = DefaultTimeoutSeconds * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, probeURL(action, podIP), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "minik8s-probe")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("HTTP probe failed with statuscode: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func probeURL(action *apis.HttpGetAction, podIP string) string {
+	scheme := strings.ToLower(action.Scheme)
+	if scheme == "" {
+		scheme = "http"
+	}
+	host := action.Host
+	if host == "" {
+		host = podIP
+	}
+	path := action.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	u := url.URL{
+		Scheme: scheme,
+		Host:   net.JoinHostPort(host, strconv.Itoa(int(action.Port))),
+		Path:   path,
+	}
+	return u.String()
+}
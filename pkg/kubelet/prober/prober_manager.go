@@ -0,0 +1,207 @@
+package prober
+
+import (
+	"context"
+	"minik8s/pkg/apis"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 某个容器的就绪状态发生了变化
+type Update struct {
+	PodUID      string
+	ContainerID string
+	Ready       bool
+}
+
+type workerKey struct {
+	podUID        string
+	containerName string
+}
+
+type Manager struct {
+	httpClient *http.Client
+
+	lock    sync.RWMutex
+	workers map[workerKey]*worker
+	// 容器id -> 是否ready，只记录有worker的容器
+	readiness map[string]bool
+
+	updates chan Update
+}
+
+func NewManager() *Manager {
+	return &Manager{
+		// 探测不复用连接，避免探测结果受到之前连接的影响
+		httpClient: &http.Client{Transport: &http.Transport{DisableKeepAlives: true}},
+		workers:    map[workerKey]*worker{},
+		readiness:  map[string]bool{},
+		updates:    make(chan Update, 64),
+	}
+}
+
+// 就绪状态的变化，kubelet收到之后更新对应pod的状态
+func (m *Manager) Updates() <-chan Update {
+	return m.updates
+}
+
+// 容器是否ready，ok为false表示这个容器没有在探测（没有配置readinessProbe或者没有运行）
+func (m *Manager) IsReady(containerID string) (ready bool, ok bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	ready, ok = m.readiness[containerID]
+	return
+}
+
+// 根据pod最新的容器状态启动或者停止探测
+// 运行中并且配置了readinessProbe的容器会有一个worker，容器换了新的实例之后worker也会重新开始
+func (m *Manager) UpdatePod(pod *apis.Pod, podIP string, statuses []apis.ContainerStatus) {
+	running := map[string]string{} // 容器名 -> 当前运行的实例id
+	for _, s := range statuses {
+		if s.State.Running != nil {
+			running[s.Name] = s.ContainerID
+		}
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, c := range pod.Spec.Containers {
+		key := workerKey{podUID: pod.UID, containerName: c.Name}
+		w, exists := m.workers[key]
+		containerID, isRunning := running[c.Name]
+		if exists && (!isRunning || w.containerID != containerID || c.ReadinessProbe == nil) {
+			m.removeWorkerLocked(key, w)
+			exists = false
+		}
+		if exists || !isRunning || c.ReadinessProbe == nil || podIP == "" {
+			continue
+		}
+		w = newWorker(m, pod.UID, containerID, c.ReadinessProbe, podIP)
+		m.workers[key] = w
+		m.readiness[containerID] = false
+		go w.run()
+	}
+}
+
+// pod被删除之后停止它所有的探测
+func (m *Manager) RemovePod(podUID string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for key, w := range m.workers {
+		if key.podUID == podUID {
+			m.removeWorkerLocked(key, w)
+		}
+	}
+}
+
+// 阻塞到ctx结束，然后停止所有的探测
+func (m *Manager) Run(ctx context.Context) {
+	<-ctx.Done()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for key, w := range m.workers {
+		m.removeWorkerLocked(key, w)
+	}
+}
+
+func (m *Manager) removeWorkerLocked(key workerKey, w *worker) {
+	w.cancel()
+	delete(m.workers, key)
+	delete(m.readiness, w.containerID)
+}
+
+// worker报告一次探测结果，状态有变化的时候通知kubelet
+func (m *Manager) setReadiness(w *worker, ready bool) {
+	m.lock.Lock()
+	old, ok := m.readiness[w.containerID]
+	if !ok || old == ready {
+		// worker已经被删掉了，或者没有变化
+		m.lock.Unlock()
+		return
+	}
+	m.readiness[w.containerID] = ready
+	m.lock.Unlock()
+	select {
+	case m.updates <- Update{PodUID: w.podUID, ContainerID: w.containerID, Ready: ready}:
+	default:
+		// kubelet处理不过来的时候丢掉，下一次定期同步的时候也会更新状态
+	}
+}
+
+type worker struct {
+	manager     *Manager
+	podUID      string
+	containerID string
+	probe       *apis.Probe
+	podIP       string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// 连续成功、失败的次数
+	successes int
+	failures  int
+}
+
+func newWorker(m *Manager, podUID string, containerID string, probe *apis.Probe, podIP string) *worker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &worker{
+		manager:     m,
+		podUID:      podUID,
+		containerID: containerID,
+		probe:       probe,
+		podIP:       podIP,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+func (w *worker) run() {
+	period := time.Duration(w.probe.PeriodSeconds) * time.Second
+	if period <= 0 {
+		period = DefaultPeriodSeconds * time.Second
+	}
+	select {
+	case <-time.After(time.Duration(w.probe.InitialDelaySeconds) * time.Second):
+	case <-w.ctx.Done():
+		return
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		w.doProbe()
+		select {
+		case <-ticker.C:
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *worker) doProbe() {
+	err := runProbe(w.ctx, w.manager.httpClient, w.probe, w.podIP)
+	if w.ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		K8sLogger.Debugln("readiness probe of container ", w.containerID, " failed: ", err)
+		w.successes = 0
+		w.failures++
+		if w.failures >= threshold(w.probe.FailureThreshold, DefaultFailureThreshold) {
+			w.manager.setReadiness(w, false)
+		}
+		return
+	}
+	w.failures = 0
+	w.successes++
+	if w.successes >= threshold(w.probe.SuccessThreshold, DefaultSuccessThreshold) {
+		w.manager.setReadiness(w, true)
+	}
+}
+
+func threshold(value int32, defaultValue int) int {
+	if value <= 0 {
+		return defaultValue
+	}
+	return int(value)
+}
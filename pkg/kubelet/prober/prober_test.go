@@ -0,0 +1,161 @@
+package prober
+
+import (
+	"context"
+	"minik8s/pkg/apis"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeApp struct {
+	status atomic.Int32
+}
+
+func (a *fakeApp) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/slow" {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	w.WriteHeader(int(a.status.Load()))
+}
+
+func newFakeApp(t *testing.T, status int) (*fakeApp, string, int32) {
+	app := &fakeApp{}
+	app.status.Store(int32(status))
+	server := httptest.NewServer(app)
+	t.Cleanup(server.Close)
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return app, host, int32(p)
+}
+
+func TestProbeURL(t *testing.T) {
+	cases := []struct {
+		action apis.HttpGetAction
+		want   string
+	}{
+		{apis.HttpGetAction{Path: "/healthz", Port: 8080}, "http://10.0.0.3:8080/healthz"},
+		{apis.HttpGetAction{Path: "ready", Port: 443, Scheme: "HTTPS", Host: "example.com"}, "https://example.com:443/ready"},
+	}
+	for _, c := range cases {
+		if got := probeURL(&c.action, "10.0.0.3"); got != c.want {
+			t.Errorf("expected %s, got %s", c.want, got)
+		}
+	}
+}
+
+func TestRunProbe(t *testing.T) {
+	app, host, port := newFakeApp(t, http.StatusOK)
+	client := &http.Client{}
+	probe := &apis.Probe{Handler: apis.Handler{HttpGet: &apis.HttpGetAction{Path: "/", Port: port}}}
+	if err := runProbe(context.Background(), client, probe, host); err != nil {
+		t.Fatal(err)
+	}
+	app.status.Store(http.StatusServiceUnavailable)
+	if err := runProbe(context.Background(), client, probe, host); err == nil {
+		t.Fatal("expected 503 to fail the probe")
+	}
+	app.status.Store(http.StatusOK)
+	probe.Handler.HttpGet.Path = "/slow"
+	start := time.Now()
+	if err := runProbe(context.Background(), client, probe, host); err == nil {
+		t.Fatal("expected the probe to time out")
+	}
+	if time.Since(start) > 1500*time.Millisecond {
+		t.Fatalf("probe did not respect the default timeout, took %v", time.Since(start))
+	}
+	if err := runProbe(context.Background(), client, &apis.Probe{}, host); err == nil {
+		t.Fatal("expected a probe without handler to fail")
+	}
+}
+
+func TestManagerReadiness(t *testing.T) {
+	_, host, port := newFakeApp(t, http.StatusOK)
+	m := NewManager()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+
+	pod := &apis.Pod{
+		ObjectMeta: apis.ObjectMeta{Name: "web", Namespace: "default", UID: "uid1"},
+		Spec: apis.PodSpec{Containers: []apis.Container{
+			{Name: "app", ReadinessProbe: &apis.Probe{Handler: apis.Handler{HttpGet: &apis.HttpGetAction{Path: "/", Port: port}}}},
+			{Name: "sidecar"},
+		}},
+	}
+	running := func(name, id string) apis.ContainerStatus {
+		return apis.ContainerStatus{Name: name, ContainerID: id, State: apis.ContainerState{Running: &apis.ContainerStateRunning{}}}
+	}
+	m.UpdatePod(pod, host, []apis.ContainerStatus{running("app", "docker://a1"), running("sidecar", "docker://s1")})
+	if ready, ok := m.IsReady("docker://a1"); !ok || ready {
+		t.Fatalf("container should start unready, got ready=%v ok=%v", ready, ok)
+	}
+	if _, ok := m.IsReady("docker://s1"); ok {
+		t.Fatal("container without readinessProbe should not be probed")
+	}
+
+	select {
+	case update := <-m.Updates():
+		if update.PodUID != "uid1" || update.ContainerID != "docker://a1" || !update.Ready {
+			t.Fatalf("unexpected update %+v", update)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no readiness update")
+	}
+	if ready, _ := m.IsReady("docker://a1"); !ready {
+		t.Fatal("container should be ready after a successful probe")
+	}
+
+	// 新的实例重新开始探测
+	m.UpdatePod(pod, host, []apis.ContainerStatus{running("app", "docker://a2")})
+	if _, ok := m.IsReady("docker://a1"); ok {
+		t.Fatal("old instance should not be probed any more")
+	}
+	if ready, ok := m.IsReady("docker://a2"); !ok || ready {
+		t.Fatalf("new instance should start unready, got ready=%v ok=%v", ready, ok)
+	}
+
+	m.RemovePod("uid1")
+	if _, ok := m.IsReady("docker://a2"); ok {
+		t.Fatal("probes should stop after the pod is removed")
+	}
+}
+
+func TestWorkerThresholds(t *testing.T) {
+	app, host, port := newFakeApp(t, http.StatusOK)
+	m := NewManager()
+	probe := &apis.Probe{
+		Handler:          apis.Handler{HttpGet: &apis.HttpGetAction{Path: "/", Port: port}},
+		SuccessThreshold: 2,
+		FailureThreshold: 2,
+	}
+	w := newWorker(m, "uid1", "docker://c1", probe, host)
+	defer w.cancel()
+	m.readiness[w.containerID] = false
+
+	w.doProbe()
+	if ready, _ := m.IsReady("docker://c1"); ready {
+		t.Fatal("one success is below the success threshold")
+	}
+	w.doProbe()
+	if ready, _ := m.IsReady("docker://c1"); !ready {
+		t.Fatal("expected ready after two successes")
+	}
+	app.status.Store(http.StatusInternalServerError)
+	w.doProbe()
+	if ready, _ := m.IsReady("docker://c1"); !ready {
+		t.Fatal("one failure is below the failure threshold")
+	}
+	w.doProbe()
+	if ready, _ := m.IsReady("docker://c1"); ready {
+		t.Fatal("expected unready after two failures")
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"io"
 	"minik8s/logger"
 	"minik8s/minik8sTypes"
+	"minik8s/pkg/kubelet/metrics"
+	runtimeerrors "minik8s/pkg/kubelet/runtime/runtimeErrors"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
@@ -19,7 +22,7 @@ import (
 */
 
 var (
-	K8sLogger = logger.K8sLogger
+	K8sLogger = logger.Named("container")
 )
 
 type ContainerManagerInterface interface {
@@ -31,27 +34,61 @@ type ContainerManagerInterface interface {
 	ListALlContainer(ctx context.Context) ([]types.Container, error)
 	InspectContainer(ctx context.Context, dockerID string) (types.ContainerJSON, error)
 	GetContainerLogs(ctx context.Context, dockerID string) (io.ReadCloser, error)
+	GetContainerLogsWithOpts(ctx context.Context, dockerID string, opts types.ContainerLogsOptions) (io.ReadCloser, error)
 	ContainerStats(ctx context.Context, dockerID string) (*types.StatsJSON, error)
 	RestartContainer(ctx context.Context, dockerID string) error
+	ExecCreate(ctx context.Context, dockerID string, config types.ExecConfig) (string, error)
+	ExecAttach(ctx context.Context, execID string, tty bool) (types.HijackedResponse, error)
+	ExecResize(ctx context.Context, execID string, height, width uint) error
+	ExecInspect(ctx context.Context, execID string) (types.ContainerExecInspect, error)
+	AttachContainer(ctx context.Context, dockerID string, stdin bool) (types.HijackedResponse, error)
+	ResizeContainer(ctx context.Context, dockerID string, height, width uint) error
+	StatContainerPath(ctx context.Context, dockerID string, path string) (types.ContainerPathStat, error)
+	CopyFromContainer(ctx context.Context, dockerID string, srcPath string) (io.ReadCloser, types.ContainerPathStat, error)
+	CopyToContainer(ctx context.Context, dockerID string, dstDir string, content io.Reader) error
 }
 
+// 单次docker请求（不包括日志、exec、attach这种流式的请求）默认的超时时间
+const DefaultRequestTimeout = 2 * time.Minute
+
 type ContainerManager struct {
-	client *client.Client
+	client         *client.Client
+	requestTimeout time.Duration
 }
 
 func NewContainerManager(c *client.Client) *ContainerManager {
 	return &ContainerManager{
-		client: c,
+		client:         c,
+		requestTimeout: DefaultRequestTimeout,
+	}
+}
+
+// 修改单次docker请求的超时时间，<=0表示不限制
+func (cm *ContainerManager) SetRequestTimeout(timeout time.Duration) {
+	cm.requestTimeout = timeout
+}
+
+// 给一次docker请求加上超时，调用者的ctx被取消的时候请求也会被取消
+func (cm *ContainerManager) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if cm.requestTimeout <= 0 {
+		return context.WithCancel(ctx)
 	}
+	return context.WithTimeout(ctx, cm.requestTimeout)
 }
 
 func (cm *ContainerManager) NewContainer(ctx context.Context, config *minik8sTypes.Config, hostConfig *minik8sTypes.HostConfig, containerName string) (dockerID string, err error) {
+	defer func(start time.Time) { metrics.ObserveRuntimeOperation(metrics.OperationCreateContainer, start, err) }(time.Now())
+	ctx, cancel := cm.withTimeout(ctx)
+	defer cancel()
 	//为了和在宿主机上跑的docker分开来，我们给label中加上一个标识
 	config.Labels[string(minik8sTypes.RunningSystemMinik8s)] = minik8sTypes.IsTrue
 	//创建一个容器
 	resp, err := cm.client.ContainerCreate(ctx,
 		&container.Config{
 			Tty:          config.Tty,
+			OpenStdin:    config.OpenStdin,
+			StdinOnce:    config.StdinOnce,
+			AttachStdin:  config.OpenStdin,
 			Env:          config.Env,
 			Cmd:          config.Cmd,
 			Entrypoint:   config.Entrypoint,
@@ -68,23 +105,32 @@ func (cm *ContainerManager) NewContainer(ctx context.Context, config *minik8sTyp
 			PidMode:      container.PidMode(hostConfig.PidMode),
 			IpcMode:      container.IpcMode(hostConfig.IpcMode),
 			Resources: container.Resources{
-				NanoCPUs: hostConfig.CPUResourceLimit,
-				Memory:   hostConfig.MemoryLimit,
+				NanoCPUs:     hostConfig.CPUResourceLimit,
+				Memory:       hostConfig.MemoryLimit,
+				CgroupParent: hostConfig.CgroupParent,
 			},
+			Sysctls:    hostConfig.Sysctls,
+			DNS:        hostConfig.DNS,
+			DNSSearch:  hostConfig.DNSSearch,
+			DNSOptions: hostConfig.DNSOptions,
+			ShmSize:    hostConfig.ShmSize,
 		}, nil, nil, containerName)
 	if err != nil {
 		K8sLogger.Error("NewContainer error: ", err)
-		return "", err
+		return "", runtimeerrors.FromDocker(err, "create container %s", containerName)
 	}
 	return resp.ID, nil
 }
 
 // 启动一个容器
-func (cm *ContainerManager) StartContainer(ctx context.Context, dockerID string) error {
-	err := cm.client.ContainerStart(ctx, dockerID, types.ContainerStartOptions{})
+func (cm *ContainerManager) StartContainer(ctx context.Context, dockerID string) (err error) {
+	defer func(start time.Time) { metrics.ObserveRuntimeOperation(metrics.OperationStartContainer, start, err) }(time.Now())
+	ctx, cancel := cm.withTimeout(ctx)
+	defer cancel()
+	err = cm.client.ContainerStart(ctx, dockerID, types.ContainerStartOptions{})
 	if err != nil {
 		K8sLogger.Error("StartContainer error: ", err)
-		return err
+		return runtimeerrors.FromDocker(err, "start container %s", dockerID)
 	}
 	return nil
 }
@@ -92,32 +138,39 @@ func (cm *ContainerManager) StartContainer(ctx context.Context, dockerID string)
 // 停止一个容器
 // stopOptions意思是停止容器的时候的一些选项，比如超时时间等
 func (cm *ContainerManager) StopContainer(ctx context.Context, dockerID string) error {
+	ctx, cancel := cm.withTimeout(ctx)
+	defer cancel()
 	err := cm.client.ContainerStop(ctx, dockerID, container.StopOptions{Timeout: nil})
 	if err != nil {
 		K8sLogger.Error("StopContainer error: ", err)
-		return err
+		return runtimeerrors.FromDocker(err, "stop container %s", dockerID)
 	}
 	return nil
 }
 
 // 删除一个容器
-func (cm *ContainerManager) RemoveContainer(ctx context.Context, dockerID string) error {
+func (cm *ContainerManager) RemoveContainer(ctx context.Context, dockerID string) (err error) {
+	defer func(start time.Time) { metrics.ObserveRuntimeOperation(metrics.OperationRemoveContainer, start, err) }(time.Now())
+	ctx, cancel := cm.withTimeout(ctx)
+	defer cancel()
 	//先暂停
-	err := cm.StopContainer(ctx, dockerID)
+	err = cm.StopContainer(ctx, dockerID)
 	if err != nil {
 		K8sLogger.Error("RemoveContainer error: ", err)
-		return err
+		return runtimeerrors.FromDocker(err, "remove container %s", dockerID)
 	}
 	err = cm.client.ContainerRemove(ctx, dockerID, types.ContainerRemoveOptions{Force: true})
 	if err != nil {
 		K8sLogger.Error("RemoveContainer error: ", err)
-		return err
+		return runtimeerrors.FromDocker(err, "remove container %s", dockerID)
 	}
 	return nil
 }
 
 // 获取所有minik8s容器的信息
 func (cm *ContainerManager) ListMinik8sContainer(ctx context.Context) ([]types.Container, error) {
+	ctx, cancel := cm.withTimeout(ctx)
+	defer cancel()
 	filter := filters.NewArgs()
 	filter.Add("label", string(minik8sTypes.RunningSystemMinik8s)+"="+minik8sTypes.IsTrue)
 	c, err := cm.client.ContainerList(ctx, types.ContainerListOptions{
@@ -126,26 +179,30 @@ func (cm *ContainerManager) ListMinik8sContainer(ctx context.Context) ([]types.C
 	})
 	if err != nil {
 		K8sLogger.Error("ListContainer error: ", err)
-		return nil, err
+		return nil, runtimeerrors.FromDocker(err, "list containers")
 	}
 	return c, nil
 }
 
 func (cm *ContainerManager) ListALlContainer(ctx context.Context) ([]types.Container, error) {
+	ctx, cancel := cm.withTimeout(ctx)
+	defer cancel()
 	c, err := cm.client.ContainerList(ctx, types.ContainerListOptions{})
 	if err != nil {
 		K8sLogger.Error("ListContainer error: ", err)
-		return nil, err
+		return nil, runtimeerrors.FromDocker(err, "list containers")
 	}
 	return c, nil
 }
 
 // 获取一个容器的状态(是否运行、退出码等)
 func (cm *ContainerManager) InspectContainer(ctx context.Context, dockerID string) (types.ContainerJSON, error) {
+	ctx, cancel := cm.withTimeout(ctx)
+	defer cancel()
 	cj, err := cm.client.ContainerInspect(ctx, dockerID)
 	if err != nil {
 		K8sLogger.Error("InspectContainer error: ", err)
-		return types.ContainerJSON{}, err
+		return types.ContainerJSON{}, runtimeerrors.FromDocker(err, "inspect container %s", dockerID)
 	}
 	return cj, nil
 }
@@ -155,17 +212,30 @@ func (cm *ContainerManager) GetContainerLogs(ctx context.Context, dockerID strin
 	rc, err := cm.client.ContainerLogs(ctx, dockerID, types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true})
 	if err != nil {
 		K8sLogger.Error("GetContainerLogs error: ", err)
-		return nil, err
+		return nil, runtimeerrors.FromDocker(err, "get logs of container %s", dockerID)
+	}
+	return rc, nil
+}
+
+// 按照选项获取一个容器的日志（follow、tail、since、timestamps等）
+// 注意：没有开tty的容器返回的是stdout和stderr复用在一起的流，需要用stdcopy拆开
+func (cm *ContainerManager) GetContainerLogsWithOpts(ctx context.Context, dockerID string, opts types.ContainerLogsOptions) (io.ReadCloser, error) {
+	rc, err := cm.client.ContainerLogs(ctx, dockerID, opts)
+	if err != nil {
+		K8sLogger.Error("GetContainerLogs error: ", err)
+		return nil, runtimeerrors.FromDocker(err, "get logs of container %s", dockerID)
 	}
 	return rc, nil
 }
 
 // 获取容器状态（cpu、内存、网络等）
 func (cm *ContainerManager) ContainerStats(ctx context.Context, dockerID string) (*types.StatsJSON, error) {
+	ctx, cancel := cm.withTimeout(ctx)
+	defer cancel()
 	rc, err := cm.client.ContainerStats(ctx, dockerID, false)
 	if err != nil {
 		K8sLogger.Error("GetContainerStats error: ", err)
-		return nil, err
+		return nil, runtimeerrors.FromDocker(err, "get stats of container %s", dockerID)
 	}
 	defer rc.Body.Close()
 
@@ -173,26 +243,30 @@ func (cm *ContainerManager) ContainerStats(ctx context.Context, dockerID string)
 	statsInfo := &types.StatsJSON{}
 	err = decoder.Decode(statsInfo)
 	if err != nil {
-		return nil, err
+		return nil, runtimeerrors.FromDocker(err, "decode stats of container %s", dockerID)
 	}
 	return statsInfo, nil
 }
 
 // 重启一个容器
 func (cm *ContainerManager) RestartContainer(ctx context.Context, dockerID string) error {
+	ctx, cancel := cm.withTimeout(ctx)
+	defer cancel()
 	err := cm.client.ContainerRestart(ctx, dockerID, container.StopOptions{Timeout: nil})
 	if err != nil {
 		K8sLogger.Error("RestartContainer error: ", err)
-		return err
+		return runtimeerrors.FromDocker(err, "restart container %s", dockerID)
 	}
 	return nil
 }
 
 func (cm *ContainerManager) ListContainerWithOpts(ctx context.Context, opts types.ContainerListOptions) ([]types.Container, error) {
+	ctx, cancel := cm.withTimeout(ctx)
+	defer cancel()
 	c, err := cm.client.ContainerList(ctx, opts)
 	if err != nil {
 		K8sLogger.Error("ListContainer error: ", err)
-		return nil, err
+		return nil, runtimeerrors.FromDocker(err, "list containers")
 	}
 	return c, nil
 }
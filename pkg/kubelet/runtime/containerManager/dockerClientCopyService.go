@@ -0,0 +1,47 @@
+package containermanager
+
+import (
+	"context"
+	"io"
+	runtimeerrors "minik8s/pkg/kubelet/runtime/runtimeErrors"
+
+	"github.com/docker/docker/api/types"
+)
+
+/*
+	这个文件是对docker拷贝文件接口的封装，传输的内容都是tar流
+	CopyFromContainer返回的tar包里的根目录名是源路径的最后一段
+	CopyToContainer要求目标路径是容器中已经存在的目录，tar包会解压到这个目录下
+*/
+
+// 获取容器中一个路径的信息（是否存在、是否是目录等）
+func (cm *ContainerManager) StatContainerPath(ctx context.Context, dockerID string, path string) (types.ContainerPathStat, error) {
+	ctx, cancel := cm.withTimeout(ctx)
+	defer cancel()
+	stat, err := cm.client.ContainerStatPath(ctx, dockerID, path)
+	if err != nil {
+		K8sLogger.Error("StatContainerPath error: ", err)
+		return types.ContainerPathStat{}, runtimeerrors.FromDocker(err, "stat %s in container %s", path, dockerID)
+	}
+	return stat, nil
+}
+
+// 把容器中的一个文件或者目录打成tar包读出来
+func (cm *ContainerManager) CopyFromContainer(ctx context.Context, dockerID string, srcPath string) (io.ReadCloser, types.ContainerPathStat, error) {
+	rc, stat, err := cm.client.CopyFromContainer(ctx, dockerID, srcPath)
+	if err != nil {
+		K8sLogger.Error("CopyFromContainer error: ", err)
+		return nil, types.ContainerPathStat{}, runtimeerrors.FromDocker(err, "copy %s from container %s", srcPath, dockerID)
+	}
+	return rc, stat, nil
+}
+
+// 把tar包解压到容器中的dstDir目录下
+func (cm *ContainerManager) CopyToContainer(ctx context.Context, dockerID string, dstDir string, content io.Reader) error {
+	err := cm.client.CopyToContainer(ctx, dockerID, dstDir, content, types.CopyToContainerOptions{})
+	if err != nil {
+		K8sLogger.Error("CopyToContainer error: ", err)
+		return runtimeerrors.FromDocker(err, "copy to %s in container %s", dstDir, dockerID)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package containermanager
+
+import (
+	"context"
+	runtimeerrors "minik8s/pkg/kubelet/runtime/runtimeErrors"
+
+	"github.com/docker/docker/api/types"
+)
+
+/*
+	这个文件是对docker exec和attach的封装
+	返回的HijackedResponse是一个双向的连接，写进去的是容器的stdin，读出来的是容器的输出
+	没有开tty的时候输出是stdout和stderr复用在一起的流，需要用stdcopy拆开
+*/
+
+// 在容器中创建一个exec实例，返回exec id
+func (cm *ContainerManager) ExecCreate(ctx context.Context, dockerID string, config types.ExecConfig) (string, error) {
+	ctx, cancel := cm.withTimeout(ctx)
+	defer cancel()
+	resp, err := cm.client.ContainerExecCreate(ctx, dockerID, config)
+	if err != nil {
+		K8sLogger.Error("ExecCreate error: ", err)
+		return "", runtimeerrors.FromDocker(err, "create exec in container %s", dockerID)
+	}
+	return resp.ID, nil
+}
+
+// 启动exec实例并且连接到它的输入输出
+func (cm *ContainerManager) ExecAttach(ctx context.Context, execID string, tty bool) (types.HijackedResponse, error) {
+	hr, err := cm.client.ContainerExecAttach(ctx, execID, types.ExecStartCheck{Tty: tty})
+	if err != nil {
+		K8sLogger.Error("ExecAttach error: ", err)
+		return types.HijackedResponse{}, runtimeerrors.FromDocker(err, "attach exec %s", execID)
+	}
+	return hr, nil
+}
+
+// 修改exec实例的终端大小
+func (cm *ContainerManager) ExecResize(ctx context.Context, execID string, height, width uint) error {
+	ctx, cancel := cm.withTimeout(ctx)
+	defer cancel()
+	err := cm.client.ContainerExecResize(ctx, execID, types.ResizeOptions{Height: height, Width: width})
+	if err != nil {
+		K8sLogger.Error("ExecResize error: ", err)
+		return runtimeerrors.FromDocker(err, "resize exec %s", execID)
+	}
+	return nil
+}
+
+// 获取exec实例的状态，结束之后可以拿到退出码
+func (cm *ContainerManager) ExecInspect(ctx context.Context, execID string) (types.ContainerExecInspect, error) {
+	ctx, cancel := cm.withTimeout(ctx)
+	defer cancel()
+	inspect, err := cm.client.ContainerExecInspect(ctx, execID)
+	if err != nil {
+		K8sLogger.Error("ExecInspect error: ", err)
+		return types.ContainerExecInspect{}, runtimeerrors.FromDocker(err, "inspect exec %s", execID)
+	}
+	return inspect, nil
+}
+
+// 连接到容器主进程的输入输出，stdin为true的时候容器需要开启OpenStdin
+func (cm *ContainerManager) AttachContainer(ctx context.Context, dockerID string, stdin bool) (types.HijackedResponse, error) {
+	hr, err := cm.client.ContainerAttach(ctx, dockerID, types.ContainerAttachOptions{
+		Stream: true,
+		Stdin:  stdin,
+		Stdout: true,
+		Stderr: true,
+	})
+	if err != nil {
+		K8sLogger.Error("AttachContainer error: ", err)
+		return types.HijackedResponse{}, runtimeerrors.FromDocker(err, "attach container %s", dockerID)
+	}
+	return hr, nil
+}
+
+// 修改容器主进程的终端大小
+func (cm *ContainerManager) ResizeContainer(ctx context.Context, dockerID string, height, width uint) error {
+	ctx, cancel := cm.withTimeout(ctx)
+	defer cancel()
+	err := cm.client.ContainerResize(ctx, dockerID, types.ResizeOptions{Height: height, Width: width})
+	if err != nil {
+		K8sLogger.Error("ResizeContainer error: ", err)
+		return runtimeerrors.FromDocker(err, "resize container %s", dockerID)
+	}
+	return nil
+}
@@ -3,7 +3,11 @@ package containermanager
 import (
 	"minik8s/minik8sTypes"
 	"minik8s/pkg/apis"
+	runtimeerrors "minik8s/pkg/kubelet/runtime/runtimeErrors"
+	"sort"
+	"strconv"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/go-connections/nat"
 )
 
@@ -22,9 +26,26 @@ func MakeContainerMapper(container *apis.Container, portsetInPod *nat.PortSet) e
 		p, err := nat.NewPort(containerNetwork.Protocol, containerNetwork.ContainerPort)
 		if err != nil {
 			K8sLogger.Errorln("MakeContainerMapper error: ", err)
-			return err
+			return runtimeerrors.New(runtimeerrors.ErrInvalidSpec, err, "invalid port %s of container %s", containerNetwork.ContainerPort, container.Name)
 		}
 		(*portsetInPod)[p] = struct{}{}
 	}
 	return nil
 }
+
+// 容器标签上的attempt，老版本创建的容器没有这个标签，当作0
+func ContainerAttempt(labels map[string]string) int {
+	attempt, err := strconv.Atoi(labels[minik8sTypes.KubernetesContainerAttemptLabel])
+	if err != nil {
+		return 0
+	}
+	return attempt
+}
+
+// 把同一个容器的实例按照attempt从新到旧排序
+// docker的创建时间只精确到秒，同一秒创建的两个实例用创建时间分不出新旧
+func SortByAttempt(containers []types.Container) {
+	sort.SliceStable(containers, func(i, j int) bool {
+		return ContainerAttempt(containers[i].Labels) > ContainerAttempt(containers[j].Labels)
+	})
+}
@@ -0,0 +1,45 @@
+package containermanager
+
+import (
+	"context"
+	"errors"
+	"minik8s/pkg/kubelet/dockerClient/dockertest"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newSlowContainerManager(t *testing.T, delay time.Duration) *ContainerManager {
+	c := dockertest.NewClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.Write([]byte("[]"))
+	}))
+	return NewContainerManager(c)
+}
+
+func TestRequestTimeout(t *testing.T) {
+	cm := newSlowContainerManager(t, time.Second)
+	cm.SetRequestTimeout(50 * time.Millisecond)
+	start := time.Now()
+	_, err := cm.ListMinik8sContainer(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("request was not cut off by the timeout, took %v", time.Since(start))
+	}
+}
+
+func TestRequestCancelledByCaller(t *testing.T) {
+	cm := newSlowContainerManager(t, time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err := cm.ListMinik8sContainer(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled, got %v", err)
+	}
+}
@@ -2,19 +2,20 @@ package imagemanager
 
 import (
 	"context"
-	"errors"
-	"io"
 	"minik8s/logger"
 	"minik8s/minik8sTypes"
-	"os"
+	"minik8s/pkg/kubelet/metrics"
+	runtimeerrors "minik8s/pkg/kubelet/runtime/runtimeErrors"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/registry"
 	"github.com/docker/docker/client"
 )
 
 var (
-	K8sLogger = logger.K8sLogger
+	K8sLogger = logger.Named("image")
 )
 
 // https://blog.csdn.net/zhonglinzhang/article/details/80697614 image——api的增删改查
@@ -28,63 +29,134 @@ func NewImageManager(dc *client.Client) *ImageManager {
 }
 
 func (im *ImageManager) PullImage(ctx context.Context, imagePullPolicy minik8sTypes.ImagePullPolicyType, imageName string) error {
+	return im.PullImageWithHandler(ctx, imagePullPolicy, imageName, nil, nil)
+}
+
+// 按照拉取策略拉取镜像，拉取过程中的事件会回调handler
+// auths是私有仓库的认证信息，会按顺序尝试，为空表示匿名拉取
+func (im *ImageManager) PullImageWithHandler(ctx context.Context, imagePullPolicy minik8sTypes.ImagePullPolicyType, imageName string, auths []registry.AuthConfig, handler PullEventHandler) error {
 	switch imagePullPolicy {
 	case minik8sTypes.Always:
 		// Always pull image
 		// 拉取镜像
-		image, err := im.dc.ImagePull(ctx, imageName, types.ImagePullOptions{})
-		// println(imageRef)
-		if err != nil {
-			K8sLogger.Error("PullImage error: ", err)
-			return err
-		}
-		io.Copy(os.Stdout, image)
-		defer image.Close()
-		return nil
+		return im.pullImage(ctx, imageName, auths, handler)
 	case minik8sTypes.IfNotPresent:
 		// IfNotPresent pull image
 		// 检查镜像是否存在，不存在则拉取
-		images, err := im.dc.ImageList(ctx, types.ImageListOptions{
-			Filters: filters.NewArgs(filters.Arg("reference", imageName)),
-		})
+		present, err := im.imagePresent(ctx, imageName)
 		if err != nil {
 			K8sLogger.Error("PullImage error: ", err)
 			return err
 		}
-		if len(images) == 0 {
-			image, err := im.dc.ImagePull(ctx, imageName, types.ImagePullOptions{})
-			if err != nil {
-				K8sLogger.Error("PullImage error: ", err)
-				return err
-			}
-			io.Copy(os.Stdout, image)
-			defer image.Close()
+		if !present {
+			return im.pullImage(ctx, imageName, auths, handler)
+		}
+		if handler != nil {
+			handler(PullEvent{Type: PullEventAlreadyPresent, Image: imageName})
 		}
 		return nil
 	case minik8sTypes.Never:
 		// Never pull image
 		// 检查镜像是否存在，不存在则报错
-		images, err := im.dc.ImageList(ctx, types.ImageListOptions{
-			Filters: filters.NewArgs(filters.Arg("reference", imageName)),
-		})
+		present, err := im.imagePresent(ctx, imageName)
 		if err != nil {
 			K8sLogger.Error("PullImage error: ", err)
 			return err
 		}
-		if len(images) == 0 {
-			K8sLogger.Error("PullImage error: ", errors.New("image not found"))
-			return errors.New("image not found")
+		if !present {
+			err := runtimeerrors.NotFoundf("image %q not found and the pull policy is Never", imageName)
+			K8sLogger.Error("PullImage error: ", err)
+			if handler != nil {
+				handler(PullEvent{Type: PullEventFailed, Image: imageName, Err: err})
+			}
+			return err
+		}
+		if handler != nil {
+			handler(PullEvent{Type: PullEventAlreadyPresent, Image: imageName})
 		}
 		return nil
 	}
 	return nil
 }
 
+func (im *ImageManager) imagePresent(ctx context.Context, imageName string) (bool, error) {
+	images, err := im.dc.ImageList(ctx, types.ImageListOptions{
+		Filters: filters.NewArgs(filters.Arg("reference", imageName)),
+	})
+	if err != nil {
+		return false, runtimeerrors.FromDocker(err, "list image %s", imageName)
+	}
+	return len(images) > 0, nil
+}
+
+// 依次用每个认证信息拉取镜像，有一个成功就返回
+// 单个认证信息失败只记日志，所有认证信息都失败之后才通知一次拉取失败
+func (im *ImageManager) pullImage(ctx context.Context, imageName string, auths []registry.AuthConfig, handler PullEventHandler) (err error) {
+	defer func(start time.Time) {
+		metrics.ObserveRuntimeOperation(metrics.OperationPullImage, start, err)
+		if err != nil {
+			metrics.ImagePullFailures.Inc()
+		}
+	}(time.Now())
+	if len(auths) == 0 {
+		return im.pullImageWithAuth(ctx, imageName, "", handler)
+	}
+	var attemptHandler PullEventHandler
+	if handler != nil {
+		attemptHandler = func(event PullEvent) {
+			if event.Type != PullEventFailed {
+				handler(event)
+			}
+		}
+	}
+	for _, auth := range auths {
+		encoded, encodeErr := registry.EncodeAuthConfig(auth)
+		if encodeErr != nil {
+			K8sLogger.Error("PullImage error: ", encodeErr)
+			err = runtimeerrors.ImagePullFailed(imageName, encodeErr)
+			continue
+		}
+		err = im.pullImageWithAuth(ctx, imageName, encoded, attemptHandler)
+		if err == nil {
+			return nil
+		}
+		K8sLogger.Warnf("pull image %s with credentials for %s failed: %v", imageName, auth.ServerAddress, err)
+	}
+	if handler != nil {
+		handler(PullEvent{Type: PullEventFailed, Image: imageName, Err: err})
+	}
+	return err
+}
+
+// 拉取镜像并解析拉取的消息流，流中的错误也会被当作拉取失败
+func (im *ImageManager) pullImageWithAuth(ctx context.Context, imageName string, registryAuth string, handler PullEventHandler) error {
+	image, err := im.dc.ImagePull(ctx, imageName, types.ImagePullOptions{RegistryAuth: registryAuth})
+	if err != nil {
+		err = runtimeerrors.ImagePullFailed(imageName, err)
+		K8sLogger.Error("PullImage error: ", err)
+		if handler != nil {
+			handler(PullEvent{Type: PullEventFailed, Image: imageName, Err: err})
+		}
+		return err
+	}
+	defer image.Close()
+	progress, err := decodePullStream(image, imageName, handler)
+	if err != nil {
+		// 流中的错误已经带着镜像名字了
+		err = runtimeerrors.New(runtimeerrors.ErrImagePullFailed, err, "")
+		K8sLogger.Error("PullImage error: ", err)
+		return err
+	}
+	K8sLogger.Infof("pulled image %s: %d layers, %d bytes in %v", imageName, progress.Layers, progress.BytesTotal, progress.Duration)
+	metrics.ImagePullBytes.Add(float64(progress.BytesTotal))
+	return nil
+}
+
 func (im *ImageManager) RemoveImage(ctx context.Context, imageName string) error {
 	_, err := im.dc.ImageRemove(ctx, imageName, types.ImageRemoveOptions{})
 	if err != nil {
 		K8sLogger.Error("RemoveImage error: ", err)
-		return err
+		return runtimeerrors.FromDocker(err, "remove image %s", imageName)
 	}
 	return nil
 }
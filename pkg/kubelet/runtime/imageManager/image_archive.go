@@ -0,0 +1,161 @@
+package imagemanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	runtimeerrors "minik8s/pkg/kubelet/runtime/runtimeErrors"
+
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+/*
+	离线镜像：从docker save导出的tar包中加载镜像，或者把镜像导出成tar包
+	没有镜像仓库的机器上，可以在kubelet启动的时候从一个目录中预加载所有镜像（包括pause镜像），
+	这样拉取策略为Never的pod也可以正常启动
+*/
+
+const loadedImagePrefix = "Loaded image: "
+const loadedImageIDPrefix = "Loaded image ID: "
+
+// 从一个tar包中加载镜像，返回加载的镜像名字（没有tag的镜像返回id）
+func (im *ImageManager) LoadImage(ctx context.Context, path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		K8sLogger.Error("LoadImage error: ", err)
+		return nil, err
+	}
+	defer f.Close()
+	resp, err := im.dc.ImageLoad(ctx, f, true)
+	if err != nil {
+		K8sLogger.Error("LoadImage error: ", err)
+		return nil, runtimeerrors.FromDocker(err, "load image archive %s", path)
+	}
+	defer resp.Body.Close()
+	images, err := decodeLoadStream(resp.Body)
+	if err != nil {
+		K8sLogger.Error("LoadImage error: ", err)
+		return nil, fmt.Errorf("load image archive %s: %w", path, err)
+	}
+	return images, nil
+}
+
+// 加载目录下所有的镜像tar包（.tar .tar.gz .tgz），某个包加载失败不影响其他包
+func (im *ImageManager) LoadImagesFromDir(ctx context.Context, dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if strings.HasSuffix(name, ".tar") || strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var loaded []string
+	var errs []error
+	for _, name := range names {
+		images, err := im.LoadImage(ctx, filepath.Join(dir, name))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		K8sLogger.Infof("loaded images %v from %s", images, name)
+		loaded = append(loaded, images...)
+	}
+	return loaded, errors.Join(errs...)
+}
+
+// 把镜像导出成一个tar包，先写临时文件，成功之后再rename
+func (im *ImageManager) SaveImages(ctx context.Context, images []string, path string) error {
+	rc, err := im.dc.ImageSave(ctx, images)
+	if err != nil {
+		K8sLogger.Error("SaveImages error: ", err)
+		return runtimeerrors.FromDocker(err, "save images %v", images)
+	}
+	defer rc.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		K8sLogger.Error("SaveImages error: ", err)
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, rc); err != nil {
+		tmp.Close()
+		K8sLogger.Error("SaveImages error: ", err)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// kubelet启动时的预加载：先加载目录中的所有镜像，然后检查required中的镜像是否都在本地了
+// 这样即使没有镜像仓库，拉取策略为Never/IfNotPresent的镜像（比如pause镜像）也可以直接使用
+func (im *ImageManager) PreloadImages(ctx context.Context, dir string, required []string) error {
+	loaded, loadErr := im.LoadImagesFromDir(ctx, dir)
+	if loadErr != nil {
+		K8sLogger.Error("PreloadImages error: ", loadErr)
+	}
+	K8sLogger.Infof("preloaded %d images from %s", len(loaded), dir)
+	var missing []string
+	for _, image := range required {
+		present, err := im.imagePresent(ctx, image)
+		if err != nil {
+			return err
+		}
+		if !present {
+			missing = append(missing, image)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Join(loadErr, fmt.Errorf("images %v are not present after preloading from %s", missing, dir))
+	}
+	return loadErr
+}
+
+// docker load返回的也是json消息流，比如 {"stream":"Loaded image: nginx:latest\n"}
+func decodeLoadStream(stream io.Reader) ([]string, error) {
+	var images []string
+	decoder := json.NewDecoder(stream)
+	for {
+		var msg jsonmessage.JSONMessage
+		err := decoder.Decode(&msg)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return images, err
+		}
+		if msg.Error != nil {
+			return images, errors.New(msg.Error.Message)
+		}
+		if msg.ErrorMessage != "" {
+			return images, errors.New(msg.ErrorMessage)
+		}
+		line := strings.TrimSpace(msg.Stream)
+		switch {
+		case strings.HasPrefix(line, loadedImageIDPrefix):
+			images = append(images, strings.TrimPrefix(line, loadedImageIDPrefix))
+		case strings.HasPrefix(line, loadedImagePrefix):
+			images = append(images, strings.TrimPrefix(line, loadedImagePrefix))
+		}
+	}
+	return images, nil
+}
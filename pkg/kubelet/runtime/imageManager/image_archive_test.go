@@ -0,0 +1,106 @@
+package imagemanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+// 模拟docker load/save：tar包的内容就是镜像名字
+type fakeArchiveDaemon struct {
+	lock   sync.Mutex
+	images map[string]bool
+}
+
+func (d *fakeArchiveDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/images/load"):
+		data, _ := io.ReadAll(r.Body)
+		name := strings.TrimSpace(string(data))
+		w.Header().Set("Content-Type", "application/json")
+		if name == "corrupted" {
+			fmt.Fprintln(w, `{"errorDetail":{"message":"unexpected EOF"},"error":"unexpected EOF"}`)
+			return
+		}
+		d.images[name] = true
+		json.NewEncoder(w).Encode(map[string]string{"stream": "Loaded image: " + name + "\n"})
+	case strings.HasSuffix(r.URL.Path, "/images/get"):
+		w.Write([]byte(strings.Join(r.URL.Query()["names"], ",")))
+	case strings.HasSuffix(r.URL.Path, "/images/json"):
+		var filterArg struct {
+			Reference map[string]bool `json:"reference"`
+		}
+		json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filterArg)
+		var list []types.ImageSummary
+		for ref := range filterArg.Reference {
+			if d.images[ref] {
+				list = append(list, types.ImageSummary{ID: ref, RepoTags: []string{ref}})
+			}
+		}
+		json.NewEncoder(w).Encode(list)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestPreloadImages(t *testing.T) {
+	daemon := &fakeArchiveDaemon{images: map[string]bool{}}
+	im := NewImageManager(newFakeDockerClient(t, daemon.ServeHTTP))
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "pause.tar"), []byte("k8s.gcr.io/pause:3.1"), 0644)
+	os.WriteFile(filepath.Join(dir, "nginx.tar.gz"), []byte("nginx:latest"), 0644)
+	os.WriteFile(filepath.Join(dir, "README"), []byte("not an image"), 0644)
+
+	if err := im.PreloadImages(context.Background(), dir, []string{"k8s.gcr.io/pause:3.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if !daemon.images["nginx:latest"] || !daemon.images["k8s.gcr.io/pause:3.1"] {
+		t.Fatalf("images not loaded: %v", daemon.images)
+	}
+	err := im.PreloadImages(context.Background(), dir, []string{"redis:latest"})
+	if err == nil || !strings.Contains(err.Error(), "redis:latest") {
+		t.Fatalf("expected missing image error, got %v", err)
+	}
+}
+
+func TestLoadImagesFromDirReportsErrors(t *testing.T) {
+	daemon := &fakeArchiveDaemon{images: map[string]bool{}}
+	im := NewImageManager(newFakeDockerClient(t, daemon.ServeHTTP))
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "bad.tar"), []byte("corrupted"), 0644)
+	os.WriteFile(filepath.Join(dir, "good.tar"), []byte("busybox:latest"), 0644)
+	loaded, err := im.LoadImagesFromDir(context.Background(), dir)
+	if err == nil || !strings.Contains(err.Error(), "bad.tar") {
+		t.Fatalf("expected error for bad.tar, got %v", err)
+	}
+	if len(loaded) != 1 || loaded[0] != "busybox:latest" {
+		t.Fatalf("unexpected loaded images: %v", loaded)
+	}
+}
+
+func TestSaveImages(t *testing.T) {
+	daemon := &fakeArchiveDaemon{images: map[string]bool{}}
+	im := NewImageManager(newFakeDockerClient(t, daemon.ServeHTTP))
+	path := filepath.Join(t.TempDir(), "images.tar")
+	if err := im.SaveImages(context.Background(), []string{"nginx:latest", "redis:latest"}, path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "nginx:latest,redis:latest" {
+		t.Fatalf("unexpected archive content %q", data)
+	}
+}
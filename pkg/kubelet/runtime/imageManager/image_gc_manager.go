@@ -0,0 +1,259 @@
+package imagemanager
+
+import (
+	"context"
+	"fmt"
+	"minik8s/minik8sTypes"
+	runtimeerrors "minik8s/pkg/kubelet/runtime/runtimeErrors"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+)
+
+/*
+	镜像垃圾回收
+	记录minik8s容器用到的镜像最后一次被使用的时间，
+	当镜像所在文件系统的使用率超过高水位的时候，按照最后使用时间从旧到新删除没有被使用的镜像，
+	直到使用率降到低水位以下。pause镜像和正在被容器使用的镜像永远不会被删除
+*/
+
+type ImageGCPolicy struct {
+	// 磁盘使用率超过这个百分比就开始回收
+	HighThresholdPercent int
+	// 回收到磁盘使用率低于这个百分比为止
+	LowThresholdPercent int
+	// 镜像至少要被发现这么久之后才能被回收，避免刚拉下来还没来得及创建容器的镜像被删掉
+	MinAge time.Duration
+}
+
+type imageRecord struct {
+	firstDetected time.Time
+	lastUsed      time.Time // 为零表示kubelet启动以来没有minik8s容器用过它
+	created       time.Time
+	size          int64
+	repoTags      []string
+}
+
+type ImageGCManager struct {
+	im         *ImageManager
+	policy     ImageGCPolicy
+	pauseImage string
+
+	lock         sync.Mutex
+	imageRecords map[string]*imageRecord // 镜像id -> 记录
+
+	// 镜像所在文件系统的容量和剩余空间，方便测试替换
+	imageFsStats func(ctx context.Context) (capacity uint64, available uint64, err error)
+	now          func() time.Time
+}
+
+func NewImageGCManager(im *ImageManager, policy ImageGCPolicy, pauseImage string) (*ImageGCManager, error) {
+	if policy.HighThresholdPercent < 0 || policy.HighThresholdPercent > 100 {
+		return nil, fmt.Errorf("invalid HighThresholdPercent %d, must be in range [0-100]", policy.HighThresholdPercent)
+	}
+	if policy.LowThresholdPercent < 0 || policy.LowThresholdPercent > 100 {
+		return nil, fmt.Errorf("invalid LowThresholdPercent %d, must be in range [0-100]", policy.LowThresholdPercent)
+	}
+	if policy.LowThresholdPercent > policy.HighThresholdPercent {
+		return nil, fmt.Errorf("LowThresholdPercent %d can not be higher than HighThresholdPercent %d", policy.LowThresholdPercent, policy.HighThresholdPercent)
+	}
+	m := &ImageGCManager{
+		im:           im,
+		policy:       policy,
+		pauseImage:   pauseImage,
+		imageRecords: map[string]*imageRecord{},
+		now:          time.Now,
+	}
+	m.imageFsStats = m.dockerRootFsStats
+	return m, nil
+}
+
+// 定期检测镜像的使用情况并在需要的时候回收
+func (m *ImageGCManager) Start(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		if err := m.GarbageCollect(ctx); err != nil {
+			K8sLogger.Errorln("image garbage collection error: ", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// 更新镜像记录，返回正在被容器使用的镜像id
+func (m *ImageGCManager) detectImages(ctx context.Context) (map[string]bool, error) {
+	images, err := m.im.dc.ImageList(ctx, types.ImageListOptions{})
+	if err != nil {
+		return nil, runtimeerrors.FromDocker(err, "list images")
+	}
+	// 所有的容器（包括不是minik8s创建的）用到的镜像都不能删
+	containers, err := m.im.dc.ContainerList(ctx, types.ContainerListOptions{All: true})
+	if err != nil {
+		return nil, runtimeerrors.FromDocker(err, "list containers")
+	}
+	minik8sContainers, err := m.im.dc.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", string(minik8sTypes.RunningSystemMinik8s)+"="+minik8sTypes.IsTrue)),
+	})
+	if err != nil {
+		return nil, runtimeerrors.FromDocker(err, "list containers")
+	}
+	inUse := map[string]bool{}
+	for _, c := range containers {
+		inUse[c.ImageID] = true
+	}
+
+	now := m.now()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	current := map[string]bool{}
+	for _, image := range images {
+		current[image.ID] = true
+		record, ok := m.imageRecords[image.ID]
+		if !ok {
+			record = &imageRecord{firstDetected: now}
+			m.imageRecords[image.ID] = record
+		}
+		record.created = time.Unix(image.Created, 0)
+		record.size = image.Size
+		record.repoTags = image.RepoTags
+	}
+	for _, c := range minik8sContainers {
+		if record, ok := m.imageRecords[c.ImageID]; ok {
+			record.lastUsed = now
+		}
+	}
+	// 已经不存在的镜像的记录也删掉
+	for id := range m.imageRecords {
+		if !current[id] {
+			delete(m.imageRecords, id)
+		}
+	}
+	return inUse, nil
+}
+
+// 检查磁盘使用率，超过高水位就回收到低水位以下
+func (m *ImageGCManager) GarbageCollect(ctx context.Context) error {
+	capacity, available, err := m.imageFsStats(ctx)
+	if err != nil {
+		return err
+	}
+	if capacity == 0 {
+		return fmt.Errorf("invalid image filesystem capacity 0")
+	}
+	usagePercent := int(100 - available*100/capacity)
+	if usagePercent < m.policy.HighThresholdPercent {
+		// 不需要回收，但是还是要更新镜像的使用时间
+		_, err := m.detectImages(ctx)
+		return err
+	}
+	amountToFree := int64(capacity)*int64(100-m.policy.LowThresholdPercent)/100 - int64(available)
+	K8sLogger.Infof("image filesystem usage %d%% is over the high threshold %d%%, trying to free %d bytes",
+		usagePercent, m.policy.HighThresholdPercent, amountToFree)
+	freed, err := m.freeSpace(ctx, amountToFree)
+	if err != nil {
+		return err
+	}
+	if freed < amountToFree {
+		return fmt.Errorf("failed to garbage collect required amount of images, wanted to free %d bytes, but freed %d bytes", amountToFree, freed)
+	}
+	return nil
+}
+
+type evictionCandidate struct {
+	id     string
+	record imageRecord
+}
+
+// 按照最后使用时间从旧到新删除没有在使用的镜像，直到释放了bytesToFree字节
+func (m *ImageGCManager) freeSpace(ctx context.Context, bytesToFree int64) (int64, error) {
+	inUse, err := m.detectImages(ctx)
+	if err != nil {
+		return 0, err
+	}
+	now := m.now()
+	m.lock.Lock()
+	var candidates []evictionCandidate
+	for id, record := range m.imageRecords {
+		if inUse[id] || m.isPauseImage(record.repoTags) {
+			continue
+		}
+		if now.Sub(record.firstDetected) < m.policy.MinAge {
+			continue
+		}
+		candidates = append(candidates, evictionCandidate{id: id, record: *record})
+	}
+	m.lock.Unlock()
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i].record, candidates[j].record
+		if !a.lastUsed.Equal(b.lastUsed) {
+			return a.lastUsed.Before(b.lastUsed)
+		}
+		return a.created.Before(b.created)
+	})
+
+	var freed int64
+	var lastErr error
+	for _, candidate := range candidates {
+		if freed >= bytesToFree {
+			break
+		}
+		K8sLogger.Infof("removing image %s %v to free %d bytes", candidate.id, candidate.record.repoTags, candidate.record.size)
+		// 一个镜像可能有多个tag，要用force才能按id删除；正在使用的镜像前面已经排除了
+		_, err := m.im.dc.ImageRemove(ctx, candidate.id, types.ImageRemoveOptions{Force: true, PruneChildren: true})
+		if err != nil {
+			K8sLogger.Errorln("remove image ", candidate.id, " error: ", err)
+			lastErr = runtimeerrors.FromDocker(err, "remove image %s", candidate.id)
+			continue
+		}
+		m.lock.Lock()
+		delete(m.imageRecords, candidate.id)
+		m.lock.Unlock()
+		freed += candidate.record.size
+	}
+	if freed < bytesToFree && lastErr != nil {
+		return freed, lastErr
+	}
+	return freed, nil
+}
+
+func (m *ImageGCManager) isPauseImage(repoTags []string) bool {
+	pause := normalizeImageRef(m.pauseImage)
+	for _, tag := range repoTags {
+		if normalizeImageRef(tag) == pause {
+			return true
+		}
+	}
+	return false
+}
+
+// nginx、docker.io/library/nginx:latest这些写法都是同一个镜像
+func normalizeImageRef(image string) string {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return image
+	}
+	return reference.TagNameOnly(named).String()
+}
+
+// docker数据目录所在文件系统的容量和剩余空间
+func (m *ImageGCManager) dockerRootFsStats(ctx context.Context) (uint64, uint64, error) {
+	info, err := m.im.dc.Info(ctx)
+	if err != nil {
+		return 0, 0, runtimeerrors.FromDocker(err, "get docker info")
+	}
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(info.DockerRootDir, &st); err != nil {
+		return 0, 0, err
+	}
+	return st.Blocks * uint64(st.Bsize), st.Bavail * uint64(st.Bsize), nil
+}
@@ -0,0 +1,110 @@
+package imagemanager
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+type fakeImageDaemon struct {
+	lock       sync.Mutex
+	images     []types.ImageSummary
+	containers []types.Container // 不是minik8s创建的容器
+	minik8s    []types.Container // minik8s创建的容器
+	removed    []string
+}
+
+func (d *fakeImageDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/images/json"):
+		json.NewEncoder(w).Encode(d.images)
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/containers/json"):
+		if strings.Contains(r.URL.Query().Get("filters"), "minik8s") {
+			json.NewEncoder(w).Encode(d.minik8s)
+			return
+		}
+		json.NewEncoder(w).Encode(append(append([]types.Container{}, d.containers...), d.minik8s...))
+	case r.Method == http.MethodDelete && strings.Contains(r.URL.Path, "/images/"):
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/images/")+len("/images/"):]
+		d.removed = append(d.removed, id)
+		var kept []types.ImageSummary
+		for _, image := range d.images {
+			if image.ID != id {
+				kept = append(kept, image)
+			}
+		}
+		d.images = kept
+		w.Write([]byte("[]"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestImageGCFreesOldestUnusedImages(t *testing.T) {
+	daemon := &fakeImageDaemon{
+		images: []types.ImageSummary{
+			{ID: "sha256:pause", RepoTags: []string{"k8s.gcr.io/pause:3.1"}, Size: 100, Created: 1},
+			{ID: "sha256:used", RepoTags: []string{"nginx:latest"}, Size: 100, Created: 2},
+			{ID: "sha256:old", RepoTags: []string{"busybox:latest"}, Size: 100, Created: 3},
+			{ID: "sha256:recent", RepoTags: []string{"redis:latest"}, Size: 100, Created: 4},
+			{ID: "sha256:newest", RepoTags: []string{"alpine:latest"}, Size: 100, Created: 5},
+		},
+		minik8s: []types.Container{{ID: "c1", ImageID: "sha256:used"}},
+	}
+	im := NewImageManager(newFakeDockerClient(t, daemon.ServeHTTP))
+	m, err := NewImageGCManager(im, ImageGCPolicy{HighThresholdPercent: 90, LowThresholdPercent: 80}, "k8s.gcr.io/pause:3.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	// redis最近被minik8s的容器用过，比从来没用过的busybox和alpine要晚删
+	daemon.minik8s = append(daemon.minik8s, types.Container{ID: "c2", ImageID: "sha256:recent"})
+	if _, err := m.detectImages(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	daemon.minik8s = daemon.minik8s[:1]
+	now = now.Add(time.Minute)
+
+	// 容量1000，剩余50，使用率95%，需要回收到80%，也就是释放150字节
+	m.imageFsStats = func(ctx context.Context) (uint64, uint64, error) { return 1000, 50, nil }
+	if err := m.GarbageCollect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"sha256:old", "sha256:newest"}
+	if len(daemon.removed) != len(want) || daemon.removed[0] != want[0] || daemon.removed[1] != want[1] {
+		t.Fatalf("expected %v removed, got %v", want, daemon.removed)
+	}
+}
+
+func TestImageGCUnderHighThreshold(t *testing.T) {
+	daemon := &fakeImageDaemon{
+		images: []types.ImageSummary{{ID: "sha256:old", RepoTags: []string{"busybox:latest"}, Size: 100}},
+	}
+	m, err := NewImageGCManager(NewImageManager(newFakeDockerClient(t, daemon.ServeHTTP)), ImageGCPolicy{HighThresholdPercent: 90, LowThresholdPercent: 80}, "k8s.gcr.io/pause:3.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.imageFsStats = func(ctx context.Context) (uint64, uint64, error) { return 1000, 500, nil }
+	if err := m.GarbageCollect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(daemon.removed) != 0 {
+		t.Fatalf("expected no image removed, got %v", daemon.removed)
+	}
+}
+
+func TestNewImageGCManagerValidatesThresholds(t *testing.T) {
+	if _, err := NewImageGCManager(nil, ImageGCPolicy{HighThresholdPercent: 70, LowThresholdPercent: 80}, ""); err == nil {
+		t.Fatal("expected error for low threshold above high threshold")
+	}
+}
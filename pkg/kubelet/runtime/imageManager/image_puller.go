@@ -0,0 +1,165 @@
+package imagemanager
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"minik8s/minik8sTypes"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/registry"
+)
+
+/*
+	ImagePuller在ImageManager外面包了一层：
+	1. 同一个镜像同时只会拉取一次，后来的调用者等待正在进行的拉取结果，不管它们的拉取策略是什么
+	2. 限制同时拉取的镜像数量
+	3. 拉取失败的镜像会进入指数退避（ImagePullBackOff），退避期间直接返回错误，不会去拉取
+	退避和去重都按照镜像和认证信息区分，换了认证信息（比如修好了secret）的拉取不受之前失败的影响
+	Never策略不会拉取镜像，只检查镜像是否存在，既不参与去重也不会进入退避
+*/
+
+var ErrImagePullBackOff = errors.New("ImagePullBackOff")
+
+const (
+	DefaultImagePullBackOffInitial = 10 * time.Second
+	DefaultImagePullBackOffMax     = 5 * time.Minute
+)
+
+// 一次正在进行的拉取
+type pullCall struct {
+	done chan struct{}
+	err  error
+}
+
+type backOffEntry struct {
+	backOff  time.Duration // 当前的退避时间
+	nextPull time.Time     // 在这个时间之前不会再拉取
+	lastErr  error
+}
+
+type ImagePuller struct {
+	im          *ImageManager
+	pullTimeout time.Duration
+	// 令牌，为nil表示不限制并发
+	tokens chan struct{}
+
+	initialBackOff time.Duration
+	maxBackOff     time.Duration
+
+	lock     sync.Mutex
+	inflight map[string]*pullCall
+	backOffs map[string]*backOffEntry
+
+	// 方便测试替换
+	now func() time.Time
+}
+
+// maxParallel <= 0 表示不限制同时拉取的数量，pullTimeout <= 0 表示不设置拉取超时
+func NewImagePuller(im *ImageManager, maxParallel int, pullTimeout time.Duration, initialBackOff time.Duration, maxBackOff time.Duration) *ImagePuller {
+	p := &ImagePuller{
+		im:             im,
+		pullTimeout:    pullTimeout,
+		initialBackOff: initialBackOff,
+		maxBackOff:     maxBackOff,
+		inflight:       map[string]*pullCall{},
+		backOffs:       map[string]*backOffEntry{},
+		now:            time.Now,
+	}
+	if maxParallel > 0 {
+		p.tokens = make(chan struct{}, maxParallel)
+	}
+	return p
+}
+
+// 保证镜像按照拉取策略就绪
+// ctx只控制调用者等待的时间，真正的拉取使用puller自己的超时，这样一个调用者放弃等待不会打断其他调用者共享的拉取
+// handler只有发起拉取的那个调用者才会收到
+func (p *ImagePuller) EnsureImage(ctx context.Context, imagePullPolicy minik8sTypes.ImagePullPolicyType, imageName string, auths []registry.AuthConfig, handler PullEventHandler) error {
+	if imagePullPolicy == minik8sTypes.Never {
+		// 镜像不存在不是拉取失败，不能影响其他策略的拉取
+		return p.im.PullImageWithHandler(ctx, imagePullPolicy, imageName, auths, handler)
+	}
+	key := imageName + "/" + credentialKey(auths)
+	p.lock.Lock()
+	if entry, ok := p.backOffs[key]; ok && p.now().Before(entry.nextPull) {
+		p.lock.Unlock()
+		return fmt.Errorf("%w: back-off %v pulling image %q: %w", ErrImagePullBackOff, entry.backOff, imageName, entry.lastErr)
+	}
+	call, ok := p.inflight[key]
+	if !ok {
+		call = &pullCall{done: make(chan struct{})}
+		p.inflight[key] = call
+		go p.doPull(key, call, imagePullPolicy, imageName, auths, handler)
+	}
+	p.lock.Unlock()
+
+	select {
+	case <-call.done:
+		return call.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 认证信息的摘要，只用来区分不同的认证信息，不会把密码放进key里面
+func credentialKey(auths []registry.AuthConfig) string {
+	if len(auths) == 0 {
+		return "anonymous"
+	}
+	h := sha256.New()
+	for _, auth := range auths {
+		for _, field := range []string{auth.ServerAddress, auth.Username, auth.Password, auth.Auth, auth.IdentityToken, auth.RegistryToken} {
+			h.Write([]byte(field))
+			h.Write([]byte{0})
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+func (p *ImagePuller) doPull(key string, call *pullCall, imagePullPolicy minik8sTypes.ImagePullPolicyType, imageName string, auths []registry.AuthConfig, handler PullEventHandler) {
+	// 拿到令牌之后才开始计算超时，排队等待的时间不算在拉取时间里面
+	if p.tokens != nil {
+		p.tokens <- struct{}{}
+	}
+	ctx := context.Background()
+	var cancel context.CancelFunc = func() {}
+	if p.pullTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, p.pullTimeout)
+	}
+	err := p.im.PullImageWithHandler(ctx, imagePullPolicy, imageName, auths, handler)
+	cancel()
+	if p.tokens != nil {
+		<-p.tokens
+	}
+
+	p.lock.Lock()
+	delete(p.inflight, key)
+	if err != nil {
+		p.recordFailure(key, err)
+	} else {
+		delete(p.backOffs, key)
+	}
+	p.lock.Unlock()
+	call.err = err
+	close(call.done)
+}
+
+// 记录一次拉取失败，退避时间翻倍直到上限，调用者需要持有锁
+func (p *ImagePuller) recordFailure(key string, err error) {
+	entry, ok := p.backOffs[key]
+	if !ok {
+		entry = &backOffEntry{backOff: p.initialBackOff}
+		p.backOffs[key] = entry
+	} else {
+		entry.backOff *= 2
+		if entry.backOff > p.maxBackOff {
+			entry.backOff = p.maxBackOff
+		}
+	}
+	entry.nextPull = p.now().Add(entry.backOff)
+	entry.lastErr = err
+}
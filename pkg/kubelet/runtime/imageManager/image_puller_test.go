@@ -0,0 +1,209 @@
+package imagemanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"minik8s/minik8sTypes"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/registry"
+)
+
+// 模拟一个拉取很慢的docker daemon，记录拉取次数和最大并发数
+type slowPullDaemon struct {
+	pulls       atomic.Int32
+	running     atomic.Int32
+	maxRunning  atomic.Int32
+	release     chan struct{}
+	failingImgs map[string]bool
+}
+
+func (d *slowPullDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/images/json") {
+		w.Write([]byte("[]"))
+		return
+	}
+	if !strings.HasSuffix(r.URL.Path, "/images/create") {
+		http.NotFound(w, r)
+		return
+	}
+	d.pulls.Add(1)
+	running := d.running.Add(1)
+	defer d.running.Add(-1)
+	for {
+		max := d.maxRunning.Load()
+		if running <= max || d.maxRunning.CompareAndSwap(max, running) {
+			break
+		}
+	}
+	<-d.release
+	if d.failingImgs[r.URL.Query().Get("fromImage")] {
+		fmt.Fprintln(w, `{"errorDetail":{"message":"manifest unknown"},"error":"manifest unknown"}`)
+		return
+	}
+	fmt.Fprintln(w, `{"status":"Pull complete","progressDetail":{},"id":"a1"}`)
+}
+
+func TestImagePullerDeduplicates(t *testing.T) {
+	daemon := &slowPullDaemon{release: make(chan struct{})}
+	p := NewImagePuller(NewImageManager(newFakeDockerClient(t, daemon.ServeHTTP)), 0, time.Minute, time.Second, time.Minute)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	// 拉取策略不同也只拉一次
+	policies := []minik8sTypes.ImagePullPolicyType{minik8sTypes.Always, minik8sTypes.IfNotPresent}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- p.EnsureImage(context.Background(), policies[i%2], "nginx:latest", nil, nil)
+		}(i)
+	}
+	// 等所有调用者都挂到同一个拉取上
+	time.Sleep(100 * time.Millisecond)
+	close(daemon.release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := daemon.pulls.Load(); n != 1 {
+		t.Fatalf("expected 1 pull, got %d", n)
+	}
+}
+
+func TestImagePullerLimitsParallelism(t *testing.T) {
+	daemon := &slowPullDaemon{release: make(chan struct{})}
+	p := NewImagePuller(NewImageManager(newFakeDockerClient(t, daemon.ServeHTTP)), 2, time.Minute, time.Second, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := p.EnsureImage(context.Background(), minik8sTypes.Always, fmt.Sprintf("image%d:latest", i), nil, nil); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(daemon.release)
+	wg.Wait()
+	if max := daemon.maxRunning.Load(); max > 2 {
+		t.Fatalf("expected at most 2 concurrent pulls, got %d", max)
+	}
+	if n := daemon.pulls.Load(); n != 4 {
+		t.Fatalf("expected 4 pulls, got %d", n)
+	}
+}
+
+func TestImagePullerBackOff(t *testing.T) {
+	daemon := &slowPullDaemon{release: make(chan struct{}), failingImgs: map[string]bool{"missing": true}}
+	close(daemon.release)
+	p := NewImagePuller(NewImageManager(newFakeDockerClient(t, daemon.ServeHTTP)), 0, time.Minute, 10*time.Second, 30*time.Second)
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	pull := func() error {
+		return p.EnsureImage(context.Background(), minik8sTypes.Always, "missing:latest", nil, nil)
+	}
+	if err := pull(); err == nil || errors.Is(err, ErrImagePullBackOff) {
+		t.Fatalf("expected pull error, got %v", err)
+	}
+	if err := pull(); !errors.Is(err, ErrImagePullBackOff) {
+		t.Fatalf("expected back-off error, got %v", err)
+	}
+	// 退避时间过了之后会再拉一次，失败后退避时间翻倍
+	now = now.Add(11 * time.Second)
+	if err := pull(); err == nil || errors.Is(err, ErrImagePullBackOff) {
+		t.Fatalf("expected pull error, got %v", err)
+	}
+	now = now.Add(11 * time.Second)
+	if err := pull(); !errors.Is(err, ErrImagePullBackOff) {
+		t.Fatalf("expected back-off error after doubling, got %v", err)
+	}
+	if n := daemon.pulls.Load(); n != 2 {
+		t.Fatalf("expected 2 pulls, got %d", n)
+	}
+}
+
+func TestImagePullerHonorsContext(t *testing.T) {
+	daemon := &slowPullDaemon{release: make(chan struct{})}
+	defer close(daemon.release)
+	p := NewImagePuller(NewImageManager(newFakeDockerClient(t, daemon.ServeHTTP)), 0, time.Minute, time.Second, time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := p.EnsureImage(ctx, minik8sTypes.Always, "nginx:latest", nil, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestImagePullerBackOffPerCredential(t *testing.T) {
+	p := NewImagePuller(NewImageManager(newRegistryStandIn(t, "user", "pass")), 0, time.Minute, 10*time.Second, time.Minute)
+	image := "registry.example.com:5000/team/app:v1"
+	wrong := []registry.AuthConfig{{Username: "user", Password: "wrong", ServerAddress: "registry.example.com:5000"}}
+	right := []registry.AuthConfig{{Username: "user", Password: "pass", ServerAddress: "registry.example.com:5000"}}
+
+	if err := p.EnsureImage(context.Background(), minik8sTypes.Always, image, wrong, nil); err == nil || errors.Is(err, ErrImagePullBackOff) {
+		t.Fatalf("expected pull error, got %v", err)
+	}
+	// 换一个拉取策略不能绕过退避
+	if err := p.EnsureImage(context.Background(), minik8sTypes.IfNotPresent, image, wrong, nil); !errors.Is(err, ErrImagePullBackOff) {
+		t.Fatalf("expected back-off error, got %v", err)
+	}
+	// 修好了认证信息之后不需要等之前的退避
+	if err := p.EnsureImage(context.Background(), minik8sTypes.Always, image, right, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImagePullerTimeoutExcludesQueueing(t *testing.T) {
+	daemon := newFakeDockerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(150 * time.Millisecond)
+		fmt.Fprintln(w, `{"status":"Pull complete","progressDetail":{},"id":"a1"}`)
+	})
+	// 第二个拉取要排队等第一个拉完，排队的时间不能算在超时里面
+	p := NewImagePuller(NewImageManager(daemon), 1, 250*time.Millisecond, time.Second, time.Minute)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := p.EnsureImage(context.Background(), minik8sTypes.Always, fmt.Sprintf("image%d:latest", i), nil, nil); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// Never策略下镜像不存在不算拉取失败，不能让其他策略的拉取进入退避
+func TestImagePullerNeverPolicyDoesNotBackOff(t *testing.T) {
+	daemon := &slowPullDaemon{release: make(chan struct{})}
+	close(daemon.release)
+	p := NewImagePuller(NewImageManager(newFakeDockerClient(t, daemon.ServeHTTP)), 0, time.Minute, 10*time.Second, time.Minute)
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		err := p.EnsureImage(context.Background(), minik8sTypes.Never, "nginx:latest", nil, nil)
+		if err == nil || errors.Is(err, ErrImagePullBackOff) {
+			t.Fatalf("expected image not found, got %v", err)
+		}
+	}
+	if err := p.EnsureImage(context.Background(), minik8sTypes.IfNotPresent, "nginx:latest", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := daemon.pulls.Load(); n != 1 {
+		t.Fatalf("expected 1 pull, got %d", n)
+	}
+}
@@ -0,0 +1,154 @@
+package imagemanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+/*
+	docker拉取镜像的时候返回的是一个json消息流，每一行是一个jsonmessage.JSONMessage
+	拉取过程中的错误（认证失败、manifest unknown等）也是放在这个流里面的，
+	所以必须把流解析完才能知道拉取到底有没有成功
+*/
+
+type PullEventType string
+
+const (
+	PullEventStarted        PullEventType = "Started"        // 开始拉取
+	PullEventProgress       PullEventType = "Progress"       // 拉取进度有更新
+	PullEventCompleted      PullEventType = "Completed"      // 拉取成功
+	PullEventFailed         PullEventType = "Failed"         // 拉取失败
+	PullEventAlreadyPresent PullEventType = "AlreadyPresent" // 镜像已经存在，不需要拉取
+)
+
+// 镜像拉取的进度
+type PullProgress struct {
+	Layers       int           // 一共有多少层
+	LayersDone   int           // 已经完成（下载并解压或者本地已经存在）的层数
+	BytesTotal   int64         // 所有已知大小的层的总字节数
+	BytesCurrent int64         // 已经下载的字节数
+	Duration     time.Duration // 从开始拉取到现在的时间
+}
+
+type PullEvent struct {
+	Type     PullEventType
+	Image    string
+	Progress PullProgress
+	Err      error // 只有Failed事件才有
+}
+
+// 拉取事件的回调，为nil表示不关心拉取过程
+type PullEventHandler func(event PullEvent)
+
+type layerProgress struct {
+	current int64
+	total   int64
+	done    bool
+}
+
+// 记录每一层的进度，汇总成PullProgress
+type pullTracker struct {
+	start  time.Time
+	layers map[string]*layerProgress
+	order  []string
+}
+
+func newPullTracker() *pullTracker {
+	return &pullTracker{
+		start:  time.Now(),
+		layers: map[string]*layerProgress{},
+	}
+}
+
+func (t *pullTracker) update(msg *jsonmessage.JSONMessage) bool {
+	if msg.ID == "" {
+		return false
+	}
+	layer, ok := t.layers[msg.ID]
+	switch msg.Status {
+	case "Pulling fs layer", "Waiting", "Downloading", "Verifying Checksum", "Download complete",
+		"Extracting", "Pull complete", "Already exists":
+	default:
+		// 比如"Pulling from library/nginx"这种消息的id是tag，不是层
+		return false
+	}
+	if !ok {
+		layer = &layerProgress{}
+		t.layers[msg.ID] = layer
+		t.order = append(t.order, msg.ID)
+	}
+	switch msg.Status {
+	case "Downloading":
+		if msg.Progress != nil {
+			layer.current = msg.Progress.Current
+			if msg.Progress.Total > 0 {
+				layer.total = msg.Progress.Total
+			}
+		}
+	case "Download complete":
+		layer.current = layer.total
+	case "Pull complete", "Already exists":
+		layer.current = layer.total
+		layer.done = true
+	}
+	return true
+}
+
+func (t *pullTracker) progress() PullProgress {
+	p := PullProgress{
+		Layers:   len(t.order),
+		Duration: time.Since(t.start),
+	}
+	for _, id := range t.order {
+		layer := t.layers[id]
+		p.BytesTotal += layer.total
+		p.BytesCurrent += layer.current
+		if layer.done {
+			p.LayersDone++
+		}
+	}
+	return p
+}
+
+// 解析docker返回的拉取消息流，流中的错误会作为返回值返回
+func decodePullStream(stream io.Reader, imageName string, handler PullEventHandler) (PullProgress, error) {
+	tracker := newPullTracker()
+	emit := func(eventType PullEventType, err error) {
+		if handler != nil {
+			handler(PullEvent{Type: eventType, Image: imageName, Progress: tracker.progress(), Err: err})
+		}
+	}
+	emit(PullEventStarted, nil)
+	decoder := json.NewDecoder(stream)
+	for {
+		var msg jsonmessage.JSONMessage
+		err := decoder.Decode(&msg)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			err = fmt.Errorf("pull image %s: decode pull stream: %w", imageName, err)
+			emit(PullEventFailed, err)
+			return tracker.progress(), err
+		}
+		if msg.Error != nil || msg.ErrorMessage != "" {
+			message := msg.ErrorMessage
+			if msg.Error != nil {
+				message = msg.Error.Message
+			}
+			err = fmt.Errorf("pull image %s: %s", imageName, message)
+			emit(PullEventFailed, err)
+			return tracker.progress(), err
+		}
+		if tracker.update(&msg) {
+			emit(PullEventProgress, nil)
+		}
+	}
+	emit(PullEventCompleted, nil)
+	return tracker.progress(), nil
+}
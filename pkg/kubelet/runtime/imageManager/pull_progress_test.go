@@ -0,0 +1,48 @@
+package imagemanager
+
+import (
+	"strings"
+	"testing"
+)
+
+const pullStream = `{"status":"Pulling from library/nginx","id":"latest"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"a1"}
+{"status":"Already exists","progressDetail":{},"id":"b2"}
+{"status":"Downloading","progressDetail":{"current":512,"total":1024},"id":"a1"}
+{"status":"Download complete","progressDetail":{},"id":"a1"}
+{"status":"Pull complete","progressDetail":{},"id":"a1"}
+{"status":"Digest: sha256:abc"}
+{"status":"Status: Downloaded newer image for nginx:latest"}
+`
+
+func TestDecodePullStream(t *testing.T) {
+	var events []PullEvent
+	progress, err := decodePullStream(strings.NewReader(pullStream), "nginx:latest", func(e PullEvent) {
+		events = append(events, e)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Layers != 2 || progress.LayersDone != 2 || progress.BytesTotal != 1024 || progress.BytesCurrent != 1024 {
+		t.Fatalf("unexpected progress: %+v", progress)
+	}
+	if events[0].Type != PullEventStarted || events[len(events)-1].Type != PullEventCompleted {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+}
+
+func TestDecodePullStreamError(t *testing.T) {
+	stream := `{"status":"Pulling from library/nope","id":"latest"}
+{"errorDetail":{"message":"manifest unknown"},"error":"manifest unknown"}
+`
+	var last PullEvent
+	_, err := decodePullStream(strings.NewReader(stream), "nope:latest", func(e PullEvent) {
+		last = e
+	})
+	if err == nil || !strings.Contains(err.Error(), "manifest unknown") {
+		t.Fatalf("expected manifest unknown error, got %v", err)
+	}
+	if last.Type != PullEventFailed || last.Err == nil {
+		t.Fatalf("expected failed event, got %+v", last)
+	}
+}
@@ -0,0 +1,87 @@
+package imagemanager
+
+import (
+	"context"
+	"fmt"
+	"minik8s/minik8sTypes"
+	"minik8s/pkg/kubelet/dockerClient/dockertest"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/client"
+)
+
+// 用handler模拟docker daemon，返回连接到它的docker客户端
+func newFakeDockerClient(t *testing.T, handler http.HandlerFunc) *client.Client {
+	return dockertest.NewClient(t, handler)
+}
+
+// 一个假的docker daemon，只接受带有正确认证信息的私有仓库拉取请求
+func newRegistryStandIn(t *testing.T, username, password string) *client.Client {
+	return newFakeDockerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/images/json"):
+			w.Write([]byte("[]"))
+		case strings.HasSuffix(r.URL.Path, "/images/create"):
+			auth, err := registry.DecodeAuthConfig(r.Header.Get(registry.AuthHeader))
+			if err != nil || auth.Username != username || auth.Password != password {
+				fmt.Fprintln(w, `{"errorDetail":{"message":"unauthorized: authentication required"},"error":"unauthorized: authentication required"}`)
+				return
+			}
+			fmt.Fprintln(w, `{"status":"Pulling fs layer","progressDetail":{},"id":"a1"}`)
+			fmt.Fprintln(w, `{"status":"Pull complete","progressDetail":{},"id":"a1"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func TestPullImageWithRegistryAuth(t *testing.T) {
+	im := NewImageManager(newRegistryStandIn(t, "user", "pass"))
+	image := "registry.example.com:5000/team/app:v1"
+
+	err := im.PullImageWithHandler(context.Background(), minik8sTypes.Always, image, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Fatalf("expected anonymous pull to fail, got %v", err)
+	}
+
+	auths := []registry.AuthConfig{
+		{Username: "user", Password: "wrong", ServerAddress: "registry.example.com:5000"},
+		{Username: "user", Password: "pass", ServerAddress: "registry.example.com:5000"},
+	}
+	var completed bool
+	err = im.PullImageWithHandler(context.Background(), minik8sTypes.IfNotPresent, image, auths, func(e PullEvent) {
+		if e.Type == PullEventCompleted {
+			completed = true
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !completed {
+		t.Fatal("expected a completed pull event")
+	}
+}
+
+func TestPullImageReportsFailureOnceForAllCredentials(t *testing.T) {
+	im := NewImageManager(newRegistryStandIn(t, "user", "pass"))
+	image := "registry.example.com:5000/team/app:v1"
+	auths := []registry.AuthConfig{
+		{Username: "user", Password: "wrong", ServerAddress: "registry.example.com:5000"},
+		{Username: "other", Password: "wrong", ServerAddress: "registry.example.com:5000"},
+	}
+	var failures int
+	err := im.PullImageWithHandler(context.Background(), minik8sTypes.Always, image, auths, func(e PullEvent) {
+		if e.Type == PullEventFailed {
+			failures++
+		}
+	})
+	if err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Fatalf("expected pull to fail, got %v", err)
+	}
+	if failures != 1 {
+		t.Fatalf("expected 1 failed event, got %d", failures)
+	}
+}
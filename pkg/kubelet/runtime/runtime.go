@@ -1,24 +1,47 @@
 package runtime
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"minik8s/logger"
 	"minik8s/minik8sTypes"
 	"minik8s/pkg/apis"
+	"minik8s/pkg/kubelet/checkpoint"
+	"minik8s/pkg/kubelet/config"
+	credentialprovider "minik8s/pkg/kubelet/credentialProvider"
 	dockerclient "minik8s/pkg/kubelet/dockerClient"
+	"minik8s/pkg/kubelet/events"
+	"minik8s/pkg/kubelet/logs"
 	containermanager "minik8s/pkg/kubelet/runtime/containerManager"
 	imagemanager "minik8s/pkg/kubelet/runtime/imageManager"
+	runtimeerrors "minik8s/pkg/kubelet/runtime/runtimeErrors"
 	"sync"
+	"time"
 )
 
 type RuntimeManager interface {
-	createPod(pod *apis.Pod) (string, error)
+	createPod(ctx context.Context, pod *apis.Pod) (string, error)
 	generateSandBoxConfig(pod *apis.Pod) (minik8sTypes.Config, minik8sTypes.HostConfig, error)
-	createPodSandbox(pod *apis.Pod) (string, error)
-	removePodContainer(*apis.Pod, *apis.Container) (string, error)
-	startPodContainer(*apis.Pod, apis.Container) error
-	createPodContainer(*apis.Pod, apis.Container, string) error
+	createPodSandbox(ctx context.Context, pod *apis.Pod) (string, error)
+	removePodContainer(context.Context, *apis.Pod, *apis.Container) (string, error)
+	startPodContainer(context.Context, *apis.Pod, apis.Container) error
+	createPodContainer(context.Context, *apis.Pod, apis.Container, string) error
 	generatePodContainerConfig(*apis.Pod, apis.Container, string) (minik8sTypes.Config, minik8sTypes.HostConfig, error)
-	killPod(pod *apis.Pod) error
+	killPod(ctx context.Context, pod *apis.Pod) error
+	// kubelet重启之后，根据容器标签和检查点恢复pod的状态，返回所有恢复出来的pod
+	RecoverPods(ctx context.Context) ([]*apis.Pod, error)
+	// 保证pod的沙箱和容器都已经创建，已经存在的不会重复创建
+	// ctx结束的时候正在进行的docker操作会被取消
+	SyncPod(ctx context.Context, pod *apis.Pod) error
+	// 删除pod的所有容器和沙箱
+	KillPod(ctx context.Context, pod *apis.Pod) error
+	// 回收退出的容器和已经不存在的pod的容器
+	GarbageCollect(ctx context.Context, policy ContainerGCPolicy) error
+	// 根据docker中的容器生成pod的ip和所有容器的状态，phase和容器是否就绪由kubelet计算
+	GetPodStatus(ctx context.Context, pod *apis.Pod) (apis.PodStatus, error)
+	// 一直运行到ctx结束，然后停止所有容器的日志收集，等日志文件写完之后才返回
+	RunLogCollector(ctx context.Context)
 	// getPodSandbox(pod *apis.Pod) (*apis.PodSandbox, error)
 	// getPodSandboxes() ([]*apis.PodSandbox, error)
 	// getPodSandboxStatus(pod *apis.Pod) (*apis.PodSandboxStatus, error)
@@ -27,59 +50,138 @@ type RuntimeManager interface {
 type runtimeManager struct {
 	containerManager *containermanager.ContainerManager
 	imagemanager     *imagemanager.ImageManager
+	imagePuller      *imagemanager.ImagePuller
+	checkpoint       *checkpoint.CheckpointManager
+	recorder         events.EventRecorder
+	secretGetter     credentialprovider.SecretGetter
+	sandboxConfig    config.SandboxConfig
+	logCollector     *logs.Collector
+	podLogsDir       string // <root>/pods
+
+	// 内存中的pod状态，kubelet重启后通过RecoverPods重建
+	lock       sync.RWMutex
+	pods       map[string]*apis.Pod         // pod uid -> pod
+	sandboxes  map[string]string            // pod uid -> pause容器id
+	containers map[string]map[string]string // pod uid -> 容器名 -> 容器id
+	// 还没有全部启动的新pod第一次被同步的时间，用来统计pod的启动耗时
+	podStartTimes map[string]time.Time
+	// 创建或者启动失败的容器的Waiting原因，pod uid -> 容器名 -> 原因
+	waiting map[string]map[string]apis.ContainerStateWaiting
+	// 已经退出的实例的终止信息，pod uid -> 容器id -> 终止信息
+	terminationMessages map[string]map[string]string
 }
 
-func NewRuntimeManager() (r RuntimeManager) {
+func NewRuntimeManager(cfg *config.KubeletConfig, recorder events.EventRecorder) (r RuntimeManager) {
 	cm := containermanager.NewContainerManager(dockerclient.GetDockerClient())
+	cm.SetRequestTimeout(cfg.RuntimeRequestTimeout)
 	im := imagemanager.NewImageManager(dockerclient.GetDockerClient())
 	runtimeMnanger := &runtimeManager{
 		containerManager: cm,
 		imagemanager:     im,
+		imagePuller: imagemanager.NewImagePuller(im, cfg.MaxParallelImagePulls, cfg.ImagePullTimeout,
+			imagemanager.DefaultImagePullBackOffInitial, imagemanager.DefaultImagePullBackOffMax),
+		checkpoint:    checkpoint.NewCheckpointManager(cfg.CheckpointDir()),
+		recorder:      recorder,
+		secretGetter:  credentialprovider.NewFileSecretGetter(cfg.SecretsDir()),
+		sandboxConfig: cfg.Sandbox,
+		logCollector:  logs.NewCollector(cm, cfg.ContainerLogMaxSize, cfg.ContainerLogMaxFiles),
+		podLogsDir:    cfg.PodLogsDir(),
+		pods:          map[string]*apis.Pod{},
+		sandboxes:     map[string]string{},
+		containers:    map[string]map[string]string{},
+		podStartTimes: map[string]time.Time{},
+		waiting:       map[string]map[string]apis.ContainerStateWaiting{},
+
+		terminationMessages: map[string]map[string]string{},
 	}
 	r = runtimeMnanger
 	return
 }
 
+func (r *runtimeManager) SyncPod(ctx context.Context, pod *apis.Pod) error {
+	_, err := r.createPod(ctx, pod)
+	return err
+}
+
+func (r *runtimeManager) KillPod(ctx context.Context, pod *apis.Pod) error {
+	return r.killPod(ctx, pod)
+}
+
+func (r *runtimeManager) RunLogCollector(ctx context.Context) {
+	r.logCollector.Run(ctx)
+}
+
 // 创建pod
-func (r *runtimeManager) createPod(pod *apis.Pod) (string, error) {
-	s, err := r.createPodSandbox(pod)
+// 已经存在的沙箱和容器（比如kubelet重启后接管的）不会被重复创建
+func (r *runtimeManager) createPod(ctx context.Context, pod *apis.Pod) (string, error) {
+	log := logger.WithPod(K8sLogger, pod.Namespace, pod.Name, pod.UID)
+	s := pod.Name + pod.UID
+	if _, ok := r.getSandbox(pod.UID); !ok {
+		r.markPodStarting(pod.UID)
+		var err error
+		s, err = r.createPodSandbox(ctx, pod)
+		if err != nil {
+			log.Errorln("createPodSandbox error: ", err)
+			return "", err
+		}
+	}
+	r.setPod(pod)
+	// 先保存检查点，这样即使创建容器的过程中kubelet崩溃，重启后也能接管已经创建的容器
+	if err := r.checkpoint.SavePod(pod); err != nil {
+		log.Errorln("save pod checkpoint error: ", err)
+	}
+	// init容器全部成功退出之后才创建普通容器
+	initialized, err := r.syncInitContainers(ctx, pod, s)
 	if err != nil {
-		K8sLogger.Errorln("createPodSandbox error: ", err)
+		log.Errorln("syncInitContainers error: ", err)
 		return "", err
 	}
-	// 依次创建pod中所有的容器
+	if !initialized {
+		return s, nil
+	}
+	// 依次创建pod中所有的容器，已经退出的容器根据重启策略创建新的实例
 	for _, container := range pod.Spec.Containers {
+		if id, ok := r.getContainer(pod.UID, container.Name); ok && !r.shouldRestartContainer(ctx, pod, id) {
+			continue
+		}
 		// 创建容器
-		err := r.createPodContainer(pod, container, s)
+		err := r.createPodContainer(ctx, pod, container, s)
 		if err != nil {
-			K8sLogger.Errorln("createPodContainer error: ", err)
+			log.Errorln("createPodContainer error: ", err)
 			return "", err
 		}
 		// 启动容器
-		err = r.startPodContainer(pod, container)
+		err = r.startPodContainer(ctx, pod, container)
 		if err != nil {
-			K8sLogger.Errorln("startPodContainer error: ", err)
+			log.Errorln("startPodContainer error: ", err)
 			return "", err
 		}
 	}
+	r.observePodStarted(pod.UID)
 	return s, nil
 }
 
-func (r *runtimeManager) killPod(pod *apis.Pod) error {
-	// 删除pod中所有的容器
+func (r *runtimeManager) killPod(ctx context.Context, pod *apis.Pod) error {
+	// 删除pod中所有的容器，包括init容器
+	containers := append(append([]apis.Container(nil), pod.Spec.InitContainers...), pod.Spec.Containers...)
 	wg := sync.WaitGroup{}
-	wg.Add(len(pod.Spec.Containers))
-	errChan := make(chan error, len(pod.Spec.Containers)) // 创建一个错误通道
+	wg.Add(len(containers))
+	errChan := make(chan error, len(containers)) // 创建一个错误通道
 	// 依次删除pod中所有的容器
-	for _, container := range pod.Spec.Containers {
+	for _, container := range containers {
 		go func(container apis.Container) {
 			defer wg.Done()
 			// 删除容器
-			fmt.Println("container: ", container)
-			s, err := r.removePodContainer(pod, &container)
+			s, err := r.removePodContainer(ctx, pod, &container)
+			if errors.Is(err, runtimeerrors.ErrNotFound) {
+				// 容器已经不存在了，不影响删除pod
+				K8sLogger.Warnln("removePodContainer: ", err)
+				return
+			}
 			if err != nil {
 				K8sLogger.Errorln("removePodContainer error: ", err)
-				errChan <- fmt.Errorf("removePodContainer error: %v", err)
+				errChan <- fmt.Errorf("removePodContainer error: %w", err)
+				return
 			}
 			K8sLogger.Infoln("removePodContainer success: ", s)
 		}(container)
@@ -89,18 +191,23 @@ func (r *runtimeManager) killPod(pod *apis.Pod) error {
 
 	//如果err通道只有一个错误
 	if len(errChan) == 1 {
-		return fmt.Errorf("removePodContainer one container error: %v", <-errChan)
+		return fmt.Errorf("removePodContainer one container error: %w", <-errChan)
 	}
 	//如果err通道有多个错误
 	for err := range errChan {
-		return fmt.Errorf("removePodContainer multi container error: %v", err)
+		return fmt.Errorf("removePodContainer multi container error: %w", err)
 	}
 
 	// 删除pod沙箱容器
-	err := r.removePodSandbox(pod)
+	err := r.removePodSandbox(ctx, pod)
 	if err != nil {
 		K8sLogger.Errorln("removePodSandbox error: ", err)
 		return err
 	}
+	r.deletePod(pod.UID)
+	r.recorder.RemovePodEvents(pod.UID)
+	if err := r.checkpoint.RemovePod(pod.UID); err != nil {
+		K8sLogger.Errorln("remove pod checkpoint error: ", err)
+	}
 	return nil
 }
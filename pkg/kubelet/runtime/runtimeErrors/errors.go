@@ -0,0 +1,151 @@
+package runtimeerrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+)
+
+/*
+	运行时（容器管理、镜像管理、runtimeManager）返回的错误类别
+	每个错误都带着一个类别，调用者用errors.Is判断类别，用errors.As拿到RuntimeError
+	docker返回的错误通过FromDocker按照errdefs归类，原始的错误仍然可以通过errors.Is/As拿到
+	kubelet根据类别决定是重试、退避还是把pod标记为失败
+*/
+
+// 错误的类别，本身也是一个error，可以直接用errors.Is比较
+type Kind string
+
+func (k Kind) Error() string {
+	return string(k)
+}
+
+const (
+	// 容器、镜像、卷等对象不存在
+	ErrNotFound Kind = "NotFound"
+	// 对象已经存在，或者和对象当前的状态冲突（比如删除一个正在运行的容器）
+	ErrAlreadyExists Kind = "AlreadyExists"
+	// 拉取镜像失败，包括拉取退避期间的失败
+	ErrImagePullFailed Kind = "ImagePullFailed"
+	// pod或者容器的配置有问题，重试也不会成功
+	ErrInvalidSpec Kind = "InvalidSpec"
+	// docker不可用：连不上、超时、daemon内部错误
+	ErrRuntimeUnavailable Kind = "RuntimeUnavailable"
+)
+
+type RuntimeError struct {
+	Kind    Kind
+	Message string
+	// 原始的错误，可以为nil
+	Err error
+}
+
+func (e *RuntimeError) Error() string {
+	switch {
+	case e.Err == nil:
+		return e.Message
+	case e.Message == "":
+		return e.Err.Error()
+	default:
+		return e.Message + ": " + e.Err.Error()
+	}
+}
+
+// errors.Is/As既能匹配类别，也能匹配原始的错误
+func (e *RuntimeError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+func New(kind Kind, err error, format string, args ...interface{}) error {
+	return &RuntimeError{Kind: kind, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+func NotFoundf(format string, args ...interface{}) error {
+	return New(ErrNotFound, nil, format, args...)
+}
+
+func AlreadyExistsf(format string, args ...interface{}) error {
+	return New(ErrAlreadyExists, nil, format, args...)
+}
+
+func InvalidSpecf(format string, args ...interface{}) error {
+	return New(ErrInvalidSpec, nil, format, args...)
+}
+
+// 拉取镜像失败，如果是docker不可用导致的，errors.Is(err, ErrRuntimeUnavailable)同样成立
+func ImagePullFailed(imageName string, err error) error {
+	return New(ErrImagePullFailed, FromDocker(err, ""), "pull image %q", imageName)
+}
+
+// 把docker返回的错误按照errdefs归类，已经归过类的错误只补充上下文
+// 无法归类的错误（比如调用者主动取消）只包一层上下文
+func FromDocker(err error, format string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	msg := fmt.Sprintf(format, args...)
+	var runtimeErr *RuntimeError
+	if errors.As(err, &runtimeErr) {
+		if msg == "" {
+			return err
+		}
+		return fmt.Errorf("%s: %w", msg, err)
+	}
+	kind, ok := classify(err)
+	if !ok {
+		if msg == "" {
+			return err
+		}
+		return fmt.Errorf("%s: %w", msg, err)
+	}
+	return &RuntimeError{Kind: kind, Message: msg, Err: err}
+}
+
+func classify(err error) (Kind, bool) {
+	switch {
+	case errors.Is(err, context.Canceled) || implements[errdefs.ErrCancelled](err):
+		// 调用者主动取消的不算docker的问题
+		return "", false
+	case implements[errdefs.ErrNotFound](err):
+		return ErrNotFound, true
+	case implements[errdefs.ErrConflict](err):
+		return ErrAlreadyExists, true
+	case implements[errdefs.ErrInvalidParameter](err):
+		return ErrInvalidSpec, true
+	case client.IsErrConnectionFailed(err),
+		implements[errdefs.ErrUnavailable](err),
+		implements[errdefs.ErrDeadline](err),
+		implements[errdefs.ErrSystem](err),
+		errors.Is(err, context.DeadlineExceeded):
+		return ErrRuntimeUnavailable, true
+	}
+	return "", false
+}
+
+// errdefs.IsXXX只认Cause，不认fmt.Errorf的%w，所以用errors.As判断
+func implements[T any](err error) bool {
+	var target T
+	return errors.As(err, &target)
+}
+
+// 错误的类别，没有类别的时候返回空字符串，可以直接用作事件的reason
+func Reason(err error) string {
+	for _, kind := range []Kind{ErrInvalidSpec, ErrImagePullFailed, ErrNotFound, ErrAlreadyExists, ErrRuntimeUnavailable} {
+		if errors.Is(err, kind) {
+			return string(kind)
+		}
+	}
+	return ""
+}
+
+// 是否值得在下一次同步的时候重试
+// 配置错误重试也不会成功，其他类别的错误（包括没有类别的）都可能是暂时的
+func IsRetryable(err error) bool {
+	return err != nil && !errors.Is(err, ErrInvalidSpec)
+}
@@ -0,0 +1,112 @@
+package runtimeerrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"minik8s/pkg/kubelet/dockerClient/dockertest"
+	"net/http"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+)
+
+// 按照路径返回固定状态码的docker daemon
+func newStatusDaemon(t *testing.T, status int) *client.Client {
+	c := dockertest.NewClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"message":"status %d"}`, status)
+	}))
+	return c
+}
+
+func TestFromDocker(t *testing.T) {
+	cases := []struct {
+		status int
+		kind   Kind
+	}{
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusConflict, ErrAlreadyExists},
+		{http.StatusBadRequest, ErrInvalidSpec},
+		{http.StatusInternalServerError, ErrRuntimeUnavailable},
+		{http.StatusServiceUnavailable, ErrRuntimeUnavailable},
+	}
+	for _, c := range cases {
+		dc := newStatusDaemon(t, c.status)
+		_, dockerErr := dc.ContainerInspect(context.Background(), "abc")
+		err := FromDocker(dockerErr, "inspect container %s", "abc")
+		if !errors.Is(err, c.kind) {
+			t.Errorf("status %d: expected %s, got %v", c.status, c.kind, err)
+		}
+		if Reason(err) != string(c.kind) {
+			t.Errorf("status %d: expected reason %s, got %q", c.status, c.kind, Reason(err))
+		}
+		var runtimeErr *RuntimeError
+		if !errors.As(err, &runtimeErr) || runtimeErr.Err != dockerErr {
+			t.Errorf("status %d: original docker error is lost: %v", c.status, err)
+		}
+		if want := "inspect container abc: " + dockerErr.Error(); err.Error() != want {
+			t.Errorf("status %d: expected message %q, got %q", c.status, want, err.Error())
+		}
+	}
+}
+
+func TestFromDockerConnectionFailed(t *testing.T) {
+	dc := dockertest.NewUnreachableClient(t)
+	_, err := dc.ContainerList(context.Background(), types.ContainerListOptions{})
+	if err = FromDocker(err, "list containers"); !errors.Is(err, ErrRuntimeUnavailable) {
+		t.Fatalf("expected RuntimeUnavailable, got %v", err)
+	}
+}
+
+func TestFromDockerUnclassified(t *testing.T) {
+	if FromDocker(nil, "nothing") != nil {
+		t.Fatal("nil error should stay nil")
+	}
+	cancelled := errdefs.Cancelled(context.Canceled)
+	err := FromDocker(cancelled, "list containers")
+	if Reason(err) != "" || !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancellation should not be classified, got %q: %v", Reason(err), err)
+	}
+	// 已经归过类的错误只补充上下文
+	notFound := NotFoundf("container %s not found", "abc")
+	err = FromDocker(notFound, "remove pod")
+	if !errors.Is(err, ErrNotFound) || err.Error() != "remove pod: container abc not found" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestImagePullFailed(t *testing.T) {
+	unavailable := errdefs.Unavailable(errors.New("daemon restarting"))
+	err := ImagePullFailed("nginx", unavailable)
+	if !errors.Is(err, ErrImagePullFailed) || !errors.Is(err, ErrRuntimeUnavailable) {
+		t.Fatalf("expected both ImagePullFailed and RuntimeUnavailable, got %v", err)
+	}
+	if Reason(err) != string(ErrImagePullFailed) {
+		t.Fatalf("unexpected reason %q", Reason(err))
+	}
+	if !IsRetryable(err) {
+		t.Fatal("image pull failures should be retried")
+	}
+	backOff := fmt.Errorf("%w: back-off pulling image: %w", errors.New("ImagePullBackOff"), err)
+	if !errors.Is(backOff, ErrImagePullFailed) {
+		t.Fatal("wrapped pull failure lost its kind")
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	if IsRetryable(nil) {
+		t.Fatal("nil is not retryable")
+	}
+	if IsRetryable(fmt.Errorf("create pod: %w", InvalidSpecf("volume data not found"))) {
+		t.Fatal("invalid spec should not be retried")
+	}
+	for _, err := range []error{NotFoundf("x"), AlreadyExistsf("x"), errors.New("unknown")} {
+		if !IsRetryable(err) {
+			t.Fatalf("%v should be retried", err)
+		}
+	}
+}
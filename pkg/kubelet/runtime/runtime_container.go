@@ -2,9 +2,15 @@ package runtime
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"minik8s/logger"
 	"minik8s/minik8sTypes"
 	"minik8s/pkg/apis"
+	containermanager "minik8s/pkg/kubelet/runtime/containerManager"
+	runtimeerrors "minik8s/pkg/kubelet/runtime/runtimeErrors"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
@@ -15,55 +21,144 @@ import (
 // -----------------------------------------------------
 
 // 这里的startContainer 跟 k8s中的startContainer不一样
-func (r *runtimeManager) startPodContainer(pod *apis.Pod, container apis.Container) error {
-	filter := filters.NewArgs()
-	filter.Add("label", minik8sTypes.KubernetesPodNameLabel+"="+pod.Name)
-	filter.Add("label", minik8sTypes.KubernetesPodNamespaceLabel+"="+pod.Namespace)
-	filter.Add("label", minik8sTypes.KubernetesPodUIDLabel+"="+string(pod.UID))
-	filter.Add("label", minik8sTypes.Minik8sPodTypeLabel+"="+minik8sTypes.Minik8sGenericPodType) //普通容器的标签
-	filter.Add("label", minik8sTypes.LabelsContainerName+"="+container.Name)                     //通过容器名字定位到一个容器
-	res, err := r.containerManager.ListContainerWithOpts(context.TODO(), types.ContainerListOptions{
-		All:     true,
-		Filters: filter,
-	})
+// 启动的是createPodContainer刚刚创建的那个实例，启动之后开始把输出收集到日志文件中
+func (r *runtimeManager) startPodContainer(ctx context.Context, pod *apis.Pod, container apis.Container) error {
+	log := logger.WithContainer(logger.WithPod(K8sLogger, pod.Namespace, pod.Name, pod.UID), container.Name, "")
+	id, ok := r.getContainer(pod.UID, container.Name)
+	if !ok {
+		log.Errorln("startContainer error: container ", container.Name, " not created")
+		return runtimeerrors.NotFoundf("container %s of pod %s/%s not created", container.Name, pod.Namespace, pod.Name)
+	}
+	log = log.With("containerID", id)
+	inspect, err := r.containerManager.InspectContainer(ctx, id)
 	if err != nil {
-		K8sLogger.Errorln("startContainer error: ", err)
+		log.Errorln("startContainer error: ", err)
+		r.setContainerWaiting(pod.UID, container.Name, apis.RunContainerError, err)
 		return err
 	}
-	for _, container := range res {
-		err := r.containerManager.StartContainer(context.Background(), container.ID)
-		if err != nil {
-			K8sLogger.Errorln("startContainer error: ", err)
-			return err
-		}
+	err = r.containerManager.StartContainer(ctx, id)
+	if err != nil {
+		log.Errorln("startContainer error: ", err)
+		r.setContainerWaiting(pod.UID, container.Name, apis.RunContainerError, err)
+		return err
+	}
+	r.clearContainerWaiting(pod.UID, container.Name)
+	if logPath := inspect.Config.Labels[minik8sTypes.KubernetesContainerLogPathLabel]; logPath != "" {
+		r.logCollector.Start(id, logPath)
 	}
+	log.Infoln("container started")
 	return nil
 }
 
-func (r *runtimeManager) createPodContainer(pod *apis.Pod, container apis.Container, sandboxName string) error {
+func (r *runtimeManager) createPodContainer(ctx context.Context, pod *apis.Pod, container apis.Container, sandboxName string) error {
+	log := logger.WithContainer(logger.WithPod(K8sLogger, pod.Namespace, pod.Name, pod.UID), container.Name, "")
 	//拉容器
-	err := r.imagemanager.PullImage(context.Background(), container.ImagePullPolicy, container.Image)
+	err := r.pullImageForPod(ctx, pod, container.ImagePullPolicy, container.Image)
 	if err != nil {
-		K8sLogger.Errorln("pullImage error: ", err)
+		log.Errorln("pullImage error: ", err)
+		r.setContainerWaiting(pod.UID, container.Name, imagePullWaitingReason(container.ImagePullPolicy, err), err)
 		return err
 	}
 	//创建容器的配置
 	config, hostConfig, err := r.generatePodContainerConfig(pod, container, sandboxName)
 	if err != nil {
-		K8sLogger.Errorln("createContainer error: ", err)
+		log.Errorln("createContainer error: ", err)
+		r.setContainerWaiting(pod.UID, container.Name, apis.CreateContainerConfigError, err)
+		return err
+	}
+	//同一个容器每次重启都是一个新的实例，用attempt区分
+	attempt, err := r.nextAttempt(ctx, pod, container.Name)
+	if err != nil {
+		log.Errorln("createContainer error: ", err)
+		r.setContainerWaiting(pod.UID, container.Name, apis.CreateContainerError, err)
 		return err
 	}
-	//创建容器的上下文
-	ctx := context.Background()
+	config.Labels[minik8sTypes.KubernetesContainerAttemptLabel] = strconv.Itoa(attempt)
+	config.Labels[minik8sTypes.KubernetesContainerLogPathLabel] = r.containerLogPath(pod.UID, container.Name, attempt)
+	//终止信息文件从宿主机挂载进去，这样容器退出之后kubelet还能读到
+	messagePath := r.containerTerminationMessagePath(pod.UID, container.Name, attempt)
+	if err := prepareTerminationMessageFile(messagePath); err != nil {
+		log.Errorln("createContainer error: ", err)
+		r.setContainerWaiting(pod.UID, container.Name, apis.CreateContainerError, err)
+		return err
+	}
+	config.Labels[minik8sTypes.KubernetesContainerTerminationMessagePathLabel] = messagePath
+	hostConfig.Binds = append(hostConfig.Binds, messagePath+":"+terminationMessagePath(container))
 	//创建容器
-	_, err = r.containerManager.NewContainer(ctx, &config, &hostConfig, container.Name)
+	ID, err := r.containerManager.NewContainer(ctx, &config, &hostConfig, containerDockerName(pod, container.Name, attempt))
 	if err != nil {
-		K8sLogger.Errorln("createContainer error: ", err)
+		log.Errorln("createContainer error: ", err)
+		r.setContainerWaiting(pod.UID, container.Name, apis.CreateContainerError, err)
 		return err
 	}
+	r.setContainer(pod.UID, container.Name, ID)
 	return nil
 }
 
+// docker中的容器名字必须唯一，不同pod的同名容器以及同一个容器的多次重启都要区分开
+func containerDockerName(pod *apis.Pod, containerName string, attempt int) string {
+	return strings.Join([]string{containerName, pod.Name, pod.Namespace, pod.UID, strconv.Itoa(attempt)}, "_")
+}
+
+// pod的日志目录 <root>/pods/<uid>
+func (r *runtimeManager) podLogDir(podUID string) string {
+	return filepath.Join(r.podLogsDir, podUID)
+}
+
+// 容器日志文件 <root>/pods/<uid>/<container>/<attempt>.log
+func (r *runtimeManager) containerLogPath(podUID string, containerName string, attempt int) string {
+	return filepath.Join(r.podLogDir(podUID), containerName, strconv.Itoa(attempt)+".log")
+}
+
+// 容器的终止信息文件 <root>/pods/<uid>/<container>/<attempt>.termination-log
+func (r *runtimeManager) containerTerminationMessagePath(podUID string, containerName string, attempt int) string {
+	return filepath.Join(r.podLogDir(podUID), containerName, strconv.Itoa(attempt)+".termination-log")
+}
+
+// 下一个实例的attempt，是已经存在的所有实例中最大的attempt加一
+// 垃圾回收不会删除当前实例，所以最大的attempt总是还在
+func (r *runtimeManager) nextAttempt(ctx context.Context, pod *apis.Pod, containerName string) (int, error) {
+	filter := filters.NewArgs()
+	filter.Add("label", minik8sTypes.KubernetesPodUIDLabel+"="+string(pod.UID))
+	filter.Add("label", minik8sTypes.Minik8sPodTypeLabel+"="+minik8sTypes.Minik8sGenericPodType)
+	filter.Add("label", minik8sTypes.LabelsContainerName+"="+containerName)
+	res, err := r.containerManager.ListContainerWithOpts(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filter,
+	})
+	if err != nil {
+		return 0, err
+	}
+	next := 0
+	for _, c := range res {
+		if attempt := containermanager.ContainerAttempt(c.Labels); attempt+1 > next {
+			next = attempt + 1
+		}
+	}
+	return next, nil
+}
+
+// 当前实例已经退出的时候，根据pod的重启策略决定是否要创建新的实例
+func (r *runtimeManager) shouldRestartContainer(ctx context.Context, pod *apis.Pod, containerID string) bool {
+	inspect, err := r.containerManager.InspectContainer(ctx, containerID)
+	if err != nil {
+		// 容器被人为删掉了，重新创建
+		K8sLogger.Warnln("inspect container ", containerID, " error: ", err)
+		return pod.Spec.RestartPolicy != minik8sTypes.Minik8sRestartPolicyNever
+	}
+	if inspect.State == nil || inspect.State.Running || inspect.State.Restarting {
+		return false
+	}
+	switch pod.Spec.RestartPolicy {
+	case minik8sTypes.Minik8sRestartPolicyNever:
+		return false
+	case minik8sTypes.Minik8sRestartPolicyOnFailure:
+		return inspect.State.ExitCode != 0
+	default:
+		return true
+	}
+}
+
 // 一个pod中的单个容器的配置（非pause容器）
 func (r *runtimeManager) generatePodContainerConfig(pod *apis.Pod, container apis.Container, sandboxName string) (minik8sTypes.Config, minik8sTypes.HostConfig, error) {
 	labels := map[string]string{}
@@ -93,6 +188,8 @@ func (r *runtimeManager) generatePodContainerConfig(pod *apis.Pod, container api
 		ImagePullPolicy: container.ImagePullPolicy,
 		Labels:          labels,
 		Tty:             true,
+		OpenStdin:       container.Stdin,
+		StdinOnce:       container.StdinOnce,
 
 		// Tty: container,
 	}
@@ -105,6 +202,7 @@ func (r *runtimeManager) generatePodContainerConfig(pod *apis.Pod, container api
 		PidMode:          minik8sTypes.NsModeContainerPrefix + sandboxName,
 		CPUResourceLimit: int64(container.Resources.Limits.Cpu),
 		MemoryLimit:      int64(container.Resources.Limits.Memory),
+		CgroupParent:     r.sandboxConfig.CgroupParent,
 	}
 	return config, hostcfg, nil
 }
@@ -132,8 +230,9 @@ func (r *runtimeManager) bindingVolumeInPod(pod *apis.Pod, container *apis.Conta
 	for _, containerVol := range container.VolumeMounts {
 		// 如果容器中的volumemount的名字和pod中的volumes的名字一样，那么就把宿主机的路径和容器的路径绑定起来
 		if value, ok := volumeMap[containerVol.Name]; !ok {
-			K8sLogger.Errorln("volume %s not found in pod", containerVol.Name)
-			return nil, fmt.Errorf("volume %s not found in pod", containerVol.Name)
+			err := runtimeerrors.InvalidSpecf("volume %s of container %s not found in pod %s/%s", containerVol.Name, container.Name, pod.Namespace, pod.Name)
+			K8sLogger.Errorln(err)
+			return nil, err
 		} else {
 			//把宿主机的路径和容器的路径绑定起来
 			// "/path/on/host:/path/in/container"
@@ -143,7 +242,7 @@ func (r *runtimeManager) bindingVolumeInPod(pod *apis.Pod, container *apis.Conta
 	return bind, nil
 }
 
-func (r *runtimeManager) removePodContainer(pod *apis.Pod, container *apis.Container) (string, error) {
+func (r *runtimeManager) removePodContainer(ctx context.Context, pod *apis.Pod, container *apis.Container) (string, error) {
 
 	filter := filters.NewArgs()
 
@@ -154,7 +253,7 @@ func (r *runtimeManager) removePodContainer(pod *apis.Pod, container *apis.Conta
 	filter.Add("label", minik8sTypes.Minik8sPodTypeLabel+"="+minik8sTypes.Minik8sGenericPodType) //普通容器的标签
 	filter.Add("label", minik8sTypes.LabelsContainerName+"="+container.Name)                     //通过容器名字定位到一个容器
 	// 根据容器的名字过滤器，过滤出来所有的容器
-	res, err := r.containerManager.ListContainerWithOpts(context.TODO(), types.ContainerListOptions{
+	res, err := r.containerManager.ListContainerWithOpts(ctx, types.ContainerListOptions{
 		All:     true,
 		Filters: filter,
 	})
@@ -163,11 +262,14 @@ func (r *runtimeManager) removePodContainer(pod *apis.Pod, container *apis.Conta
 		return "", err
 	}
 	if len(res) == 0 {
-		return "", fmt.Errorf("container not found")
+		return "", runtimeerrors.NotFoundf("container %s of pod %s/%s not found", container.Name, pod.Namespace, pod.Name)
 	}
-	// 遍历所有的容器，然后删除
+	// 遍历所有的容器，然后删除，已经被别人删掉的跳过
 	for _, container := range res {
-		err2 := r.containerManager.RemoveContainer(context.TODO(), container.ID)
+		err2 := r.containerManager.RemoveContainer(ctx, container.ID)
+		if errors.Is(err2, runtimeerrors.ErrNotFound) {
+			continue
+		}
 		if err2 != nil {
 			K8sLogger.Errorln("removeContainer error: ", err2)
 			return "", err2
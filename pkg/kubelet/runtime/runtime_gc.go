@@ -0,0 +1,180 @@
+package runtime
+
+import (
+	"context"
+	"minik8s/minik8sTypes"
+	"minik8s/pkg/kubelet/logs"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// -----------------------------------------------------
+// 这个文件主要处理的是容器的垃圾回收
+// 平时只有killPod的时候才会删除容器，退出的容器和pod已经不存在的沙箱会一直堆积，
+// 这里根据容器上的标签定期清理：
+// 1. 每个pod的每个容器最多保留MaxPerPodContainer个已经退出的容器
+// 2. 所属pod已经不存在（uid不认识）的容器，超过宽限期之后删除
+// 3. 所属pod已经不存在、并且没有正在运行的业务容器的pause容器，超过宽限期之后删除
+// 4. 删除容器的时候一起删除它的日志文件，pod不存在并且容器都删完之后删除pod的日志目录
+// -----------------------------------------------------
+
+type ContainerGCPolicy struct {
+	// 退出的容器至少要创建了这么久才会被回收
+	MinAge time.Duration
+	// 每个pod的每个容器最多保留多少个退出的容器，小于0表示不限制
+	MaxPerPodContainer int
+	// 不认识的pod的容器要存在多久才会被删除，避免删掉正在创建中的pod的容器
+	OrphanGracePeriod time.Duration
+}
+
+const (
+	containerStateRunning = "running"
+)
+
+// 一个pod中的一个容器的所有实例
+type containerKey struct {
+	podUID        string
+	containerName string
+}
+
+func (r *runtimeManager) GarbageCollect(ctx context.Context, policy ContainerGCPolicy) error {
+	containers, err := r.containerManager.ListMinik8sContainer(ctx)
+	if err != nil {
+		K8sLogger.Errorln("GarbageCollect error: ", err)
+		return err
+	}
+	now := time.Now()
+
+	r.lock.RLock()
+	knownPods := map[string]bool{}
+	activeContainers := map[string]bool{}
+	for uid := range r.pods {
+		knownPods[uid] = true
+	}
+	for _, byName := range r.containers {
+		for _, id := range byName {
+			activeContainers[id] = true
+		}
+	}
+	for _, id := range r.sandboxes {
+		activeContainers[id] = true
+	}
+	r.lock.RUnlock()
+
+	var toRemove []types.Container
+	liveAppContainers := map[string]int{} // pod uid -> 正在运行的业务容器数量
+	deadByKey := map[containerKey][]types.Container{}
+	var orphanedSandboxes []types.Container
+	for _, c := range containers {
+		uid := c.Labels[minik8sTypes.KubernetesPodUIDLabel]
+		age := now.Sub(time.Unix(c.Created, 0))
+		isPause := c.Labels[minik8sTypes.Minik8sPodTypeLabel] == minik8sTypes.Minik8sPausePodType
+		if !isPause && c.State == containerStateRunning {
+			liveAppContainers[uid]++
+		}
+		if !knownPods[uid] {
+			if age < policy.OrphanGracePeriod {
+				continue
+			}
+			if isPause {
+				orphanedSandboxes = append(orphanedSandboxes, c)
+			} else {
+				toRemove = append(toRemove, c)
+			}
+			continue
+		}
+		// pod还在，只回收已经退出并且不是当前实例的容器
+		if isPause || c.State == containerStateRunning || activeContainers[c.ID] || age < policy.MinAge {
+			continue
+		}
+		key := containerKey{podUID: uid, containerName: c.Labels[minik8sTypes.LabelsContainerName]}
+		deadByKey[key] = append(deadByKey[key], c)
+	}
+
+	// 每个容器只保留最新的MaxPerPodContainer个退出的实例
+	if policy.MaxPerPodContainer >= 0 {
+		for _, dead := range deadByKey {
+			if len(dead) <= policy.MaxPerPodContainer {
+				continue
+			}
+			sort.Slice(dead, func(i, j int) bool {
+				return dead[i].Created > dead[j].Created
+			})
+			toRemove = append(toRemove, dead[policy.MaxPerPodContainer:]...)
+		}
+	}
+
+	// 删除孤儿业务容器之后，对应的pause容器也就没有正在运行的业务容器了
+	removedApp := map[string]int{}
+	for _, c := range toRemove {
+		if err := r.containerManager.RemoveContainer(ctx, c.ID); err != nil {
+			K8sLogger.Errorln("GarbageCollect remove container ", c.ID, " error: ", err)
+			continue
+		}
+		K8sLogger.Infoln("GarbageCollect removed container ", c.ID, " of pod ", c.Labels[minik8sTypes.KubernetesPodUIDLabel])
+		if logPath := c.Labels[minik8sTypes.KubernetesContainerLogPathLabel]; logPath != "" {
+			if err := logs.RemoveLogFiles(logPath); err != nil {
+				K8sLogger.Errorln("GarbageCollect remove logs of container ", c.ID, " error: ", err)
+			}
+		}
+		if messagePath := c.Labels[minik8sTypes.KubernetesContainerTerminationMessagePathLabel]; messagePath != "" {
+			if err := os.Remove(messagePath); err != nil && !os.IsNotExist(err) {
+				K8sLogger.Errorln("GarbageCollect remove termination message of container ", c.ID, " error: ", err)
+			}
+		}
+		if c.State == containerStateRunning {
+			removedApp[c.Labels[minik8sTypes.KubernetesPodUIDLabel]]++
+		}
+	}
+	for _, c := range orphanedSandboxes {
+		uid := c.Labels[minik8sTypes.KubernetesPodUIDLabel]
+		if liveAppContainers[uid]-removedApp[uid] > 0 {
+			continue
+		}
+		if err := r.containerManager.RemoveContainer(ctx, c.ID); err != nil {
+			K8sLogger.Errorln("GarbageCollect remove sandbox ", c.ID, " error: ", err)
+			continue
+		}
+		K8sLogger.Infoln("GarbageCollect removed sandbox ", c.ID, " of pod ", uid)
+	}
+
+	// 有容器的pod下一轮再看，容器都删完了再删日志目录
+	podsWithContainers := map[string]bool{}
+	for _, c := range containers {
+		podsWithContainers[c.Labels[minik8sTypes.KubernetesPodUIDLabel]] = true
+	}
+	r.removeOrphanedPodLogs(knownPods, podsWithContainers, now, policy.OrphanGracePeriod)
+	return nil
+}
+
+// 删除已经不存在的pod的日志目录，目录最后修改时间超过宽限期才会删除
+func (r *runtimeManager) removeOrphanedPodLogs(knownPods, podsWithContainers map[string]bool, now time.Time, grace time.Duration) {
+	if r.podLogsDir == "" {
+		return
+	}
+	entries, err := os.ReadDir(r.podLogsDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			K8sLogger.Errorln("GarbageCollect read pod logs dir error: ", err)
+		}
+		return
+	}
+	for _, entry := range entries {
+		uid := entry.Name()
+		if !entry.IsDir() || knownPods[uid] || podsWithContainers[uid] {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || now.Sub(info.ModTime()) < grace {
+			continue
+		}
+		if err := os.RemoveAll(r.podLogDir(uid)); err != nil {
+			K8sLogger.Errorln("GarbageCollect remove logs of pod ", uid, " error: ", err)
+			continue
+		}
+		K8sLogger.Infoln("GarbageCollect removed logs of pod ", uid)
+	}
+}
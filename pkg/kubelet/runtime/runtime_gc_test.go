@@ -0,0 +1,155 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"minik8s/minik8sTypes"
+	"minik8s/pkg/apis"
+	"minik8s/pkg/kubelet/dockerClient/dockertest"
+	containermanager "minik8s/pkg/kubelet/runtime/containerManager"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+type fakeContainerDaemon struct {
+	lock       sync.Mutex
+	containers []types.Container
+	removed    []string
+}
+
+func (d *fakeContainerDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/containers/json"):
+		json.NewEncoder(w).Encode(d.containers)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/stop"):
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete && strings.Contains(r.URL.Path, "/containers/"):
+		d.removed = append(d.removed, r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:])
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newFakeRuntimeManager(t *testing.T, daemon http.Handler) *runtimeManager {
+	c := dockertest.NewClient(t, daemon)
+	return &runtimeManager{
+		containerManager: containermanager.NewContainerManager(c),
+		podLogsDir:       t.TempDir(),
+		pods:             map[string]*apis.Pod{},
+		sandboxes:        map[string]string{},
+		containers:       map[string]map[string]string{},
+		waiting:          map[string]map[string]apis.ContainerStateWaiting{},
+
+		terminationMessages: map[string]map[string]string{},
+	}
+}
+
+func gcTestContainer(id, uid, podType, name, state string, created time.Time) types.Container {
+	return types.Container{
+		ID:      id,
+		State:   state,
+		Created: created.Unix(),
+		Labels: map[string]string{
+			minik8sTypes.KubernetesPodUIDLabel: uid,
+			minik8sTypes.Minik8sPodTypeLabel:   podType,
+			minik8sTypes.LabelsContainerName:   name,
+		},
+	}
+}
+
+func TestGarbageCollect(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+	fresh := time.Now()
+	pause, generic := minik8sTypes.Minik8sPausePodType, minik8sTypes.Minik8sGenericPodType
+	daemon := &fakeContainerDaemon{containers: []types.Container{
+		// 已知的pod，当前的实例加上三个退出的实例，只保留最新的一个退出的实例
+		gcTestContainer("live-sandbox", "known", pause, "", "running", old),
+		gcTestContainer("current", "known", generic, "web", "exited", old),
+		gcTestContainer("dead-1", "known", generic, "web", "exited", old.Add(1*time.Minute)),
+		gcTestContainer("dead-2", "known", generic, "web", "exited", old.Add(2*time.Minute)),
+		gcTestContainer("dead-3", "known", generic, "web", "exited", old.Add(3*time.Minute)),
+		// 不认识的pod，超过宽限期的业务容器和沙箱都要删掉
+		gcTestContainer("orphan-app", "gone", generic, "web", "running", old),
+		gcTestContainer("orphan-sandbox", "gone", pause, "", "running", old),
+		// 不认识的pod，但是还在宽限期内（可能正在创建）
+		gcTestContainer("new-sandbox", "creating", pause, "", "running", fresh),
+	}}
+	r := newFakeRuntimeManager(t, daemon)
+	r.pods["known"] = &apis.Pod{ObjectMeta: apis.ObjectMeta{UID: "known"}}
+	r.sandboxes["known"] = "live-sandbox"
+	r.containers["known"] = map[string]string{"web": "current"}
+
+	err := r.GarbageCollect(context.Background(), ContainerGCPolicy{
+		MinAge:             time.Minute,
+		MaxPerPodContainer: 1,
+		OrphanGracePeriod:  time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(daemon.removed)
+	want := []string{"dead-1", "dead-2", "orphan-app", "orphan-sandbox"}
+	if strings.Join(daemon.removed, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected %v removed, got %v", want, daemon.removed)
+	}
+}
+
+func TestGarbageCollectRemovesLogs(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+	generic := minik8sTypes.Minik8sGenericPodType
+	daemon := &fakeContainerDaemon{}
+	r := newFakeRuntimeManager(t, daemon)
+	r.pods["known"] = &apis.Pod{ObjectMeta: apis.ObjectMeta{UID: "known"}}
+	r.containers["known"] = map[string]string{"web": "current"}
+
+	// 已知pod的三个实例，最老的那个会被回收，它的日志（包括轮转出来的）也要删掉
+	var files []string
+	for attempt, id := range []string{"dead-0", "dead-1", "current"} {
+		c := gcTestContainer(id, "known", generic, "web", "exited", old.Add(time.Duration(attempt)*time.Minute))
+		c.Labels[minik8sTypes.KubernetesContainerLogPathLabel] = r.containerLogPath("known", "web", attempt)
+		daemon.containers = append(daemon.containers, c)
+		files = append(files, r.containerLogPath("known", "web", attempt))
+	}
+	files = append(files, files[0]+".1")
+	// 已经不存在并且没有容器的pod只剩下日志目录
+	files = append(files, r.containerLogPath("gone", "web", 0))
+	for _, f := range files {
+		if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(f, []byte("log\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Chtimes(r.podLogDir("gone"), old, old)
+
+	err := r.GarbageCollect(context.Background(), ContainerGCPolicy{
+		MinAge:             time.Minute,
+		MaxPerPodContainer: 1,
+		OrphanGracePeriod:  time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, f := range files {
+		_, err := os.Stat(f)
+		removed := i == 0 || i == 3 || i == 4
+		if removed != os.IsNotExist(err) {
+			t.Fatalf("file %s: expected removed=%v, stat error %v", f, removed, err)
+		}
+	}
+	if _, err := os.Stat(r.podLogDir("gone")); !os.IsNotExist(err) {
+		t.Fatalf("expected logs of removed pod to be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"minik8s/minik8sTypes"
+	"minik8s/pkg/apis"
+	credentialprovider "minik8s/pkg/kubelet/credentialProvider"
+	"minik8s/pkg/kubelet/events"
+	imagemanager "minik8s/pkg/kubelet/runtime/imageManager"
+)
+
+// -----------------------------------------------------
+// 这个文件主要处理的是pod拉取镜像的操作，拉取的过程会作为事件记录到pod上
+// 私有仓库的认证信息来自pod的imagePullSecrets
+// 拉取经过imagePuller，同一个镜像不会被重复拉取，拉取失败的镜像会进入退避
+// -----------------------------------------------------
+
+func (r *runtimeManager) pullImageForPod(ctx context.Context, pod *apis.Pod, imagePullPolicy minik8sTypes.ImagePullPolicyType, imageName string) error {
+	auths, _ := credentialprovider.KeyringForPod(r.secretGetter, pod).Lookup(imageName)
+	err := r.imagePuller.EnsureImage(ctx, imagePullPolicy, imageName, auths, r.pullEventHandler(pod, imagePullPolicy, imageName))
+	if errors.Is(err, imagemanager.ErrImagePullBackOff) {
+		r.recorder.Eventf(pod, events.EventTypeWarning, events.BackOffPullImage, "Back-off pulling image %q", imageName)
+	}
+	return err
+}
+
+func (r *runtimeManager) pullEventHandler(pod *apis.Pod, imagePullPolicy minik8sTypes.ImagePullPolicyType, imageName string) imagemanager.PullEventHandler {
+	return func(event imagemanager.PullEvent) {
+		switch event.Type {
+		case imagemanager.PullEventStarted:
+			r.recorder.Eventf(pod, events.EventTypeNormal, events.PullingImage, "Pulling image %q", imageName)
+		case imagemanager.PullEventCompleted:
+			r.recorder.Eventf(pod, events.EventTypeNormal, events.PulledImage,
+				"Successfully pulled image %q in %v (%d layers, %d bytes)",
+				imageName, event.Progress.Duration, event.Progress.Layers, event.Progress.BytesTotal)
+		case imagemanager.PullEventAlreadyPresent:
+			r.recorder.Eventf(pod, events.EventTypeNormal, events.PulledImage, "Container image %q already present on machine", imageName)
+		case imagemanager.PullEventFailed:
+			reason := events.FailedToPullImage
+			if imagePullPolicy == minik8sTypes.Never {
+				reason = events.ErrImageNeverPullPolicy
+			}
+			r.recorder.Eventf(pod, events.EventTypeWarning, reason, "Failed to pull image %q: %v", imageName, event.Err)
+		case imagemanager.PullEventProgress:
+			K8sLogger.Debugf("pulling image %s for pod %s/%s: %d/%d layers, %d/%d bytes", imageName, pod.Namespace, pod.Name,
+				event.Progress.LayersDone, event.Progress.Layers, event.Progress.BytesCurrent, event.Progress.BytesTotal)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"minik8s/minik8sTypes"
+	"minik8s/pkg/apis"
+	runtimeerrors "minik8s/pkg/kubelet/runtime/runtimeErrors"
+)
+
+// -----------------------------------------------------
+// 这个文件主要处理的是pod的init容器
+// init容器按照声明的顺序一个一个运行，前一个成功退出之后才创建下一个，全部成功之后才创建普通容器
+// 每次同步最多推进一步，正在运行的init容器等到下一次同步再检查
+// 失败的init容器按照重启策略重新运行，重启策略是Never的时候不再重试，pod的phase会变成Failed
+// -----------------------------------------------------
+
+// 推进pod的init容器，所有init容器都成功退出之后返回true
+func (r *runtimeManager) syncInitContainers(ctx context.Context, pod *apis.Pod, sandboxName string) (bool, error) {
+	if len(pod.Spec.InitContainers) == 0 || r.appContainersCreated(pod) {
+		// 普通容器已经创建过了（比如kubelet重启之后接管的pod），init容器不再运行
+		return true, nil
+	}
+	for _, container := range pod.Spec.InitContainers {
+		if id, ok := r.getContainer(pod.UID, container.Name); ok {
+			inspect, err := r.containerManager.InspectContainer(ctx, id)
+			if err != nil && !errors.Is(err, runtimeerrors.ErrNotFound) {
+				return false, err
+			}
+			if err == nil && inspect.State != nil {
+				switch {
+				case inspect.State.Running || inspect.State.Restarting:
+					return false, nil
+				case inspect.State.Status == "exited" && inspect.State.ExitCode == 0:
+					continue
+				case pod.Spec.RestartPolicy == minik8sTypes.Minik8sRestartPolicyNever:
+					return false, nil
+				}
+			}
+		}
+		// 还没有运行过，或者失败了需要重新运行
+		if err := r.createPodContainer(ctx, pod, container, sandboxName); err != nil {
+			return false, err
+		}
+		if err := r.startPodContainer(ctx, pod, container); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+func (r *runtimeManager) appContainersCreated(pod *apis.Pod) bool {
+	for _, container := range pod.Spec.Containers {
+		if _, ok := r.getContainer(pod.UID, container.Name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package runtime
+
+import (
+	"context"
+	"minik8s/minik8sTypes"
+	"minik8s/pkg/apis"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestSyncInitContainers(t *testing.T) {
+	daemon := &fakeInspectDaemon{inspects: map[string]types.ContainerJSON{
+		"init-running": statusTestInspect("init-running", types.ContainerState{Status: "running", Running: true}),
+		"init-done":    statusTestInspect("init-done", types.ContainerState{Status: "exited", ExitCode: 0}),
+		"init-failed":  statusTestInspect("init-failed", types.ContainerState{Status: "exited", ExitCode: 1}),
+	}}
+	r := newFakeRuntimeManager(t, daemon)
+	newPod := func(uid string, restartPolicy minik8sTypes.RestartPolicy) *apis.Pod {
+		return &apis.Pod{
+			ObjectMeta: apis.ObjectMeta{Name: "p", Namespace: "default", UID: uid},
+			Spec: apis.PodSpec{
+				RestartPolicy:  restartPolicy,
+				InitContainers: []apis.Container{{Name: "first"}, {Name: "second"}},
+				Containers:     []apis.Container{{Name: "web"}},
+			},
+		}
+	}
+	cases := []struct {
+		name       string
+		policy     minik8sTypes.RestartPolicy
+		containers map[string]string
+		want       bool
+	}{
+		{"first still running", "", map[string]string{"first": "init-running"}, false},
+		{"second still running", "", map[string]string{"first": "init-done", "second": "init-running"}, false},
+		{"all done", "", map[string]string{"first": "init-done", "second": "init-done"}, true},
+		{"failed and never restarted", minik8sTypes.Minik8sRestartPolicyNever, map[string]string{"first": "init-failed"}, false},
+		{"app containers already created", "", map[string]string{"web": "web"}, true},
+	}
+	for _, c := range cases {
+		pod := newPod(c.name, c.policy)
+		for name, id := range c.containers {
+			r.setContainer(pod.UID, name, id)
+		}
+		got, err := r.syncInitContainers(context.Background(), pod, "sandbox")
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if got != c.want {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestGetPodStatusWhileInitializing(t *testing.T) {
+	daemon := &fakeInspectDaemon{
+		fakeContainerDaemon: fakeContainerDaemon{containers: []types.Container{statusTestContainer("init0", "pod1", "init", 0)}},
+		inspects: map[string]types.ContainerJSON{
+			"init0": statusTestInspect("init0", types.ContainerState{Status: "running", Running: true}),
+		},
+	}
+	r := newFakeRuntimeManager(t, daemon)
+	pod := &apis.Pod{
+		ObjectMeta: apis.ObjectMeta{Name: "p", Namespace: "default", UID: "pod1"},
+		Spec: apis.PodSpec{
+			InitContainers: []apis.Container{{Name: "init"}},
+			Containers:     []apis.Container{{Name: "web"}},
+		},
+	}
+	status, err := r.GetPodStatus(context.Background(), pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := status.InitContainerStatuses[0]; s.State.Running == nil {
+		t.Errorf("expected init container to be running, got %+v", s.State)
+	}
+	if s := status.ContainerStatuses[0]; s.State.Waiting == nil || s.State.Waiting.Reason != apis.PodInitializing {
+		t.Errorf("expected web to wait for initialization, got %+v", s.State)
+	}
+}
@@ -0,0 +1,150 @@
+package runtime
+
+import (
+	"context"
+	"minik8s/minik8sTypes"
+	"minik8s/pkg/apis"
+	"minik8s/pkg/kubelet/metrics"
+	containermanager "minik8s/pkg/kubelet/runtime/containerManager"
+	"time"
+)
+
+// -----------------------------------------------------
+// 这个文件主要处理的是kubelet重启之后的状态恢复
+// 每个容器上都有pod的name/namespace/uid和podtype标签，
+// 再加上磁盘上的pod检查点，就可以重建内存中的pod、沙箱和容器表，
+// 这样正在运行的容器会被接管，而不是变成孤儿或者被重复创建
+// 已经退出的容器同样被接管，下一次同步的时候按照重启策略决定是否创建新的实例；
+// 已经不存在的沙箱和容器在下一次同步的时候重新创建
+// 沙箱还在但是已经退出的pod不会被自动重建
+// -----------------------------------------------------
+
+func (r *runtimeManager) RecoverPods(ctx context.Context) ([]*apis.Pod, error) {
+	checkpointed, err := r.checkpoint.LoadPods()
+	if err != nil {
+		K8sLogger.Errorln("RecoverPods error: ", err)
+		return nil, err
+	}
+	podsByUID := map[string]*apis.Pod{}
+	for _, pod := range checkpointed {
+		podsByUID[pod.UID] = pod
+	}
+
+	containers, err := r.containerManager.ListMinik8sContainer(ctx)
+	if err != nil {
+		K8sLogger.Errorln("RecoverPods error: ", err)
+		return nil, err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	currentAttempt := map[string]int{} // 容器id -> attempt，同一个容器有多个实例的时候接管attempt最大的
+	for _, c := range containers {
+		uid := c.Labels[minik8sTypes.KubernetesPodUIDLabel]
+		pod, ok := podsByUID[uid]
+		if !ok {
+			// 没有检查点的容器不知道完整的spec，留给垃圾回收处理
+			K8sLogger.Warnln("RecoverPods: container ", c.ID, " belongs to unknown pod ", uid)
+			continue
+		}
+		r.pods[uid] = pod
+		switch c.Labels[minik8sTypes.Minik8sPodTypeLabel] {
+		case minik8sTypes.Minik8sPausePodType:
+			r.sandboxes[uid] = c.ID
+		case minik8sTypes.Minik8sGenericPodType:
+			containerName := c.Labels[minik8sTypes.LabelsContainerName]
+			if r.containers[uid] == nil {
+				r.containers[uid] = map[string]string{}
+			}
+			attempt := containermanager.ContainerAttempt(c.Labels)
+			if id, ok := r.containers[uid][containerName]; ok && currentAttempt[id] > attempt {
+				continue
+			}
+			r.containers[uid][containerName] = c.ID
+			currentAttempt[c.ID] = attempt
+		}
+	}
+	// 正在运行的容器继续收集日志，从日志文件中最后一行的时间之后接着写
+	for _, c := range containers {
+		uid := c.Labels[minik8sTypes.KubernetesPodUIDLabel]
+		name := c.Labels[minik8sTypes.LabelsContainerName]
+		if c.State != containerStateRunning || r.containers[uid][name] != c.ID {
+			continue
+		}
+		if logPath := c.Labels[minik8sTypes.KubernetesContainerLogPathLabel]; logPath != "" {
+			r.logCollector.Start(c.ID, logPath)
+		}
+	}
+	for uid, pod := range r.pods {
+		K8sLogger.Infoln("RecoverPods: adopted pod ", pod.Namespace, "/", pod.Name, " uid ", uid,
+			" sandbox ", r.sandboxes[uid], " containers ", len(r.containers[uid]))
+	}
+	// 有检查点但是容器都不在了的pod也要返回，kubelet同步的时候会创建缺少的沙箱和容器
+	return checkpointed, nil
+}
+
+func (r *runtimeManager) setPod(pod *apis.Pod) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.pods[pod.UID] = pod
+}
+
+func (r *runtimeManager) deletePod(uid string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.pods, uid)
+	delete(r.sandboxes, uid)
+	delete(r.containers, uid)
+	delete(r.podStartTimes, uid)
+	delete(r.waiting, uid)
+	delete(r.terminationMessages, uid)
+}
+
+// 记录新pod第一次被同步的时间，创建沙箱失败重试的时候保留最早的时间
+func (r *runtimeManager) markPodStarting(uid string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.podStartTimes[uid]; !ok {
+		r.podStartTimes[uid] = time.Now()
+	}
+}
+
+// pod的所有容器都启动了，记录启动耗时，每个pod只记录一次
+func (r *runtimeManager) observePodStarted(uid string) {
+	r.lock.Lock()
+	start, ok := r.podStartTimes[uid]
+	delete(r.podStartTimes, uid)
+	r.lock.Unlock()
+	if ok {
+		metrics.PodStartDuration.Observe(metrics.SinceInSeconds(start))
+	}
+}
+
+func (r *runtimeManager) setSandbox(uid string, sandboxID string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.sandboxes[uid] = sandboxID
+}
+
+func (r *runtimeManager) getSandbox(uid string) (string, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	id, ok := r.sandboxes[uid]
+	return id, ok
+}
+
+func (r *runtimeManager) setContainer(uid string, containerName string, containerID string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.containers[uid] == nil {
+		r.containers[uid] = map[string]string{}
+	}
+	r.containers[uid][containerName] = containerID
+}
+
+func (r *runtimeManager) getContainer(uid string, containerName string) (string, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	id, ok := r.containers[uid][containerName]
+	return id, ok
+}
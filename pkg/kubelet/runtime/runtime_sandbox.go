@@ -2,10 +2,12 @@ package runtime
 
 import (
 	"context"
+	"errors"
 	"minik8s/logger"
 	"minik8s/minik8sTypes"
 	"minik8s/pkg/apis"
 	containerManager "minik8s/pkg/kubelet/runtime/containerManager"
+	runtimeerrors "minik8s/pkg/kubelet/runtime/runtimeErrors"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
@@ -17,7 +19,7 @@ import (
 // -----------------------------------------------------
 
 var (
-	K8sLogger = logger.K8sLogger
+	K8sLogger = logger.Named("runtime")
 )
 
 // 目的是生成一个pause容器的配置
@@ -30,8 +32,9 @@ func (r *runtimeManager) generateSandBoxConfig(pod *apis.Pod) (minik8sTypes.Conf
 			Name: pod.Name,
 			Uid:  podUID,
 		},
-		Labels:      newPodLabels(pod),
-		Annotations: newPodAnnotations(pod),
+		LogDirectory: r.podLogDir(podUID),
+		Labels:       newPodLabels(pod),
+		Annotations:  newPodAnnotations(pod),
 	}
 	//todo：dns配置
 	//hostname和domainname配置
@@ -46,14 +49,21 @@ func (r *runtimeManager) generateSandBoxConfig(pod *apis.Pod) (minik8sTypes.Conf
 	}
 	//组合成docker的config
 	config := minik8sTypes.Config{
-		Image:           minik8sTypes.Minik8sPauseImage,
+		Image:           r.sandboxConfig.PauseImage(),
 		Labels:          podSandboxConfig.Labels,
 		ExposedPorts:    sandboxExposePorts,
-		ImagePullPolicy: minik8sTypes.IfNotPresent,
+		ImagePullPolicy: r.sandboxConfig.ImagePullPolicy,
 	}
 	//组合成docker的hostconfig
+	//sysctl、dns、shm都是pod级别的，设置在pause容器上，其他容器共享pause容器的命名空间
 	hostConfig := minik8sTypes.HostConfig{
-		IpcMode: minik8sTypes.IpcModeShareable,
+		IpcMode:      minik8sTypes.IpcModeShareable,
+		Sysctls:      r.sandboxConfig.Sysctls,
+		DNS:          r.sandboxConfig.DNS,
+		DNSSearch:    r.sandboxConfig.DNSSearch,
+		DNSOptions:   r.sandboxConfig.DNSOptions,
+		CgroupParent: r.sandboxConfig.CgroupParent,
+		ShmSize:      r.sandboxConfig.ShmSize,
 	}
 	return config, hostConfig, nil
 }
@@ -85,10 +95,9 @@ func newPodAnnotations(pod *apis.Pod) map[string]string {
 
 // 创建一个沙箱返回一个pause容器id
 // 参照pkg/kubelet/kuberuntime/kuberuntime_sandbox.go
-func (r *runtimeManager) createPodSandbox(pod *apis.Pod) (SandboxContainerName string, err error) {
+func (r *runtimeManager) createPodSandbox(ctx context.Context, pod *apis.Pod) (SandboxContainerName string, err error) {
 	//创建一个容器管理器对象
 	cm := r.containerManager
-	ctx := context.Background()
 
 	//生成沙箱配置
 	config, hostcfg, err := r.generateSandBoxConfig(pod)
@@ -99,7 +108,7 @@ func (r *runtimeManager) createPodSandbox(pod *apis.Pod) (SandboxContainerName s
 	//创建一个容器的配置对象
 	SandboxContainerName = pod.Name + pod.UID
 	//拉取pause镜像
-	err = r.imagemanager.PullImage(ctx, minik8sTypes.IfNotPresent, minik8sTypes.Minik8sPauseImage)
+	err = r.pullImageForPod(ctx, pod, config.ImagePullPolicy, config.Image)
 	if err != nil {
 		K8sLogger.Errorln("CreateSandbox error: ", err)
 		return "", err
@@ -114,13 +123,19 @@ func (r *runtimeManager) createPodSandbox(pod *apis.Pod) (SandboxContainerName s
 	err = cm.StartContainer(ctx, ID)
 	if err != nil {
 		K8sLogger.Errorln("CreateSandbox error: ", err)
+		// 没有记录下来的pause容器要删掉，否则下次同步会因为重名一直创建失败
+		// ctx可能已经结束了，删除不能因此失败
+		if removeErr := cm.RemoveContainer(context.WithoutCancel(ctx), ID); removeErr != nil {
+			K8sLogger.Errorln("remove sandbox that failed to start error: ", removeErr)
+		}
 		return "", err
 	}
+	r.setSandbox(pod.UID, ID)
 	return
 }
 
 // 删除pod中的sandbox
-func (r *runtimeManager) removePodSandbox(pod *apis.Pod) error {
+func (r *runtimeManager) removePodSandbox(ctx context.Context, pod *apis.Pod) error {
 	filter := filters.NewArgs()
 	// 在filter中添加标签
 	// 四个标签：PodName、PodNamespace、PodUID、IfPause
@@ -128,7 +143,7 @@ func (r *runtimeManager) removePodSandbox(pod *apis.Pod) error {
 	filter.Add("label", minik8sTypes.KubernetesPodNamespaceLabel+"="+pod.Namespace)
 	filter.Add("label", minik8sTypes.KubernetesPodUIDLabel+"="+string(pod.UID))
 	filter.Add("label", minik8sTypes.Minik8sPodTypeLabel+"="+minik8sTypes.Minik8sPausePodType)
-	c, err := r.containerManager.ListContainerWithOpts(context.TODO(), types.ContainerListOptions{
+	c, err := r.containerManager.ListContainerWithOpts(ctx, types.ContainerListOptions{
 		All:     true,
 		Filters: filter,
 	})
@@ -137,7 +152,10 @@ func (r *runtimeManager) removePodSandbox(pod *apis.Pod) error {
 		return err
 	}
 	for _, container := range c {
-		err := r.containerManager.RemoveContainer(context.Background(), container.ID)
+		err := r.containerManager.RemoveContainer(ctx, container.ID)
+		if errors.Is(err, runtimeerrors.ErrNotFound) {
+			continue
+		}
 		if err != nil {
 			K8sLogger.Errorln("StopPodSandbox error: ", err)
 			return err
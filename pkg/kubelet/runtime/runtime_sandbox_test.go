@@ -0,0 +1,54 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"minik8s/pkg/apis"
+	"minik8s/pkg/kubelet/config"
+	"minik8s/pkg/kubelet/dockerClient/dockertest"
+	"minik8s/pkg/kubelet/events"
+	imagemanager "minik8s/pkg/kubelet/runtime/imageManager"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+// pause镜像已经存在，pause容器能创建但是启动失败
+type fakeSandboxDaemon struct {
+	fakeContainerDaemon
+}
+
+func (d *fakeSandboxDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/images/json"):
+		json.NewEncoder(w).Encode([]types.ImageSummary{{ID: "pause"}})
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/containers/create"):
+		json.NewEncoder(w).Encode(map[string]string{"Id": "sandbox"})
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/start"):
+		http.Error(w, `{"message": "failed to set up network"}`, http.StatusInternalServerError)
+	default:
+		d.fakeContainerDaemon.ServeHTTP(w, r)
+	}
+}
+
+// 启动失败的pause容器要删掉，否则下次同步会重名
+func TestCreatePodSandboxRemovesSandboxThatFailedToStart(t *testing.T) {
+	daemon := &fakeSandboxDaemon{}
+	r := newFakeRuntimeManager(t, daemon)
+	r.imagePuller = imagemanager.NewImagePuller(imagemanager.NewImageManager(dockertest.NewClient(t, daemon)), 0, 0, 0, 0)
+	r.recorder = events.NewRecorder()
+	r.sandboxConfig = config.DefaultSandboxConfig()
+	pod := &apis.Pod{ObjectMeta: apis.ObjectMeta{Name: "p", Namespace: "default", UID: "pod1"}}
+
+	if _, err := r.createPodSandbox(context.Background(), pod); err == nil {
+		t.Fatal("expected the sandbox to fail to start")
+	}
+	if _, ok := r.getSandbox(pod.UID); ok {
+		t.Fatal("sandbox that failed to start should not be recorded")
+	}
+	if len(daemon.removed) != 1 || daemon.removed[0] != "sandbox" {
+		t.Fatalf("expected the sandbox to be removed, removed %v", daemon.removed)
+	}
+}
@@ -0,0 +1,222 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"minik8s/minik8sTypes"
+	"minik8s/pkg/apis"
+	containermanager "minik8s/pkg/kubelet/runtime/containerManager"
+	imagemanager "minik8s/pkg/kubelet/runtime/imageManager"
+	runtimeerrors "minik8s/pkg/kubelet/runtime/runtimeErrors"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// -----------------------------------------------------
+// 这个文件主要处理的是pod中容器状态的生成
+// 每个容器的状态来自它在docker中最新的两个实例：最新的实例是当前状态，前一个实例是上一次退出的状态
+// 创建或者启动失败的容器没有新的实例，失败的原因记在内存里，作为Waiting状态的原因
+// -----------------------------------------------------
+
+// 容器状态中的id带上运行时的前缀，和k8s保持一致
+const dockerContainerIDPrefix = "docker://"
+
+const containerStateCreated = "created"
+
+func (r *runtimeManager) GetPodStatus(ctx context.Context, pod *apis.Pod) (apis.PodStatus, error) {
+	filter := filters.NewArgs()
+	filter.Add("label", minik8sTypes.KubernetesPodUIDLabel+"="+string(pod.UID))
+	filter.Add("label", minik8sTypes.Minik8sPodTypeLabel+"="+minik8sTypes.Minik8sGenericPodType)
+	res, err := r.containerManager.ListContainerWithOpts(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filter,
+	})
+	if err != nil {
+		K8sLogger.Errorln("GetPodStatus error: ", err)
+		return apis.PodStatus{}, err
+	}
+	// 容器名 -> 所有实例，attempt从大到小
+	instances := map[string][]types.Container{}
+	for _, c := range res {
+		name := c.Labels[minik8sTypes.LabelsContainerName]
+		instances[name] = append(instances[name], c)
+	}
+	for _, list := range instances {
+		containermanager.SortByAttempt(list)
+	}
+	r.pruneTerminationMessages(pod.UID, res)
+
+	status := apis.PodStatus{PodIP: r.podIP(ctx, pod)}
+	initialized := true
+	for _, container := range pod.Spec.InitContainers {
+		s, err := r.containerStatus(ctx, pod.UID, container, instances[container.Name])
+		if err != nil {
+			return apis.PodStatus{}, err
+		}
+		initialized = initialized && s.IsSucceeded()
+		status.InitContainerStatuses = append(status.InitContainerStatuses, s)
+	}
+	for _, container := range pod.Spec.Containers {
+		s, err := r.containerStatus(ctx, pod.UID, container, instances[container.Name])
+		if err != nil {
+			return apis.PodStatus{}, err
+		}
+		if !initialized && s.State.Waiting != nil && s.State.Waiting.Reason == apis.ContainerCreating {
+			// 普通容器在等init容器运行完
+			s.State.Waiting.Reason = apis.PodInitializing
+		}
+		status.ContainerStatuses = append(status.ContainerStatuses, s)
+	}
+	return status, nil
+}
+
+// pause容器的ip就是pod的ip，拿不到的时候返回空
+func (r *runtimeManager) podIP(ctx context.Context, pod *apis.Pod) string {
+	sandboxID, ok := r.getSandbox(pod.UID)
+	if !ok {
+		return ""
+	}
+	inspect, err := r.containerManager.InspectContainer(ctx, sandboxID)
+	if err != nil || inspect.NetworkSettings == nil {
+		return ""
+	}
+	if inspect.NetworkSettings.IPAddress != "" {
+		return inspect.NetworkSettings.IPAddress
+	}
+	for _, network := range inspect.NetworkSettings.Networks {
+		if network.IPAddress != "" {
+			return network.IPAddress
+		}
+	}
+	return ""
+}
+
+// instances是这个容器的所有实例，attempt从大到小
+func (r *runtimeManager) containerStatus(ctx context.Context, podUID string, container apis.Container, instances []types.Container) (apis.ContainerStatus, error) {
+	status := apis.ContainerStatus{
+		Name:  container.Name,
+		Image: container.Image,
+	}
+	waiting, failed := r.getContainerWaiting(podUID, container.Name)
+	if len(instances) == 0 {
+		if !failed {
+			waiting = apis.ContainerStateWaiting{Reason: apis.ContainerCreating}
+		}
+		status.State.Waiting = &waiting
+		return status, nil
+	}
+
+	current, err := r.containerManager.InspectContainer(ctx, instances[0].ID)
+	if err != nil {
+		return status, err
+	}
+	status.ContainerID = dockerContainerIDPrefix + current.ID
+	status.ImageID = current.Image
+	status.RestartCount = containermanager.ContainerAttempt(instances[0].Labels)
+	if len(instances) > 1 {
+		previous, err := r.containerManager.InspectContainer(ctx, instances[1].ID)
+		if err == nil {
+			status.LastTerminationState = r.instanceState(ctx, podUID, container, previous)
+		} else if !errors.Is(err, runtimeerrors.ErrNotFound) {
+			// 前一个实例可能刚好被垃圾回收了
+			return status, err
+		}
+	}
+	state := r.instanceState(ctx, podUID, container, current)
+	if failed && state.Running == nil {
+		// 新的实例没有创建或者启动成功，已经退出的当前实例作为上一次的状态
+		status.State.Waiting = &waiting
+		if state.Terminated != nil {
+			status.LastTerminationState = state
+		}
+	} else {
+		status.State = state
+	}
+	status.Started = status.State.Running != nil
+	return status, nil
+}
+
+// 一个实例的状态，已经退出的实例带上终止信息
+func (r *runtimeManager) instanceState(ctx context.Context, podUID string, container apis.Container, inspect types.ContainerJSON) apis.ContainerState {
+	state := containerState(inspect)
+	if terminated := state.Terminated; terminated != nil {
+		if message := r.terminationMessage(ctx, podUID, container, inspect, terminated.ExitCode); message != "" {
+			terminated.Message = message
+		}
+	}
+	return state
+}
+
+// 把docker中一个实例的状态转换成容器状态，退出的实例的message是docker启动失败的原因
+func containerState(inspect types.ContainerJSON) apis.ContainerState {
+	if inspect.ContainerJSONBase == nil || inspect.State == nil || inspect.State.Status == containerStateCreated {
+		return apis.ContainerState{Waiting: &apis.ContainerStateWaiting{Reason: apis.ContainerCreating}}
+	}
+	state := inspect.State
+	if state.Running || state.Restarting {
+		return apis.ContainerState{Running: &apis.ContainerStateRunning{StartedAt: parseDockerTime(state.StartedAt)}}
+	}
+	reason := apis.ContainerCompleted
+	switch {
+	case state.OOMKilled:
+		reason = apis.ContainerOOMKilled
+	case state.Error != "":
+		reason = apis.ContainerCannotRun
+	case state.ExitCode != 0:
+		reason = apis.ContainerError
+	}
+	return apis.ContainerState{Terminated: &apis.ContainerStateTerminated{
+		ExitCode:    state.ExitCode,
+		Reason:      reason,
+		Message:     state.Error,
+		StartedAt:   parseDockerTime(state.StartedAt),
+		FinishedAt:  parseDockerTime(state.FinishedAt),
+		ContainerID: dockerContainerIDPrefix + inspect.ID,
+	}}
+}
+
+// docker的时间是RFC3339格式，没有的时候是0001-01-01T00:00:00Z
+func parseDockerTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// 创建或者启动容器失败的原因，下一次成功启动之前容器一直处于这个Waiting状态
+func (r *runtimeManager) setContainerWaiting(podUID string, containerName string, reason string, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.waiting[podUID] == nil {
+		r.waiting[podUID] = map[string]apis.ContainerStateWaiting{}
+	}
+	r.waiting[podUID][containerName] = apis.ContainerStateWaiting{Reason: reason, Message: err.Error()}
+}
+
+func (r *runtimeManager) clearContainerWaiting(podUID string, containerName string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.waiting[podUID], containerName)
+}
+
+func (r *runtimeManager) getContainerWaiting(podUID string, containerName string) (apis.ContainerStateWaiting, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	waiting, ok := r.waiting[podUID][containerName]
+	return waiting, ok
+}
+
+// 拉取镜像失败的原因
+func imagePullWaitingReason(imagePullPolicy minik8sTypes.ImagePullPolicyType, err error) string {
+	switch {
+	case errors.Is(err, imagemanager.ErrImagePullBackOff):
+		return apis.ImagePullBackOff
+	case imagePullPolicy == minik8sTypes.Never && errors.Is(err, runtimeerrors.ErrNotFound):
+		return apis.ErrImageNeverPull
+	default:
+		return apis.ErrImagePull
+	}
+}
@@ -0,0 +1,138 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"minik8s/minik8sTypes"
+	"minik8s/pkg/apis"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+)
+
+// 在fakeContainerDaemon的基础上支持inspect和日志（只支持tty容器的原始输出）
+type fakeInspectDaemon struct {
+	fakeContainerDaemon
+	inspects map[string]types.ContainerJSON
+	logs     map[string]string
+}
+
+func (d *fakeInspectDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/logs") {
+		parts := strings.Split(r.URL.Path, "/")
+		w.Write([]byte(d.logs[parts[len(parts)-2]]))
+		return
+	}
+	if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/json") && !strings.HasSuffix(r.URL.Path, "/containers/json") {
+		parts := strings.Split(r.URL.Path, "/")
+		if inspect, ok := d.inspects[parts[len(parts)-2]]; ok {
+			json.NewEncoder(w).Encode(inspect)
+			return
+		}
+		http.Error(w, `{"message":"No such container"}`, http.StatusNotFound)
+		return
+	}
+	d.fakeContainerDaemon.ServeHTTP(w, r)
+}
+
+func statusTestContainer(id, uid, name string, attempt int) types.Container {
+	c := gcTestContainer(id, uid, minik8sTypes.Minik8sGenericPodType, name, "", time.Now())
+	c.Labels[minik8sTypes.KubernetesContainerAttemptLabel] = strconv.Itoa(attempt)
+	return c
+}
+
+func statusTestInspect(id string, state types.ContainerState) types.ContainerJSON {
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{ID: id, Image: "sha256:" + id, State: &state},
+		NetworkSettings:   &types.NetworkSettings{DefaultNetworkSettings: types.DefaultNetworkSettings{IPAddress: "10.0.0.8"}, Networks: map[string]*network.EndpointSettings{}},
+	}
+}
+
+func TestGetPodStatus(t *testing.T) {
+	started := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	finished := started.Add(time.Minute)
+	daemon := &fakeInspectDaemon{
+		fakeContainerDaemon: fakeContainerDaemon{containers: []types.Container{
+			statusTestContainer("web0", "pod1", "web", 0),
+			statusTestContainer("web1", "pod1", "web", 1),
+			statusTestContainer("job0", "pod1", "job", 0),
+		}},
+		inspects: map[string]types.ContainerJSON{
+			"pause": statusTestInspect("pause", types.ContainerState{Status: "running", Running: true}),
+			"web0": statusTestInspect("web0", types.ContainerState{Status: "exited", ExitCode: 2,
+				StartedAt: started.Format(time.RFC3339Nano), FinishedAt: finished.Format(time.RFC3339Nano)}),
+			"web1": statusTestInspect("web1", types.ContainerState{Status: "running", Running: true, StartedAt: finished.Format(time.RFC3339Nano)}),
+			"job0": statusTestInspect("job0", types.ContainerState{Status: "exited", ExitCode: 0}),
+		},
+	}
+	r := newFakeRuntimeManager(t, daemon)
+	r.setSandbox("pod1", "pause")
+	pod := &apis.Pod{
+		ObjectMeta: apis.ObjectMeta{Name: "p", Namespace: "default", UID: "pod1"},
+		Spec: apis.PodSpec{
+			InitContainers: []apis.Container{{Name: "init", Image: "busybox"}},
+			Containers:     []apis.Container{{Name: "web", Image: "nginx"}, {Name: "job", Image: "busybox"}, {Name: "sidecar", Image: "envoy"}},
+		},
+	}
+	r.setContainerWaiting("pod1", "job", apis.ErrImagePull, errors.New("manifest unknown"))
+	r.setContainerWaiting("pod1", "sidecar", apis.ImagePullBackOff, errors.New("back-off"))
+
+	status, err := r.GetPodStatus(context.Background(), pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.PodIP != "10.0.0.8" {
+		t.Errorf("unexpected pod ip %q", status.PodIP)
+	}
+	if len(status.InitContainerStatuses) != 1 || status.InitContainerStatuses[0].State.Waiting == nil ||
+		status.InitContainerStatuses[0].State.Waiting.Reason != apis.ContainerCreating {
+		t.Errorf("unexpected init container statuses %+v", status.InitContainerStatuses)
+	}
+	if len(status.ContainerStatuses) != 3 {
+		t.Fatalf("expected 3 container statuses, got %d", len(status.ContainerStatuses))
+	}
+
+	web := status.ContainerStatuses[0]
+	if web.Name != "web" || web.Image != "nginx" || web.ContainerID != "docker://web1" || web.ImageID != "sha256:web1" {
+		t.Errorf("unexpected web status %+v", web)
+	}
+	// 就绪状态由kubelet根据探针计算，运行时不设置
+	if web.Ready || !web.Started || web.RestartCount != 1 || web.State.Running == nil || !web.State.Running.StartedAt.Equal(finished) {
+		t.Errorf("web should be running after one restart: %+v", web)
+	}
+	last := web.LastTerminationState.Terminated
+	if last == nil || last.ExitCode != 2 || last.Reason != apis.ContainerError || !last.StartedAt.Equal(started) ||
+		!last.FinishedAt.Equal(finished) || last.ContainerID != "docker://web0" {
+		t.Errorf("unexpected last termination state %+v", web.LastTerminationState)
+	}
+
+	// 退出之后重新创建失败，当前实例作为上一次的状态
+	job := status.ContainerStatuses[1]
+	if job.State.Waiting == nil || job.State.Waiting.Reason != apis.ErrImagePull || job.State.Waiting.Message != "manifest unknown" {
+		t.Errorf("unexpected job state %+v", job.State)
+	}
+	if job.Ready || job.LastTerminationState.Terminated == nil || job.LastTerminationState.Terminated.Reason != apis.ContainerCompleted {
+		t.Errorf("unexpected job last state %+v", job.LastTerminationState)
+	}
+
+	sidecar := status.ContainerStatuses[2]
+	if sidecar.ContainerID != "" || sidecar.State.Waiting == nil || sidecar.State.Waiting.Reason != apis.ImagePullBackOff {
+		t.Errorf("unexpected sidecar status %+v", sidecar)
+	}
+
+	// 启动成功之后Waiting原因被清掉
+	r.clearContainerWaiting("pod1", "job")
+	status, err = r.GetPodStatus(context.Background(), pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job := status.ContainerStatuses[1]; job.State.Terminated == nil || job.State.Terminated.ExitCode != 0 {
+		t.Errorf("unexpected job state %+v", job.State)
+	}
+}
@@ -0,0 +1,132 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"minik8s/minik8sTypes"
+	"minik8s/pkg/apis"
+	"minik8s/pkg/kubelet/logs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+)
+
+// -----------------------------------------------------
+// 这个文件主要处理的是容器退出的原因
+// 每个容器实例都有一个宿主机上的终止信息文件，挂载到容器的terminationMessagePath，
+// 容器退出之后kubelet读取这个文件作为终止状态的message，
+// 文件为空并且策略是FallbackToLogsOnError的时候，异常退出的容器用日志的最后几行代替
+// 退出的实例不会再变化，读到的message按容器id缓存起来
+// -----------------------------------------------------
+
+// 和k8s保持一致
+const (
+	maxTerminationMessageBytes        = 4096
+	maxTerminationMessageLogBytes     = 2048
+	maxTerminationMessageLogLines     = 80
+	terminationMessageFilePermissions = 0666
+)
+
+// 容器中终止信息文件的路径，没有配置的时候使用默认值
+func terminationMessagePath(container apis.Container) string {
+	if container.TerminationMessagePath == "" {
+		return apis.DefaultTerminationMessagePath
+	}
+	return container.TerminationMessagePath
+}
+
+// 创建一个空的终止信息文件，容器里面的进程不一定是root，所以所有人都可以写
+func prepareTerminationMessageFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, nil, terminationMessageFilePermissions); err != nil {
+		return err
+	}
+	// WriteFile的权限会受到umask的影响
+	return os.Chmod(path, terminationMessageFilePermissions)
+}
+
+// 一个已经退出的实例的终止信息，按容器id缓存
+func (r *runtimeManager) terminationMessage(ctx context.Context, podUID string, container apis.Container, inspect types.ContainerJSON, exitCode int) string {
+	r.lock.RLock()
+	message, ok := r.terminationMessages[podUID][inspect.ID]
+	r.lock.RUnlock()
+	if ok {
+		return message
+	}
+
+	if inspect.Config != nil {
+		message = readTerminationMessageFile(inspect.Config.Labels[minik8sTypes.KubernetesContainerTerminationMessagePathLabel])
+	}
+	if message == "" && exitCode != 0 && container.TerminationMessagePolicy == apis.TerminationMessageFallbackToLogsOnError {
+		message = r.tailContainerLogs(ctx, inspect.ID)
+	}
+	if ctx.Err() != nil {
+		// 读日志被打断了，下次再读
+		return message
+	}
+
+	r.lock.Lock()
+	if r.terminationMessages[podUID] == nil {
+		r.terminationMessages[podUID] = map[string]string{}
+	}
+	r.terminationMessages[podUID][inspect.ID] = message
+	r.lock.Unlock()
+	return message
+}
+
+// 读取终止信息文件，最多读maxTerminationMessageBytes字节，文件不存在的时候返回空
+func readTerminationMessageFile(path string) string {
+	if path == "" {
+		return ""
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			K8sLogger.Warnln("read termination message ", path, " error: ", err)
+		}
+		return ""
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxTerminationMessageBytes))
+	if err != nil {
+		K8sLogger.Warnln("read termination message ", path, " error: ", err)
+		return ""
+	}
+	return string(data)
+}
+
+// 容器日志的最后maxTerminationMessageLogLines行，最多maxTerminationMessageLogBytes字节
+func (r *runtimeManager) tailContainerLogs(ctx context.Context, containerID string) string {
+	tail := int64(maxTerminationMessageLogLines)
+	var buf bytes.Buffer
+	err := logs.NewLogService(r.containerManager).GetContainerLogsByID(ctx, containerID, logs.LogOptions{TailLines: &tail}, &buf, &buf)
+	if err != nil {
+		K8sLogger.Warnln("read logs of container ", containerID, " for termination message error: ", err)
+		return ""
+	}
+	data := buf.Bytes()
+	if len(data) > maxTerminationMessageLogBytes {
+		data = data[len(data)-maxTerminationMessageLogBytes:]
+	}
+	return strings.TrimRight(string(data), "\r\n")
+}
+
+// 只保留还存在的实例的终止信息，被垃圾回收的实例不再需要
+func (r *runtimeManager) pruneTerminationMessages(podUID string, containers []types.Container) {
+	exists := map[string]bool{}
+	for _, c := range containers {
+		exists[c.ID] = true
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for id := range r.terminationMessages[podUID] {
+		if !exists[id] {
+			delete(r.terminationMessages[podUID], id)
+		}
+	}
+}
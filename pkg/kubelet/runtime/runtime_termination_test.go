@@ -0,0 +1,127 @@
+package runtime
+
+import (
+	"context"
+	"minik8s/minik8sTypes"
+	"minik8s/pkg/apis"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestPrepareTerminationMessageFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pod1", "web", "0.termination-log")
+	if err := prepareTerminationMessageFile(path); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 0 || info.Mode().Perm() != terminationMessageFilePermissions {
+		t.Fatalf("unexpected termination message file %v %d", info.Mode(), info.Size())
+	}
+	if terminationMessagePath(apis.Container{}) != apis.DefaultTerminationMessagePath {
+		t.Fatal("expected the default termination message path")
+	}
+}
+
+func TestTerminationMessages(t *testing.T) {
+	dir := t.TempDir()
+	writeMessage := func(name, message string) string {
+		path := filepath.Join(dir, name+".termination-log")
+		if err := os.WriteFile(path, []byte(message), 0666); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	exited := func(id string, exitCode int, oom bool, dockerErr string, messagePath string) types.ContainerJSON {
+		inspect := statusTestInspect(id, types.ContainerState{Status: "exited", ExitCode: exitCode, OOMKilled: oom, Error: dockerErr})
+		inspect.Config = &container.Config{Tty: true, Labels: map[string]string{
+			minik8sTypes.KubernetesContainerTerminationMessagePathLabel: messagePath,
+		}}
+		return inspect
+	}
+	var longLogs []string
+	for i := 0; i < 200; i++ {
+		longLogs = append(longLogs, "line "+strconv.Itoa(i)+strings.Repeat(".", 30))
+	}
+	daemon := &fakeInspectDaemon{
+		fakeContainerDaemon: fakeContainerDaemon{containers: []types.Container{
+			statusTestContainer("file0", "pod1", "file", 0),
+			statusTestContainer("oom0", "pod1", "oom", 0),
+			statusTestContainer("logs0", "pod1", "logs", 0),
+			statusTestContainer("ok0", "pod1", "ok", 0),
+			statusTestContainer("cannot0", "pod1", "cannot", 0),
+		}},
+		inspects: map[string]types.ContainerJSON{
+			"file0":   exited("file0", 3, false, "", writeMessage("file0", "config file missing")),
+			"oom0":    exited("oom0", 137, true, "", writeMessage("oom0", "")),
+			"logs0":   exited("logs0", 1, false, "", writeMessage("logs0", "")),
+			"ok0":     exited("ok0", 0, false, "", writeMessage("ok0", "")),
+			"cannot0": exited("cannot0", 127, false, "exec: \"nginx\": executable file not found in $PATH", ""),
+		},
+		logs: map[string]string{
+			"ok0": "bye\n",
+		},
+	}
+	// 日志超过2048字节时只保留最后的部分
+	daemon.logs["logs0"] = strings.Join(longLogs, "\n") + "\n"
+	r := newFakeRuntimeManager(t, daemon)
+	fallback := apis.TerminationMessageFallbackToLogsOnError
+	pod := &apis.Pod{
+		ObjectMeta: apis.ObjectMeta{Name: "p", Namespace: "default", UID: "pod1"},
+		Spec: apis.PodSpec{Containers: []apis.Container{
+			{Name: "file", TerminationMessagePolicy: fallback},
+			{Name: "oom"},
+			{Name: "logs", TerminationMessagePolicy: fallback},
+			{Name: "ok", TerminationMessagePolicy: fallback},
+			{Name: "cannot"},
+		}},
+	}
+	status, err := r.GetPodStatus(context.Background(), pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	terminated := map[string]*apis.ContainerStateTerminated{}
+	for _, s := range status.ContainerStatuses {
+		if s.State.Terminated == nil {
+			t.Fatalf("container %s should be terminated: %+v", s.Name, s.State)
+		}
+		terminated[s.Name] = s.State.Terminated
+	}
+
+	if c := terminated["file"]; c.ExitCode != 3 || c.Reason != apis.ContainerError || c.Message != "config file missing" {
+		t.Errorf("unexpected file state %+v", c)
+	}
+	if c := terminated["oom"]; c.ExitCode != 137 || c.Reason != apis.ContainerOOMKilled || c.Message != "" {
+		t.Errorf("unexpected oom state %+v", c)
+	}
+	if c := terminated["logs"]; c.Reason != apis.ContainerError || !strings.HasSuffix(c.Message, "line 199"+strings.Repeat(".", 30)) ||
+		len(c.Message) > maxTerminationMessageLogBytes || strings.Contains(c.Message, "line 0.") {
+		t.Errorf("unexpected logs state %+v", c)
+	}
+	if c := terminated["ok"]; c.Reason != apis.ContainerCompleted || c.Message != "" {
+		t.Errorf("logs should only be used for failed containers %+v", c)
+	}
+	if c := terminated["cannot"]; c.Reason != apis.ContainerCannotRun || !strings.Contains(c.Message, "executable file not found") {
+		t.Errorf("unexpected cannot-run state %+v", c)
+	}
+
+	// 退出的实例的终止信息被缓存，被垃圾回收之后清掉
+	os.WriteFile(daemon.inspects["file0"].Config.Labels[minik8sTypes.KubernetesContainerTerminationMessagePathLabel], []byte("changed"), 0666)
+	status, _ = r.GetPodStatus(context.Background(), pod)
+	if c := status.ContainerStatuses[0].State.Terminated; c.Message != "config file missing" {
+		t.Errorf("termination message should be cached, got %q", c.Message)
+	}
+	daemon.containers = daemon.containers[1:]
+	r.GetPodStatus(context.Background(), pod)
+	if _, ok := r.terminationMessages["pod1"]["file0"]; ok {
+		t.Error("termination message of a removed container should be pruned")
+	}
+}
@@ -1,9 +1,16 @@
 package runtime
 
 import (
+	"context"
 	"minik8s/minik8sTypes"
 	"minik8s/pkg/apis"
+	"minik8s/pkg/kubelet/checkpoint"
+	"minik8s/pkg/kubelet/config"
+	"minik8s/pkg/kubelet/events"
 	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
 )
 
 var testPod = apis.Pod{
@@ -42,17 +49,24 @@ var testPod = apis.Pod{
 	},
 }
 
+// 检查点和日志都写到临时目录中，不碰真正的 /var/lib/minik8s
+func testKubeletConfig(t *testing.T) *config.KubeletConfig {
+	cfg := config.DefaultKubeletConfig()
+	cfg.RootDir = t.TempDir()
+	return cfg
+}
+
 func TestCreatePod(t *testing.T) {
 	// 创建一个runtimeManager
 
-	r := NewRuntimeManager()
+	r := NewRuntimeManager(testKubeletConfig(t), events.NewRecorder())
 	// err := r.DeletePod(&testPod)
 	// if err != nil {
 	// 	t.Error(err)
 	// }
 
 	// 创建pod
-	s, err := r.createPod(&testPod)
+	s, err := r.createPod(context.Background(), &testPod)
 	if err != nil {
 		t.Error(err)
 	}
@@ -62,10 +76,56 @@ func TestCreatePod(t *testing.T) {
 
 func TestDeletePod(t *testing.T) {
 	// 创建一个runtimeManager
-	r := NewRuntimeManager()
-	err := r.killPod(&testPod)
+	r := NewRuntimeManager(testKubeletConfig(t), events.NewRecorder())
+	err := r.killPod(context.Background(), &testPod)
 	if err != nil {
 		t.Error(err)
 	}
 
 }
+
+func TestRecoverPods(t *testing.T) {
+	pause, generic := minik8sTypes.Minik8sPausePodType, minik8sTypes.Minik8sGenericPodType
+	uid := testPod.UID
+	web0 := statusTestContainer("web-0", uid, "testContainer-1", 0)
+	web0.State = "exited"
+	web1 := statusTestContainer("web-1", uid, "testContainer-1", 1)
+	web1.State = "exited"
+	daemon := &fakeContainerDaemon{containers: []types.Container{
+		gcTestContainer("sandbox", uid, pause, "", "running", time.Now()),
+		// 同一个容器有多个实例的时候接管attempt最大的
+		web1,
+		web0,
+		// 没有检查点的pod的容器不接管
+		gcTestContainer("orphan", "unknown", generic, "web", "running", time.Now()),
+	}}
+	r := newFakeRuntimeManager(t, daemon)
+	r.checkpoint = checkpoint.NewCheckpointManager(t.TempDir())
+	// 只有检查点没有容器的pod也要返回，交给kubelet重新创建
+	gone := apis.Pod{ObjectMeta: apis.ObjectMeta{Name: "gone", Namespace: "default", UID: "gone-uid"}}
+	for _, pod := range []*apis.Pod{&testPod, &gone} {
+		if err := r.checkpoint.SavePod(pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pods, err := r.RecoverPods(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 2 {
+		t.Fatalf("expected 2 recovered pods, got %d", len(pods))
+	}
+	if id, ok := r.getSandbox(uid); !ok || id != "sandbox" {
+		t.Errorf("expected sandbox to be adopted, got %q", id)
+	}
+	if id, ok := r.getContainer(uid, "testContainer-1"); !ok || id != "web-1" {
+		t.Errorf("expected the newest instance to be adopted, got %q", id)
+	}
+	if _, ok := r.pods["unknown"]; ok {
+		t.Error("containers without a checkpoint should not be adopted")
+	}
+	if _, ok := r.getSandbox(gone.UID); ok {
+		t.Error("pod without containers should not have a sandbox")
+	}
+}
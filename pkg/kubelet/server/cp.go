@@ -0,0 +1,81 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"minik8s/pkg/kubelet/cp"
+	"minik8s/pkg/kubelet/streaming"
+	"net/http"
+
+	"github.com/docker/docker/api/types"
+)
+
+// HEAD /cp/{namespace}/{pod}/{container}?path=/etc   路径的信息放在响应头里
+// GET  /cp/{namespace}/{pod}/{container}?path=/etc   返回路径的tar包
+// PUT  /cp/{namespace}/{pod}/{container}?path=/etc   把请求体的tar包解压到这个目录下
+// 和exec一样没有认证，拒绝浏览器发起的跨站请求，防止网页借用户的网络读写容器里的文件
+func (s *Server) handleCopy(w http.ResponseWriter, r *http.Request) {
+	if !streaming.NoBrowserOrigin(r) {
+		http.Error(w, "requests from browsers are not allowed", http.StatusForbidden)
+		return
+	}
+	parts := splitPath(r.URL.Path, "/cp/", 3)
+	if parts == nil {
+		http.Error(w, "path must be /cp/{namespace}/{pod}/{container}", http.StatusBadRequest)
+		return
+	}
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "path is required", http.StatusBadRequest)
+		return
+	}
+	podUID, ok := s.lookupPod(w, parts[0], parts[1])
+	if !ok {
+		return
+	}
+	container := parts[2]
+	switch r.Method {
+	case http.MethodHead:
+		stat, err := s.copier.Stat(r.Context(), podUID, container, path)
+		if err != nil {
+			writeCopyError(w, err)
+			return
+		}
+		setPathStat(w, stat)
+	case http.MethodGet:
+		rc, stat, err := s.copier.CopyFrom(r.Context(), podUID, container, path)
+		if err != nil {
+			writeCopyError(w, err)
+			return
+		}
+		defer rc.Close()
+		setPathStat(w, stat)
+		w.Header().Set("Content-Type", "application/x-tar")
+		if _, err := io.Copy(w, rc); err != nil {
+			K8sLogger.Errorln("copy from container error: ", err)
+		}
+	case http.MethodPut:
+		if err := s.copier.CopyTo(r.Context(), podUID, container, path, r.Body); err != nil {
+			writeCopyError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func setPathStat(w http.ResponseWriter, stat types.ContainerPathStat) {
+	if value, err := cp.EncodePathStat(stat); err == nil {
+		w.Header().Set(cp.PathStatHeader, value)
+	}
+}
+
+func writeCopyError(w http.ResponseWriter, err error) {
+	K8sLogger.Errorln("copy error: ", err)
+	if errors.Is(err, cp.ErrContainerNotFound) || errors.Is(err, cp.ErrPathNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"minik8s/pkg/kubelet/cp"
+	"minik8s/pkg/kubelet/dockerClient/dockertest"
+	containermanager "minik8s/pkg/kubelet/runtime/containerManager"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+// 模拟docker daemon：只有一个容器，容器里只有/etc这个目录
+func newCopyTestServer(t *testing.T, received *[]byte) *httptest.Server {
+	daemon := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			json.NewEncoder(w).Encode([]types.Container{{ID: "c1", Created: 1}})
+		case strings.HasSuffix(r.URL.Path, "/archive"):
+			if r.Method == http.MethodPut {
+				*received, _ = io.ReadAll(r.Body)
+				return
+			}
+			if r.URL.Query().Get("path") != "/etc" {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"message":"Could not find the file"}`))
+				return
+			}
+			stat, _ := json.Marshal(types.ContainerPathStat{Name: "etc", Mode: 0755 | 1<<31})
+			w.Header().Set("X-Docker-Container-Path-Stat", base64.StdEncoding.EncodeToString(stat))
+			if r.Method == http.MethodGet {
+				w.Write([]byte("tar content"))
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	cm := containermanager.NewContainerManager(dockertest.NewClient(t, daemon))
+	server := httptest.NewServer(NewServer(cm, nil, nil, func(namespace, name string) (string, bool) { return "pod1", true }))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHandleCopy(t *testing.T) {
+	var received []byte
+	server := newCopyTestServer(t, &received)
+	url := server.URL + "/cp/default/web/app?path=/etc"
+
+	resp, err := http.Head(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	stat, err := cp.DecodePathStat(resp.Header.Get(cp.PathStatHeader))
+	if resp.StatusCode != http.StatusOK || err != nil || stat.Name != "etc" {
+		t.Fatalf("unexpected HEAD response %d, stat %+v (%v)", resp.StatusCode, stat, err)
+	}
+
+	resp, err = http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "tar content" || resp.Header.Get("Content-Type") != "application/x-tar" {
+		t.Fatalf("unexpected GET response %d %q %q", resp.StatusCode, resp.Header.Get("Content-Type"), body)
+	}
+
+	req, _ := http.NewRequest(http.MethodPut, url, strings.NewReader("uploaded"))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(received) != "uploaded" {
+		t.Fatalf("unexpected PUT response %d, daemon received %q", resp.StatusCode, received)
+	}
+}
+
+func TestHandleCopyErrors(t *testing.T) {
+	var received []byte
+	server := newCopyTestServer(t, &received)
+	for _, c := range []struct {
+		method string
+		path   string
+		origin string
+		code   int
+	}{
+		{http.MethodGet, "/cp/default/web/app?path=/missing", "", http.StatusNotFound},
+		{http.MethodGet, "/cp/default/web/app", "", http.StatusBadRequest},
+		{http.MethodGet, "/cp/default/web?path=/etc", "", http.StatusBadRequest},
+		{http.MethodPost, "/cp/default/web/app?path=/etc", "", http.StatusMethodNotAllowed},
+		// 浏览器发起的跨站请求
+		{http.MethodPut, "/cp/default/web/app?path=/etc", "http://evil.example.com", http.StatusForbidden},
+	} {
+		req, _ := http.NewRequest(c.method, server.URL+c.path, nil)
+		if c.origin != "" {
+			req.Header.Set("Origin", c.origin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.code {
+			t.Errorf("%s %s: expected %d, got %d", c.method, c.path, c.code, resp.StatusCode)
+		}
+	}
+}
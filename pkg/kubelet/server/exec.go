@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"minik8s/pkg/kubelet/streaming"
+	"net/http"
+)
+
+// GET /exec/{namespace}/{pod}/{container}?command=sh&command=-c&command=ls&stdin=true&tty=true
+// 需要升级成websocket，协议见streaming包
+func (s *Server) handleExec(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := splitPath(r.URL.Path, "/exec/", 3)
+	if parts == nil {
+		http.Error(w, "path must be /exec/{namespace}/{pod}/{container}", http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	opts := streaming.ExecOptions{Command: query["command"]}
+	var err error
+	if opts.Stdin, err = parseBool(query, "stdin"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if opts.TTY, err = parseBool(query, "tty"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(opts.Command) == 0 {
+		http.Error(w, "command is required", http.StatusBadRequest)
+		return
+	}
+	podUID, ok := s.lookupPod(w, parts[0], parts[1])
+	if !ok {
+		return
+	}
+	streaming.Serve(w, r, opts.Stdin, func(ctx context.Context, streams streaming.Streams) (int, error) {
+		return s.streamer.Exec(ctx, podUID, parts[2], opts, streams)
+	})
+}
+
+// GET /attach/{namespace}/{pod}/{container}?stdin=true
+// 是否是tty由容器创建时的配置决定
+func (s *Server) handleAttach(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := splitPath(r.URL.Path, "/attach/", 3)
+	if parts == nil {
+		http.Error(w, "path must be /attach/{namespace}/{pod}/{container}", http.StatusBadRequest)
+		return
+	}
+	stdin, err := parseBool(r.URL.Query(), "stdin")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	podUID, ok := s.lookupPod(w, parts[0], parts[1])
+	if !ok {
+		return
+	}
+	streaming.Serve(w, r, stdin, func(ctx context.Context, streams streaming.Streams) (int, error) {
+		return s.streamer.Attach(ctx, podUID, parts[2], stdin, streams)
+	})
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"minik8s/pkg/kubelet/logs"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// GET /containerLogs/{namespace}/{pod}/{container}?follow=true&tailLines=10&sinceSeconds=60&limitBytes=1024&timestamps=true&previous=true
+func (s *Server) handleContainerLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := splitPath(r.URL.Path, "/containerLogs/", 3)
+	if parts == nil {
+		http.Error(w, "path must be /containerLogs/{namespace}/{pod}/{container}", http.StatusBadRequest)
+		return
+	}
+	opts, err := parseLogOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	podUID, ok := s.lookupPod(w, parts[0], parts[1])
+	if !ok {
+		return
+	}
+	// 找不到容器的时候还没有写任何内容，还可以返回404
+	w.Header().Set("Content-Type", "text/plain")
+	out := newFlushWriter(w)
+	err = s.logService.GetContainerLogs(r.Context(), podUID, parts[2], opts, out, out)
+	if err != nil {
+		K8sLogger.Errorln("get container logs error: ", err)
+		if errors.Is(err, logs.ErrContainerNotFound) || errors.Is(err, logs.ErrNoPreviousInstance) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func parseLogOptions(query url.Values) (logs.LogOptions, error) {
+	opts := logs.LogOptions{}
+	var err error
+	if opts.Follow, err = parseBool(query, "follow"); err != nil {
+		return opts, err
+	}
+	if opts.Timestamps, err = parseBool(query, "timestamps"); err != nil {
+		return opts, err
+	}
+	if opts.Previous, err = parseBool(query, "previous"); err != nil {
+		return opts, err
+	}
+	if opts.TailLines, err = parseNonNegativeInt(query, "tailLines"); err != nil {
+		return opts, err
+	}
+	if opts.SinceSeconds, err = parseNonNegativeInt(query, "sinceSeconds"); err != nil {
+		return opts, err
+	}
+	if opts.LimitBytes, err = parseNonNegativeInt(query, "limitBytes"); err != nil {
+		return opts, err
+	}
+	if opts.Follow && opts.Previous {
+		return opts, fmt.Errorf("follow and previous can not be used together")
+	}
+	return opts, nil
+}
+
+func parseBool(query url.Values, key string) (bool, error) {
+	value := query.Get(key)
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q", key, value)
+	}
+	return b, nil
+}
+
+func parseNonNegativeInt(query url.Values, key string) (*int64, error) {
+	value := query.Get(key)
+	if value == "" {
+		return nil, nil
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || i < 0 {
+		return nil, fmt.Errorf("invalid %s %q", key, value)
+	}
+	return &i, nil
+}
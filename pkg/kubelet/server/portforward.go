@@ -0,0 +1,33 @@
+package server
+
+import (
+	"errors"
+	"minik8s/pkg/kubelet/portforward"
+	"net/http"
+)
+
+// GET /portForward/{namespace}/{pod}
+// 需要升级成websocket，一条连接上可以转发多个tcp连接，协议见portforward包
+func (s *Server) handlePortForward(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := splitPath(r.URL.Path, "/portForward/", 2)
+	if parts == nil {
+		http.Error(w, "path must be /portForward/{namespace}/{pod}", http.StatusBadRequest)
+		return
+	}
+	// 升级之前先找到pod，找不到的时候还可以返回404
+	dial, err := s.forwarder.PodDialer(r.Context(), parts[0], parts[1])
+	if err != nil {
+		K8sLogger.Errorln("port forward error: ", err)
+		if errors.Is(err, portforward.ErrPodNotFound) || errors.Is(err, portforward.ErrNoPodIP) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	portforward.Serve(w, r, dial)
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"minik8s/logger"
+	"minik8s/pkg/kubelet/cp"
+	"minik8s/pkg/kubelet/logs"
+	"minik8s/pkg/kubelet/portforward"
+	containermanager "minik8s/pkg/kubelet/runtime/containerManager"
+	"minik8s/pkg/kubelet/stats"
+	"minik8s/pkg/kubelet/streaming"
+	"net/http"
+	"strings"
+	"time"
+)
+
+/*
+	kubelet的http服务，提供容器日志、exec、attach、端口转发、拷贝文件、资源统计等接口
+	路径和k8s的kubelet保持一致，比如 /containerLogs/{namespace}/{pod}/{container}
+*/
+
+var (
+	K8sLogger = logger.Named("server")
+)
+
+type Server struct {
+	mux        *http.ServeMux
+	logService *logs.LogService
+	streamer   *streaming.Streamer
+	forwarder  *portforward.Forwarder
+	copier     *cp.Copier
+	stats      *stats.Provider
+	// 返回容器运行时不可用的原因，可用的时候返回nil
+	runtimeHealth func() error
+	podUID        PodUIDLookup
+}
+
+// 按照namespace和名字找到这个节点上的pod的uid，pod不在这个节点上的时候返回false
+// 容器都是按uid查找的，同名的pod被删掉重建之后，旧pod还没被回收的容器不会被找到
+type PodUIDLookup func(namespace, name string) (string, bool)
+
+func NewServer(cm *containermanager.ContainerManager, statsProvider *stats.Provider, runtimeHealth func() error, podUID PodUIDLookup) *Server {
+	s := &Server{
+		mux:           http.NewServeMux(),
+		logService:    logs.NewLogService(cm),
+		streamer:      streaming.NewStreamer(cm),
+		forwarder:     portforward.NewForwarder(cm),
+		copier:        cp.NewCopier(cm),
+		stats:         statsProvider,
+		runtimeHealth: runtimeHealth,
+		podUID:        podUID,
+	}
+	s.mux.HandleFunc("/healthz", s.handleHealthz)
+	s.mux.HandleFunc("/containerLogs/", s.handleContainerLogs)
+	s.mux.HandleFunc("/exec/", s.handleExec)
+	s.mux.HandleFunc("/attach/", s.handleAttach)
+	s.mux.HandleFunc("/portForward/", s.handlePortForward)
+	s.mux.HandleFunc("/cp/", s.handleCopy)
+	s.mux.HandleFunc("/stats/summary", s.handleStatsSummary)
+	// GET查看、PUT {"level":"debug"} 修改日志级别，只接受本机的请求
+	s.mux.Handle("/debug/loglevel", logger.LevelHandler())
+	return s
+}
+
+// docker不可用的时候返回503
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if s.runtimeHealth != nil {
+		if err := s.runtimeHealth(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+	}
+	w.Write([]byte("ok"))
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// 监听addr直到ctx结束，结束的时候等待正在处理的请求完成
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	srv := &http.Server{Addr: addr, Handler: s}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	K8sLogger.Infoln("kubelet server listening on ", addr)
+	err := srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// 找到pod的uid，找不到的时候返回404
+func (s *Server) lookupPod(w http.ResponseWriter, namespace, name string) (string, bool) {
+	uid, ok := s.podUID(namespace, name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("pod %s/%s not found", namespace, name), http.StatusNotFound)
+	}
+	return uid, ok
+}
+
+// 把 /prefix/a/b/c 拆成 [a b c]，段数不对返回nil
+func splitPath(path string, prefix string, n int) []string {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, prefix), "/"), "/")
+	if len(parts) != n {
+		return nil
+	}
+	for _, p := range parts {
+		if p == "" {
+			return nil
+		}
+	}
+	return parts
+}
+
+// 每次写之后都flush，follow日志的时候客户端才能实时看到
+type flushWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func newFlushWriter(w http.ResponseWriter) *flushWriter {
+	fw := &flushWriter{w: w}
+	if flusher, ok := w.(http.Flusher); ok {
+		fw.flusher = flusher
+	}
+	return fw
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if fw.flusher != nil {
+		fw.flusher.Flush()
+	}
+	return n, err
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestParseLogOptions(t *testing.T) {
+	opts, err := parseLogOptions(url.Values{"follow": {"true"}, "tailLines": {"5"}, "limitBytes": {"100"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !opts.Follow || *opts.TailLines != 5 || *opts.LimitBytes != 100 || opts.SinceSeconds != nil {
+		t.Fatalf("unexpected options %+v", opts)
+	}
+	for _, bad := range []url.Values{
+		{"tailLines": {"-1"}},
+		{"sinceSeconds": {"abc"}},
+		{"follow": {"yes please"}},
+		{"follow": {"true"}, "previous": {"true"}},
+	} {
+		if _, err := parseLogOptions(bad); err == nil {
+			t.Errorf("expected error for %v", bad)
+		}
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	parts := splitPath("/containerLogs/default/web/nginx", "/containerLogs/", 3)
+	if len(parts) != 3 || parts[0] != "default" || parts[1] != "web" || parts[2] != "nginx" {
+		t.Fatalf("unexpected parts %v", parts)
+	}
+	if splitPath("/containerLogs/default//nginx", "/containerLogs/", 3) != nil {
+		t.Fatal("expected nil for empty segment")
+	}
+	if splitPath("/containerLogs/default/web", "/containerLogs/", 3) != nil {
+		t.Fatal("expected nil for missing segment")
+	}
+}
+
+// 不在这个节点上的pod直接返回404，不会去docker里找同名pod留下的容器
+func TestHandleContainerLogsUnknownPod(t *testing.T) {
+	s := NewServer(nil, nil, nil, func(namespace, name string) (string, bool) { return "", false })
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/containerLogs/default/web/nginx", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d %s", w.Code, w.Body.String())
+	}
+}
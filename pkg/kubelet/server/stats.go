@@ -0,0 +1,25 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// GET /stats/summary
+// 返回节点和节点上所有pod、容器的资源使用情况，数据来自最近一次采样
+func (s *Server) handleStatsSummary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	summary, err := s.stats.GetSummary(r.Context())
+	if err != nil {
+		K8sLogger.Errorln("get stats summary error: ", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(summary); err != nil {
+		K8sLogger.Errorln("write stats summary error: ", err)
+	}
+}
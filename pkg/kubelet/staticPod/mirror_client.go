@@ -0,0 +1,83 @@
+package staticpod
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"minik8s/minik8sTypes"
+	"minik8s/pkg/apis"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 静态pod不经过apiserver创建，为了让apiserver能看到它们，kubelet会在apiserver中创建对应的mirror pod
+type MirrorClient interface {
+	CreateMirrorPod(pod *apis.Pod) error
+	DeleteMirrorPod(pod *apis.Pod) error
+}
+
+type mirrorClient struct {
+	apiServerAddress string
+	httpClient       *http.Client
+}
+
+func NewMirrorClient(apiServerAddress string) MirrorClient {
+	return &mirrorClient{
+		apiServerAddress: strings.TrimSuffix(apiServerAddress, "/"),
+		httpClient:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (mc *mirrorClient) podsURL(namespace string) string {
+	return mc.apiServerAddress + "/api/v1/namespaces/" + url.PathEscape(namespace) + "/pods"
+}
+
+// 在apiserver中创建mirror pod，已经存在不算错误
+func (mc *mirrorClient) CreateMirrorPod(pod *apis.Pod) error {
+	mirror := *pod
+	mirror.Annotations = map[string]string{}
+	for k, v := range pod.Annotations {
+		mirror.Annotations[k] = v
+	}
+	mirror.Annotations[minik8sTypes.ConfigMirrorAnnotation] = pod.UID
+	body, err := json.Marshal(&mirror)
+	if err != nil {
+		return err
+	}
+	resp, err := mc.httpClient.Post(mc.podsURL(pod.Namespace), "application/json", bytes.NewReader(body))
+	if err != nil {
+		K8sLogger.Errorln("CreateMirrorPod error: ", err)
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusConflict {
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("create mirror pod %s/%s: unexpected status %s", pod.Namespace, pod.Name, resp.Status)
+	}
+	return nil
+}
+
+// 删除apiserver中的mirror pod，不存在不算错误
+func (mc *mirrorClient) DeleteMirrorPod(pod *apis.Pod) error {
+	req, err := http.NewRequest(http.MethodDelete, mc.podsURL(pod.Namespace)+"/"+url.PathEscape(pod.Name), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := mc.httpClient.Do(req)
+	if err != nil {
+		K8sLogger.Errorln("DeleteMirrorPod error: ", err)
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("delete mirror pod %s/%s: unexpected status %s", pod.Namespace, pod.Name, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package staticpod
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"minik8s/logger"
+	"minik8s/minik8sTypes"
+	"minik8s/pkg/apis"
+	"minik8s/pkg/uuid"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+/*
+	静态pod：kubelet定期扫描一个目录，目录下的每个清单文件描述一个pod
+	文件新增就创建pod，文件删除就删除pod，
+	文件内容变化的时候uid也会变化，相当于删掉旧的pod再创建一个新的pod
+*/
+
+var (
+	K8sLogger = logger.Named("staticpod")
+)
+
+type Source struct {
+	path     string
+	nodeName string
+	period   time.Duration
+}
+
+func NewSource(path string, nodeName string, period time.Duration) *Source {
+	return &Source{
+		path:     path,
+		nodeName: nodeName,
+		period:   period,
+	}
+}
+
+// 定期扫描目录，目录中的pod集合发生变化的时候把完整的pod列表发到updates中
+func (s *Source) Run(ctx context.Context, updates chan<- []*apis.Pod) {
+	ticker := time.NewTicker(s.period)
+	defer ticker.Stop()
+	var lastUIDs []string
+	first := true
+	for {
+		pods, err := s.ListPods()
+		if err != nil {
+			K8sLogger.Errorln("static pod source error: ", err)
+		} else if uids := podUIDs(pods); first || !equalStrings(uids, lastUIDs) {
+			// 第一次扫描即使是空的也要发出去，kubelet需要知道哪些恢复出来的静态pod已经不存在了
+			first = false
+			lastUIDs = uids
+			select {
+			case updates <- pods:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// 读取目录中所有的pod清单，解析失败的文件会被跳过
+func (s *Source) ListPods() ([]*apis.Pod, error) {
+	entries, err := os.ReadDir(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var pods []*apis.Pod
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		switch filepath.Ext(name) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		pod, err := s.readPodFile(filepath.Join(s.path, name))
+		if err != nil {
+			K8sLogger.Errorln("read static pod file ", name, " error: ", err)
+			continue
+		}
+		pods = append(pods, pod)
+	}
+	return pods, nil
+}
+
+func (s *Source) readPodFile(path string) (*apis.Pod, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pod, err := decodePod(path, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePod(pod); err != nil {
+		return nil, err
+	}
+	s.applyDefaults(pod, path, data)
+	return pod, nil
+}
+
+func decodePod(path string, data []byte) (*apis.Pod, error) {
+	pod := &apis.Pod{}
+	if filepath.Ext(path) == ".json" {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		if err := decoder.Decode(pod); err != nil {
+			return nil, err
+		}
+		return pod, nil
+	}
+	if err := yaml.Unmarshal(data, pod); err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+func validatePod(pod *apis.Pod) error {
+	if pod.Kind != "" && pod.Kind != "Pod" {
+		return fmt.Errorf("unsupported kind %s", pod.Kind)
+	}
+	if pod.Name == "" {
+		return fmt.Errorf("pod name is empty")
+	}
+	if len(pod.Spec.Containers) == 0 {
+		return fmt.Errorf("pod %s has no containers", pod.Name)
+	}
+	names := map[string]bool{}
+	for _, c := range append(append([]apis.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...) {
+		if c.Name == "" || c.Image == "" {
+			return fmt.Errorf("pod %s has a container without name or image", pod.Name)
+		}
+		if names[c.Name] {
+			return fmt.Errorf("pod %s has duplicate container name %s", pod.Name, c.Name)
+		}
+		names[c.Name] = true
+	}
+	return nil
+}
+
+// 给静态pod补上默认值，uid由节点名、文件路径和文件内容决定
+func (s *Source) applyDefaults(pod *apis.Pod, path string, data []byte) {
+	apis.SetPodDefaults(pod)
+	// 和k8s一样，静态pod的名字后面加上节点名，避免不同节点上的同名静态pod冲突
+	pod.Name = pod.Name + "-" + s.nodeName
+	content := append([]byte(s.nodeName+"\n"+path+"\n"), data...)
+	pod.UID = uuid.NewUIDFromContent(content)
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[minik8sTypes.ConfigSourceAnnotation] = minik8sTypes.FileSource
+	pod.Annotations[minik8sTypes.ConfigHashAnnotation] = pod.UID
+}
+
+// 判断一个pod是不是静态pod
+func IsStaticPod(pod *apis.Pod) bool {
+	return pod.Annotations[minik8sTypes.ConfigSourceAnnotation] == minik8sTypes.FileSource
+}
+
+func podUIDs(pods []*apis.Pod) []string {
+	uids := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		uids = append(uids, pod.UID)
+	}
+	sort.Strings(uids)
+	return uids
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package staticpod
+
+import (
+	"minik8s/minik8sTypes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testManifest = `
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+  - name: nginx
+    image: docker.io/library/nginx
+    ports:
+    - containerPort: 80
+`
+
+func TestListPods(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "web.yaml"), []byte(testManifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 隐藏文件和其他后缀的文件不会被当成清单
+	if err := os.WriteFile(filepath.Join(dir, ".web.yaml.swp"), []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewSource(dir, "node1", 0)
+	pods, err := s.ListPods()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 1 {
+		t.Fatalf("expected 1 pod, got %d", len(pods))
+	}
+	pod := pods[0]
+	if pod.Name != "web-node1" || pod.Namespace != "default" || pod.UID == "" {
+		t.Fatalf("unexpected pod meta: %+v", pod.ObjectMeta)
+	}
+	if pod.Spec.Containers[0].Ports[0].ContainerPort != "80" {
+		t.Fatalf("unexpected port: %+v", pod.Spec.Containers[0].Ports)
+	}
+	if !IsStaticPod(pod) || pod.Spec.RestartPolicy != minik8sTypes.Minik8sRestartPolicyAlways {
+		t.Fatalf("defaults not applied: %+v", pod)
+	}
+
+	// 内容不变uid不变
+	again, err := s.ListPods()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again[0].UID != pod.UID {
+		t.Fatal("uid changed without content change")
+	}
+	// 内容变化uid也变化
+	if err := os.WriteFile(filepath.Join(dir, "web.yaml"), []byte(testManifest+"  restartPolicy: Never\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := s.ListPods()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed[0].UID == pod.UID {
+		t.Fatal("uid did not change with content")
+	}
+}
+
+func TestListPodsSkipsInvalid(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "bad.yaml"), []byte("metadata:\n  name: bad\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pods, err := NewSource(dir, "node1", 0).ListPods()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 0 {
+		t.Fatalf("expected invalid manifest to be skipped, got %d pods", len(pods))
+	}
+}
@@ -0,0 +1,73 @@
+package stats
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 把最近一次采样中每个容器的cpu和内存暴露成prometheus指标
+// 指标的值直接来自采样结果，所以实现成Collector而不是在每次采样的时候去设置gauge，这样已经删除的容器不会留下旧的数据
+
+var (
+	containerCPUUsageDesc = prometheus.NewDesc(
+		"container_cpu_usage_seconds_total",
+		"Cumulative cpu time consumed by the container in core-seconds.",
+		[]string{"container", "pod", "namespace"}, nil,
+	)
+	containerCPUUsageRateDesc = prometheus.NewDesc(
+		"container_cpu_usage_nano_cores",
+		"CPU usage rate of the container in nano cores, averaged over the last sampling interval.",
+		[]string{"container", "pod", "namespace"}, nil,
+	)
+	containerMemoryWorkingSetDesc = prometheus.NewDesc(
+		"container_memory_working_set_bytes",
+		"Current working set of the container in bytes.",
+		[]string{"container", "pod", "namespace"}, nil,
+	)
+	containerMemoryUsageDesc = prometheus.NewDesc(
+		"container_memory_usage_bytes",
+		"Current memory usage of the container in bytes, including page cache.",
+		[]string{"container", "pod", "namespace"}, nil,
+	)
+)
+
+type metricsCollector struct {
+	provider *Provider
+}
+
+// 返回一个从provider读取最近一次采样结果的Collector
+func NewMetricsCollector(provider *Provider) prometheus.Collector {
+	return &metricsCollector{provider: provider}
+}
+
+func (c *metricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- containerCPUUsageDesc
+	ch <- containerCPUUsageRateDesc
+	ch <- containerMemoryWorkingSetDesc
+	ch <- containerMemoryUsageDesc
+}
+
+func (c *metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.provider.lock.RLock()
+	summary := c.provider.summary
+	c.provider.lock.RUnlock()
+	if summary == nil {
+		return
+	}
+	for _, pod := range summary.Pods {
+		for _, container := range pod.Containers {
+			labels := []string{container.Name, pod.PodRef.Name, pod.PodRef.Namespace}
+			if container.CPU != nil {
+				ch <- prometheus.NewMetricWithTimestamp(container.Time, prometheus.MustNewConstMetric(
+					containerCPUUsageDesc, prometheus.CounterValue, float64(container.CPU.UsageCoreNanoSeconds)/1e9, labels...))
+				ch <- prometheus.NewMetricWithTimestamp(container.Time, prometheus.MustNewConstMetric(
+					containerCPUUsageRateDesc, prometheus.GaugeValue, float64(container.CPU.UsageNanoCores), labels...))
+			}
+			if container.Memory != nil {
+				ch <- prometheus.NewMetricWithTimestamp(container.Time, prometheus.MustNewConstMetric(
+					containerMemoryWorkingSetDesc, prometheus.GaugeValue, float64(container.Memory.WorkingSetBytes), labels...))
+				ch <- prometheus.NewMetricWithTimestamp(container.Time, prometheus.MustNewConstMetric(
+					containerMemoryUsageDesc, prometheus.GaugeValue, float64(container.Memory.UsageBytes), labels...))
+			}
+		}
+	}
+}
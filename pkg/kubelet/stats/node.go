@@ -0,0 +1,114 @@
+package stats
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// -----------------------------------------------------
+// 这个文件从/proc中读取节点的cpu、内存和网络
+// -----------------------------------------------------
+
+// /proc/stat中的时间单位是USER_HZ，linux上基本都是100
+const userHZ = 100
+
+// 读取节点累计使用的cpu时间（纳秒），不包括idle和iowait
+func readNodeCPUUsage(procRoot string) (uint64, error) {
+	f, err := os.Open(filepath.Join(procRoot, "stat"))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+		var busy uint64
+		for i, field := range fields[1:] {
+			v, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid /proc/stat cpu line: %w", err)
+			}
+			// 第4列是idle，第5列是iowait
+			if i == 3 || i == 4 {
+				continue
+			}
+			// guest和guest_nice已经算在user和nice里了
+			if i >= 8 {
+				break
+			}
+			busy += v
+		}
+		return busy * uint64(time.Second/userHZ), nil
+	}
+	return 0, fmt.Errorf("cpu line not found in /proc/stat")
+}
+
+// 读取节点的内存总量和可用内存（字节）
+func readNodeMemory(procRoot string) (total uint64, available uint64, err error) {
+	f, err := os.Open(filepath.Join(procRoot, "meminfo"))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		// 单位是kB
+		switch fields[0] {
+		case "MemTotal:":
+			total = v * 1024
+		case "MemAvailable:":
+			available = v * 1024
+		}
+	}
+	if total == 0 {
+		return 0, 0, fmt.Errorf("MemTotal not found in /proc/meminfo")
+	}
+	return total, available, scanner.Err()
+}
+
+// 读取节点所有网卡（除了lo）的收发字节数
+func readNodeNetwork(procRoot string) (*NetworkStats, error) {
+	f, err := os.Open(filepath.Join(procRoot, "net", "dev"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stats := &NetworkStats{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 格式：  eth0: rxBytes rxPackets rxErrs rxDrop ... txBytes txPackets txErrs ...
+		name, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || strings.TrimSpace(name) == "lo" {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) < 11 {
+			continue
+		}
+		values := make([]uint64, 11)
+		for i := range values {
+			values[i], _ = strconv.ParseUint(fields[i], 10, 64)
+		}
+		stats.RxBytes += values[0]
+		stats.RxErrors += values[2]
+		stats.TxBytes += values[8]
+		stats.TxErrors += values[10]
+	}
+	return stats, scanner.Err()
+}
@@ -0,0 +1,304 @@
+package stats
+
+import (
+	"context"
+	"minik8s/logger"
+	"minik8s/minik8sTypes"
+	"minik8s/pkg/kubelet/metrics"
+	containermanager "minik8s/pkg/kubelet/runtime/containerManager"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+/*
+	定期采集节点上所有minik8s容器的资源使用情况，按pod汇总，再加上从/proc读取的节点数据
+	docker的一次性stats接口会带上前一次的cpu采样（precpu），所以每次采样都可以直接算出cpu使用率
+*/
+
+var (
+	K8sLogger = logger.Named("stats")
+)
+
+const containerStateRunning = "running"
+
+type Provider struct {
+	cm       *containermanager.ContainerManager
+	nodeName string
+	procRoot string
+	numCPU   int
+	now      func() time.Time
+
+	lock    sync.RWMutex
+	summary *Summary
+	// 上一次采样时节点累计使用的cpu时间，用来算节点的cpu使用率
+	prevNodeCPU  uint64
+	prevNodeTime time.Time
+}
+
+func NewProvider(cm *containermanager.ContainerManager, nodeName string) *Provider {
+	return &Provider{
+		cm:       cm,
+		nodeName: nodeName,
+		procRoot: "/proc",
+		numCPU:   runtime.NumCPU(),
+		now:      time.Now,
+	}
+}
+
+// 每隔period采样一次，直到ctx结束
+func (p *Provider) Start(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		if _, err := p.Sample(ctx); err != nil && ctx.Err() == nil {
+			K8sLogger.Errorln("collect stats error: ", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// 返回最近一次采样的结果，还没有采样过的时候立即采样一次
+func (p *Provider) GetSummary(ctx context.Context) (*Summary, error) {
+	p.lock.RLock()
+	summary := p.summary
+	p.lock.RUnlock()
+	if summary != nil {
+		return summary, nil
+	}
+	return p.Sample(ctx)
+}
+
+// 最近一次采样中某个pod的数据
+func (p *Provider) GetPodStats(podUID string) (PodStats, bool) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.summary == nil {
+		return PodStats{}, false
+	}
+	for _, pod := range p.summary.Pods {
+		if pod.PodRef.UID == podUID {
+			return pod, true
+		}
+	}
+	return PodStats{}, false
+}
+
+// 采样一次所有容器和节点的数据
+func (p *Provider) Sample(ctx context.Context) (*Summary, error) {
+	now := p.now()
+	memTotal, memAvailable, err := readNodeMemory(p.procRoot)
+	if err != nil {
+		return nil, err
+	}
+	containers, err := p.cm.ListMinik8sContainer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var running []types.Container
+	runningPods, runningContainers := 0, 0
+	for _, c := range containers {
+		if c.State != containerStateRunning {
+			continue
+		}
+		running = append(running, c)
+		if c.Labels[minik8sTypes.Minik8sPodTypeLabel] == minik8sTypes.Minik8sPausePodType {
+			runningPods++
+		} else {
+			runningContainers++
+		}
+	}
+	metrics.RunningPods.Set(float64(runningPods))
+	metrics.RunningContainers.Set(float64(runningContainers))
+	raw := make([]*types.StatsJSON, len(running))
+	var wg sync.WaitGroup
+	for i := range running {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s, err := p.cm.ContainerStats(ctx, running[i].ID)
+			if err != nil {
+				// 容器可能刚刚退出，跳过就好
+				K8sLogger.Warnln("get stats of container ", running[i].ID, " error: ", err)
+				return
+			}
+			raw[i] = s
+		}(i)
+	}
+	wg.Wait()
+
+	pods := map[string]*PodStats{}
+	for i, c := range running {
+		if raw[i] == nil {
+			continue
+		}
+		uid := c.Labels[minik8sTypes.KubernetesPodUIDLabel]
+		pod, ok := pods[uid]
+		if !ok {
+			pod = &PodStats{
+				PodRef: PodReference{
+					Name:      c.Labels[minik8sTypes.KubernetesPodNameLabel],
+					Namespace: c.Labels[minik8sTypes.KubernetesPodNamespaceLabel],
+					UID:       uid,
+				},
+				Time:       now,
+				Containers: []ContainerStats{},
+			}
+			pods[uid] = pod
+		}
+		if c.Labels[minik8sTypes.Minik8sPodTypeLabel] == minik8sTypes.Minik8sPausePodType {
+			pod.Network = networkStats(raw[i].Networks)
+			continue
+		}
+		pod.Containers = append(pod.Containers, p.containerStats(c, raw[i], memTotal))
+	}
+
+	summary := &Summary{Node: p.nodeStats(now, memTotal, memAvailable), Pods: []PodStats{}}
+	for _, pod := range pods {
+		aggregatePod(pod)
+		summary.Pods = append(summary.Pods, *pod)
+	}
+	sort.Slice(summary.Pods, func(i, j int) bool {
+		a, b := summary.Pods[i].PodRef, summary.Pods[j].PodRef
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	p.lock.Lock()
+	p.summary = summary
+	p.lock.Unlock()
+	return summary, nil
+}
+
+func (p *Provider) containerStats(c types.Container, s *types.StatsJSON, memTotal uint64) ContainerStats {
+	cs := ContainerStats{
+		Name: c.Labels[minik8sTypes.LabelsContainerName],
+		ID:   c.ID,
+		Time: s.Read,
+	}
+	// cpu：两次采样之间的cpu时间差除以时间差
+	cpu := &CPUStats{UsageCoreNanoSeconds: s.CPUStats.CPUUsage.TotalUsage}
+	if interval := s.Read.Sub(s.PreRead); interval > 0 && !s.PreRead.IsZero() &&
+		s.CPUStats.CPUUsage.TotalUsage >= s.PreCPUStats.CPUUsage.TotalUsage {
+		delta := s.CPUStats.CPUUsage.TotalUsage - s.PreCPUStats.CPUUsage.TotalUsage
+		cpu.UsageNanoCores = uint64(float64(delta) / interval.Seconds())
+	}
+	cpu.Percent = p.cpuPercent(cpu.UsageNanoCores)
+	cs.CPU = cpu
+
+	// 内存：工作集 = 使用量 - 不活跃的page cache，cgroup v1和v2的字段名不一样
+	mem := &MemoryStats{UsageBytes: s.MemoryStats.Usage, LimitBytes: s.MemoryStats.Limit}
+	inactiveFile := firstStat(s.MemoryStats.Stats, "total_inactive_file", "inactive_file")
+	if mem.UsageBytes > inactiveFile {
+		mem.WorkingSetBytes = mem.UsageBytes - inactiveFile
+	}
+	mem.RSSBytes = firstStat(s.MemoryStats.Stats, "total_rss", "rss", "anon")
+	mem.Percent = memoryPercent(mem.WorkingSetBytes, memTotal)
+	cs.Memory = mem
+
+	blkio := &BlockIOStats{}
+	for _, entry := range s.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			blkio.ReadBytes += entry.Value
+		case "write":
+			blkio.WriteBytes += entry.Value
+		}
+	}
+	cs.BlockIO = blkio
+	return cs
+}
+
+// 把pod中所有容器的数据加起来
+func aggregatePod(pod *PodStats) {
+	sort.Slice(pod.Containers, func(i, j int) bool {
+		return pod.Containers[i].Name < pod.Containers[j].Name
+	})
+	cpu, mem, blkio := &CPUStats{}, &MemoryStats{}, &BlockIOStats{}
+	for _, c := range pod.Containers {
+		cpu.UsageNanoCores += c.CPU.UsageNanoCores
+		cpu.UsageCoreNanoSeconds += c.CPU.UsageCoreNanoSeconds
+		cpu.Percent += c.CPU.Percent
+		mem.UsageBytes += c.Memory.UsageBytes
+		mem.WorkingSetBytes += c.Memory.WorkingSetBytes
+		mem.RSSBytes += c.Memory.RSSBytes
+		mem.Percent += c.Memory.Percent
+		blkio.ReadBytes += c.BlockIO.ReadBytes
+		blkio.WriteBytes += c.BlockIO.WriteBytes
+	}
+	pod.CPU, pod.Memory, pod.BlockIO = cpu, mem, blkio
+}
+
+func (p *Provider) nodeStats(now time.Time, memTotal, memAvailable uint64) NodeStats {
+	node := NodeStats{NodeName: p.nodeName, Time: now}
+	node.Memory = &MemoryStats{
+		UsageBytes:      memTotal - memAvailable,
+		WorkingSetBytes: memTotal - memAvailable,
+		LimitBytes:      memTotal,
+		Percent:         memoryPercent(memTotal-memAvailable, memTotal),
+	}
+	if usage, err := readNodeCPUUsage(p.procRoot); err != nil {
+		K8sLogger.Warnln("read node cpu usage error: ", err)
+	} else {
+		cpu := &CPUStats{UsageCoreNanoSeconds: usage}
+		p.lock.Lock()
+		if interval := now.Sub(p.prevNodeTime); !p.prevNodeTime.IsZero() && interval > 0 && usage >= p.prevNodeCPU {
+			cpu.UsageNanoCores = uint64(float64(usage-p.prevNodeCPU) / interval.Seconds())
+		}
+		p.prevNodeCPU, p.prevNodeTime = usage, now
+		p.lock.Unlock()
+		cpu.Percent = p.cpuPercent(cpu.UsageNanoCores)
+		node.CPU = cpu
+	}
+	if network, err := readNodeNetwork(p.procRoot); err != nil {
+		K8sLogger.Warnln("read node network error: ", err)
+	} else {
+		node.Network = network
+	}
+	return node
+}
+
+func (p *Provider) cpuPercent(nanoCores uint64) float64 {
+	if p.numCPU <= 0 {
+		return 0
+	}
+	return float64(nanoCores) / float64(uint64(p.numCPU)*uint64(time.Second)) * 100
+}
+
+func memoryPercent(workingSet, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(workingSet) / float64(total) * 100
+}
+
+func networkStats(networks map[string]types.NetworkStats) *NetworkStats {
+	stats := &NetworkStats{}
+	for _, n := range networks {
+		stats.RxBytes += n.RxBytes
+		stats.RxErrors += n.RxErrors
+		stats.TxBytes += n.TxBytes
+		stats.TxErrors += n.TxErrors
+	}
+	return stats
+}
+
+// 按顺序返回第一个存在的统计项
+func firstStat(stats map[string]uint64, keys ...string) uint64 {
+	for _, key := range keys {
+		if v, ok := stats[key]; ok {
+			return v
+		}
+	}
+	return 0
+}
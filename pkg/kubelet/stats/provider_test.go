@@ -0,0 +1,249 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"minik8s/minik8sTypes"
+	"minik8s/pkg/kubelet/dockerClient/dockertest"
+	containermanager "minik8s/pkg/kubelet/runtime/containerManager"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	testProcStat = "cpu  100 0 100 700 100 0 0 0 0 0\ncpu0 100 0 100 700 100 0 0 0 0 0\n"
+	testMeminfo  = "MemTotal:       1000000 kB\nMemFree:         200000 kB\nMemAvailable:    400000 kB\n"
+	testNetDev   = "Inter-|   Receive                                                |  Transmit\n" +
+		" face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed\n" +
+		"    lo:     500       5    0    0    0     0          0         0      500       5    0    0    0     0       0          0\n" +
+		"  eth0:    1000      10    1    0    0     0          0         0     2000      20    2    0    0     0       0          0\n"
+)
+
+func writeProc(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]string{"stat": testProcStat, "meminfo": testMeminfo, "net/dev": testNetDev}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// 模拟docker daemon的容器列表和stats接口
+type fakeStatsDaemon struct {
+	containers []types.Container
+	stats      map[string]types.StatsJSON
+}
+
+func (d *fakeStatsDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/containers/json"):
+		json.NewEncoder(w).Encode(d.containers)
+	case strings.HasSuffix(r.URL.Path, "/stats"):
+		id := strings.TrimSuffix(r.URL.Path[strings.LastIndex(r.URL.Path, "/containers/")+len("/containers/"):], "/stats")
+		s, ok := d.stats[id]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(s)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func podLabels(uid, name, podType, container string) map[string]string {
+	return map[string]string{
+		minik8sTypes.KubernetesPodUIDLabel:       uid,
+		minik8sTypes.KubernetesPodNameLabel:      name,
+		minik8sTypes.KubernetesPodNamespaceLabel: "default",
+		minik8sTypes.Minik8sPodTypeLabel:         podType,
+		minik8sTypes.LabelsContainerName:         container,
+	}
+}
+
+func containerStats(usage, preUsage uint64, memUsage uint64) types.StatsJSON {
+	read := time.Date(2024, 1, 1, 0, 0, 2, 0, time.UTC)
+	s := types.StatsJSON{}
+	s.Read = read
+	s.PreRead = read.Add(-time.Second)
+	s.CPUStats.CPUUsage.TotalUsage = usage
+	s.PreCPUStats.CPUUsage.TotalUsage = preUsage
+	s.MemoryStats.Usage = memUsage
+	s.MemoryStats.Stats = map[string]uint64{"inactive_file": 1024, "anon": 2048}
+	s.BlkioStats.IoServiceBytesRecursive = []types.BlkioStatEntry{
+		{Op: "read", Value: 100}, {Op: "write", Value: 200}, {Op: "Read", Value: 1},
+	}
+	return s
+}
+
+func newTestProvider(t *testing.T, daemon *fakeStatsDaemon) *Provider {
+	c := dockertest.NewClient(t, daemon)
+	p := NewProvider(containermanager.NewContainerManager(c), "node1")
+	p.procRoot = writeProc(t)
+	p.numCPU = 2
+	return p
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestProviderSample(t *testing.T) {
+	pause := containerStats(0, 0, 0)
+	pause.Networks = map[string]types.NetworkStats{"eth0": {RxBytes: 10, TxBytes: 20, RxErrors: 1}}
+	daemon := &fakeStatsDaemon{
+		containers: []types.Container{
+			{ID: "pause1", State: "running", Labels: podLabels("uid1", "web", minik8sTypes.Minik8sPausePodType, "pause")},
+			{ID: "c1", State: "running", Labels: podLabels("uid1", "web", minik8sTypes.Minik8sGenericPodType, "nginx")},
+			{ID: "c2", State: "running", Labels: podLabels("uid1", "web", minik8sTypes.Minik8sGenericPodType, "sidecar")},
+			{ID: "c3", State: "exited", Labels: podLabels("uid2", "job", minik8sTypes.Minik8sGenericPodType, "worker")},
+		},
+		stats: map[string]types.StatsJSON{
+			"pause1": pause,
+			// 1秒内用了0.5核
+			"c1": containerStats(1500000000, 1000000000, 1024+100000*1024),
+			// 1秒内用了0.25核
+			"c2": containerStats(750000000, 500000000, 1024+300000*1024),
+		},
+	}
+	p := newTestProvider(t, daemon)
+	summary, err := p.Sample(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Pods) != 1 {
+		t.Fatalf("expected 1 pod, got %+v", summary.Pods)
+	}
+	pod := summary.Pods[0]
+	if pod.PodRef != (PodReference{Name: "web", Namespace: "default", UID: "uid1"}) {
+		t.Fatalf("unexpected pod ref %+v", pod.PodRef)
+	}
+	if len(pod.Containers) != 2 || pod.Containers[0].Name != "nginx" || pod.Containers[1].Name != "sidecar" {
+		t.Fatalf("unexpected containers %+v", pod.Containers)
+	}
+	nginx := pod.Containers[0]
+	if nginx.CPU.UsageNanoCores != 500000000 || !almostEqual(nginx.CPU.Percent, 25) {
+		t.Fatalf("unexpected nginx cpu %+v", nginx.CPU)
+	}
+	if nginx.Memory.WorkingSetBytes != 100000*1024 || nginx.Memory.RSSBytes != 2048 || !almostEqual(nginx.Memory.Percent, 10) {
+		t.Fatalf("unexpected nginx memory %+v", nginx.Memory)
+	}
+	if nginx.BlockIO.ReadBytes != 101 || nginx.BlockIO.WriteBytes != 200 {
+		t.Fatalf("unexpected nginx block io %+v", nginx.BlockIO)
+	}
+	if pod.CPU.UsageNanoCores != 750000000 || !almostEqual(pod.CPU.Percent, 37.5) || !almostEqual(pod.Memory.Percent, 40) {
+		t.Fatalf("unexpected pod usage %+v %+v", pod.CPU, pod.Memory)
+	}
+	if pod.Network == nil || pod.Network.RxBytes != 10 || pod.Network.TxBytes != 20 || pod.Network.RxErrors != 1 {
+		t.Fatalf("unexpected pod network %+v", pod.Network)
+	}
+
+	node := summary.Node
+	if node.NodeName != "node1" || node.Memory.UsageBytes != 600000*1024 || !almostEqual(node.Memory.Percent, 60) {
+		t.Fatalf("unexpected node memory %+v", node.Memory)
+	}
+	// 第一次采样没有上一次的数据，只有累计值
+	if node.CPU.UsageCoreNanoSeconds != 200*uint64(time.Second)/userHZ || node.CPU.UsageNanoCores != 0 {
+		t.Fatalf("unexpected node cpu %+v", node.CPU)
+	}
+	if node.Network.RxBytes != 1000 || node.Network.TxBytes != 2000 {
+		t.Fatalf("unexpected node network %+v", node.Network)
+	}
+
+	usage, ok := p.GetPodStats("uid1")
+	if !ok || !almostEqual(usage.CPU.Percent, 37.5) {
+		t.Fatalf("unexpected pod stats %+v %v", usage, ok)
+	}
+	if _, ok := p.GetPodStats("uid2"); ok {
+		t.Fatal("exited pod should not have stats")
+	}
+}
+
+func TestProviderNodeCPURate(t *testing.T) {
+	p := newTestProvider(t, &fakeStatsDaemon{})
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	p.now = func() time.Time { return now }
+	if _, err := p.Sample(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// 1秒之后多用了100个jiffy，也就是1核
+	stat := "cpu  150 0 150 800 100 0 0 0 0 0\n"
+	if err := os.WriteFile(filepath.Join(p.procRoot, "stat"), []byte(stat), 0644); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Second)
+	summary, err := p.Sample(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Node.CPU.UsageNanoCores != uint64(time.Second) || !almostEqual(summary.Node.CPU.Percent, 50) {
+		t.Fatalf("unexpected node cpu %+v", summary.Node.CPU)
+	}
+	if len(summary.Pods) != 0 {
+		t.Fatalf("expected no pods, got %+v", summary.Pods)
+	}
+}
+
+func TestMetricsCollector(t *testing.T) {
+	daemon := &fakeStatsDaemon{
+		containers: []types.Container{
+			{ID: "pause1", State: "running", Labels: podLabels("uid1", "web", minik8sTypes.Minik8sPausePodType, "pause")},
+			{ID: "c1", State: "running", Labels: podLabels("uid1", "web", minik8sTypes.Minik8sGenericPodType, "nginx")},
+		},
+		stats: map[string]types.StatsJSON{
+			"pause1": containerStats(0, 0, 0),
+			"c1":     containerStats(1500000000, 1000000000, 1024+100000*1024),
+		},
+	}
+	p := newTestProvider(t, daemon)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(NewMetricsCollector(p))
+	server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	defer server.Close()
+	scrape := func() string {
+		resp, err := server.Client().Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+	// 还没有采样过的时候没有容器的指标
+	if body := scrape(); strings.Contains(body, "container_cpu_usage_nano_cores") {
+		t.Fatalf("unexpected metrics before sampling:\n%s", body)
+	}
+	if _, err := p.Sample(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	body := scrape()
+	for _, want := range []string{
+		`container_cpu_usage_nano_cores{container="nginx",namespace="default",pod="web"} 5e+08`,
+		`container_cpu_usage_seconds_total{container="nginx",namespace="default",pod="web"} 1.5`,
+		`container_memory_working_set_bytes{container="nginx",namespace="default",pod="web"} 1.024e+08`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics output does not contain %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package stats
+
+import "time"
+
+/*
+	/stats/summary 返回的数据，字段参考k8s kubelet的summary api
+	百分比都是相对于整个节点的容量（cpu核数、内存总量）
+*/
+
+type Summary struct {
+	Node NodeStats  `json:"node"`
+	Pods []PodStats `json:"pods"`
+}
+
+type NodeStats struct {
+	NodeName string        `json:"nodeName"`
+	Time     time.Time     `json:"time"`
+	CPU      *CPUStats     `json:"cpu,omitempty"`
+	Memory   *MemoryStats  `json:"memory,omitempty"`
+	Network  *NetworkStats `json:"network,omitempty"`
+}
+
+type PodReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	UID       string `json:"uid"`
+}
+
+type PodStats struct {
+	PodRef     PodReference     `json:"podRef"`
+	Time       time.Time        `json:"time"`
+	Containers []ContainerStats `json:"containers"`
+	CPU        *CPUStats        `json:"cpu,omitempty"`
+	Memory     *MemoryStats     `json:"memory,omitempty"`
+	// pod中所有容器共享pause容器的网络命名空间，所以网络只统计到pod级别
+	Network *NetworkStats `json:"network,omitempty"`
+	BlockIO *BlockIOStats `json:"blockIO,omitempty"`
+}
+
+type ContainerStats struct {
+	Name    string        `json:"name"`
+	ID      string        `json:"id"`
+	Time    time.Time     `json:"time"`
+	CPU     *CPUStats     `json:"cpu,omitempty"`
+	Memory  *MemoryStats  `json:"memory,omitempty"`
+	BlockIO *BlockIOStats `json:"blockIO,omitempty"`
+}
+
+type CPUStats struct {
+	// 最近一段时间平均每秒使用的cpu纳秒数，1e9表示用满一个核
+	UsageNanoCores uint64 `json:"usageNanoCores"`
+	// 累计使用的cpu时间
+	UsageCoreNanoSeconds uint64 `json:"usageCoreNanoSeconds"`
+	// 占整个节点cpu的百分比
+	Percent float64 `json:"percent"`
+}
+
+type MemoryStats struct {
+	UsageBytes uint64 `json:"usageBytes"`
+	// 工作集，去掉了可以回收的page cache，OOM是根据这个判断的
+	WorkingSetBytes uint64 `json:"workingSetBytes"`
+	RSSBytes        uint64 `json:"rssBytes"`
+	// 容器的内存限制，没有限制的时候是节点的内存总量
+	LimitBytes uint64 `json:"limitBytes,omitempty"`
+	// 工作集占整个节点内存的百分比
+	Percent float64 `json:"percent"`
+}
+
+type NetworkStats struct {
+	RxBytes  uint64 `json:"rxBytes"`
+	RxErrors uint64 `json:"rxErrors"`
+	TxBytes  uint64 `json:"txBytes"`
+	TxErrors uint64 `json:"txErrors"`
+}
+
+type BlockIOStats struct {
+	ReadBytes  uint64 `json:"readBytes"`
+	WriteBytes uint64 `json:"writeBytes"`
+}
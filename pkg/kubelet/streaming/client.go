@@ -0,0 +1,80 @@
+package streaming
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gorilla/websocket"
+)
+
+// 客户端（命令行）连接kubelet的exec/attach接口，url形如 ws://127.0.0.1:10250/exec/...
+func Dial(ctx context.Context, url string) (*Conn, error) {
+	dialer := websocket.Dialer{ReadBufferSize: bufferSize, WriteBufferSize: bufferSize}
+	ws, resp, err := dialer.DialContext(ctx, url, nil)
+	if err != nil {
+		if resp != nil {
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			return nil, fmt.Errorf("%s: %s", resp.Status, body)
+		}
+		return nil, err
+	}
+	return NewConn(ws), nil
+}
+
+// 客户端这边的流转发：把Stdin和Resize发给kubelet，把输出写到Stdout和Stderr，
+// 直到收到kubelet发来的状态，返回远端命令的退出码
+func (c *Conn) Stream(streams Streams) (int, error) {
+	done := make(chan struct{})
+	defer close(done)
+	if streams.Stdin != nil {
+		go func() {
+			io.Copy(c.Writer(ChannelStdin), streams.Stdin)
+			// 空的stdin帧表示输入结束
+			c.WriteFrame(ChannelStdin, nil)
+		}()
+	}
+	if streams.Resize != nil {
+		go func() {
+			for {
+				select {
+				case size := <-streams.Resize:
+					data, _ := json.Marshal(size)
+					if err := c.WriteFrame(ChannelResize, data); err != nil {
+						return
+					}
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	for {
+		channel, data, err := c.ReadFrame()
+		if err != nil {
+			return -1, fmt.Errorf("connection closed before the command finished: %w", err)
+		}
+		switch channel {
+		case ChannelStdout:
+			if streams.Stdout != nil {
+				streams.Stdout.Write(data)
+			}
+		case ChannelStderr:
+			if streams.Stderr != nil {
+				streams.Stderr.Write(data)
+			}
+		case ChannelStatus:
+			var status Status
+			if err := json.Unmarshal(data, &status); err != nil {
+				return -1, err
+			}
+			if status.Error != "" {
+				return status.ExitCode, errors.New(status.Error)
+			}
+			return status.ExitCode, nil
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package streaming
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"minik8s/logger"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+/*
+	exec/attach的流协议，跑在websocket上
+	每个websocket的二进制消息是一帧，第一个字节是通道号，后面是数据：
+	0 stdin   客户端 -> kubelet，数据为空表示stdin结束
+	1 stdout  kubelet -> 客户端
+	2 stderr  kubelet -> 客户端
+	3 status  kubelet -> 客户端，json格式的Status，发完之后kubelet关闭连接
+	4 resize  客户端 -> kubelet，json格式的Resize
+*/
+
+var (
+	K8sLogger = logger.Named("streaming")
+)
+
+const (
+	ChannelStdin  byte = 0
+	ChannelStdout byte = 1
+	ChannelStderr byte = 2
+	ChannelStatus byte = 3
+	ChannelResize byte = 4
+
+	bufferSize = 32 * 1024
+)
+
+// 终端大小
+type Resize struct {
+	Width  uint `json:"width"`
+	Height uint `json:"height"`
+}
+
+// 命令结束之后的状态，Error不为空表示kubelet这边出错了（比如找不到容器）
+type Status struct {
+	ExitCode int    `json:"exitCode"`
+	Error    string `json:"error,omitempty"`
+}
+
+// 一次exec/attach用到的输入输出，Stdin为nil表示没有输入，Resize为nil表示没有终端
+type Streams struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	Resize <-chan Resize
+}
+
+// 一条websocket连接，写操作加锁，多个通道可以同时写
+type Conn struct {
+	ws        *websocket.Conn
+	writeLock sync.Mutex
+}
+
+func NewConn(ws *websocket.Conn) *Conn {
+	return &Conn{ws: ws}
+}
+
+func (c *Conn) WriteFrame(channel byte, data []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	frame := make([]byte, 0, len(data)+1)
+	frame = append(frame, channel)
+	frame = append(frame, data...)
+	return c.ws.WriteMessage(websocket.BinaryMessage, frame)
+}
+
+func (c *Conn) ReadFrame() (byte, []byte, error) {
+	for {
+		messageType, data, err := c.ws.ReadMessage()
+		if err != nil {
+			return 0, nil, err
+		}
+		if messageType != websocket.BinaryMessage || len(data) == 0 {
+			continue
+		}
+		return data[0], data[1:], nil
+	}
+}
+
+// 往某个通道写的io.Writer
+func (c *Conn) Writer(channel byte) io.Writer {
+	return &channelWriter{conn: c, channel: channel}
+}
+
+func (c *Conn) Close() error {
+	c.writeLock.Lock()
+	c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	c.writeLock.Unlock()
+	return c.ws.Close()
+}
+
+type channelWriter struct {
+	conn    *Conn
+	channel byte
+}
+
+func (w *channelWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := w.conn.WriteFrame(w.channel, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  bufferSize,
+	WriteBufferSize: bufferSize,
+	CheckOrigin:     NoBrowserOrigin,
+}
+
+// 命令行客户端不会带Origin头，浏览器发起的websocket一定会带
+// 这些接口没有认证，拒绝所有带Origin的请求，防止用户打开的网页跨站连上来在容器里执行命令
+func NoBrowserOrigin(r *http.Request) bool {
+	return r.Header.Get("Origin") == ""
+}
+
+// 处理一次exec/attach请求：升级成websocket，把连接转换成Streams交给handler，
+// handler返回之后把退出码发给客户端。客户端断开的时候ctx会被取消
+func Serve(w http.ResponseWriter, r *http.Request, stdin bool, handler func(ctx context.Context, streams Streams) (int, error)) {
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade已经返回了错误的响应
+		K8sLogger.Errorln("upgrade to websocket error: ", err)
+		return
+	}
+	conn := NewConn(ws)
+	defer conn.Close()
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	stdinReader, stdinWriter := io.Pipe()
+	defer stdinReader.Close()
+	resize := make(chan Resize, 1)
+	go conn.readClientFrames(cancel, stdin, stdinWriter, resize)
+
+	streams := Streams{
+		Stdout: conn.Writer(ChannelStdout),
+		Stderr: conn.Writer(ChannelStderr),
+		Resize: resize,
+	}
+	if stdin {
+		streams.Stdin = stdinReader
+	}
+	exitCode, err := handler(ctx, streams)
+	status := Status{ExitCode: exitCode}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	data, _ := json.Marshal(status)
+	if err := conn.WriteFrame(ChannelStatus, data); err != nil && ctx.Err() == nil {
+		K8sLogger.Errorln("write stream status error: ", err)
+	}
+}
+
+// 读取客户端发来的stdin和resize，连接断开的时候取消ctx
+func (c *Conn) readClientFrames(cancel context.CancelFunc, stdin bool, stdinWriter *io.PipeWriter, resize chan Resize) {
+	defer cancel()
+	defer stdinWriter.Close()
+	for {
+		channel, data, err := c.ReadFrame()
+		if err != nil {
+			return
+		}
+		switch channel {
+		case ChannelStdin:
+			if !stdin {
+				continue
+			}
+			if len(data) == 0 {
+				stdinWriter.Close()
+				continue
+			}
+			if _, err := stdinWriter.Write(data); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+				return
+			}
+		case ChannelResize:
+			var size Resize
+			if err := json.Unmarshal(data, &size); err != nil {
+				K8sLogger.Warnln("invalid resize frame: ", err)
+				continue
+			}
+			// 只保留最新的大小
+			select {
+			case <-resize:
+			default:
+			}
+			resize <- size
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package streaming
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"minik8s/minik8sTypes"
+	containermanager "minik8s/pkg/kubelet/runtime/containerManager"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+/*
+	在pod的容器中执行命令（exec）或者连接到容器的主进程（attach）
+	使用docker的exec和attach接口，把Streams和docker返回的连接对接起来
+*/
+
+var (
+	ErrContainerNotFound = errors.New("running container not found")
+	ErrStdinNotEnabled   = errors.New("container does not have stdin enabled")
+)
+
+type ExecOptions struct {
+	Command []string
+	Stdin   bool
+	TTY     bool
+}
+
+type Streamer struct {
+	cm *containermanager.ContainerManager
+}
+
+func NewStreamer(cm *containermanager.ContainerManager) *Streamer {
+	return &Streamer{cm: cm}
+}
+
+// 在容器中执行命令直到命令结束或者ctx被取消，返回命令的退出码
+func (s *Streamer) Exec(ctx context.Context, podUID, containerName string, opts ExecOptions, streams Streams) (int, error) {
+	if len(opts.Command) == 0 {
+		return -1, fmt.Errorf("command is required")
+	}
+	containerID, err := s.findRunningContainer(ctx, podUID, containerName)
+	if err != nil {
+		return -1, err
+	}
+	execID, err := s.cm.ExecCreate(ctx, containerID, types.ExecConfig{
+		Tty:          opts.TTY,
+		AttachStdin:  opts.Stdin,
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          opts.Command,
+	})
+	if err != nil {
+		return -1, err
+	}
+	hr, err := s.cm.ExecAttach(ctx, execID, opts.TTY)
+	if err != nil {
+		return -1, err
+	}
+	resize := func(size Resize) error {
+		return s.cm.ExecResize(ctx, execID, size.Height, size.Width)
+	}
+	if err := copyStreams(ctx, hr, opts.TTY, opts.Stdin, streams, resize); err != nil && ctx.Err() == nil {
+		return -1, err
+	}
+	inspect, err := s.cm.ExecInspect(context.Background(), execID)
+	if err != nil {
+		return -1, err
+	}
+	return inspect.ExitCode, nil
+}
+
+// 连接到容器主进程的输入输出，直到容器退出或者ctx被取消
+// 容器退出的时候返回容器的退出码，客户端主动断开的时候返回0
+func (s *Streamer) Attach(ctx context.Context, podUID, containerName string, stdin bool, streams Streams) (int, error) {
+	containerID, err := s.findRunningContainer(ctx, podUID, containerName)
+	if err != nil {
+		return -1, err
+	}
+	inspect, err := s.cm.InspectContainer(ctx, containerID)
+	if err != nil {
+		return -1, err
+	}
+	if stdin && !inspect.Config.OpenStdin {
+		return -1, ErrStdinNotEnabled
+	}
+	hr, err := s.cm.AttachContainer(ctx, containerID, stdin)
+	if err != nil {
+		return -1, err
+	}
+	resize := func(size Resize) error {
+		return s.cm.ResizeContainer(ctx, containerID, size.Height, size.Width)
+	}
+	if err := copyStreams(ctx, hr, inspect.Config.Tty, stdin, streams, resize); err != nil && ctx.Err() == nil {
+		return -1, err
+	}
+	inspect, err = s.cm.InspectContainer(context.Background(), containerID)
+	if err != nil {
+		return -1, err
+	}
+	if inspect.State.Running {
+		return 0, nil
+	}
+	return inspect.State.ExitCode, nil
+}
+
+// 把docker的连接和Streams对接起来，直到docker那边的输出结束
+func copyStreams(ctx context.Context, hr types.HijackedResponse, tty bool, stdin bool, streams Streams, resize func(Resize) error) error {
+	defer hr.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		// 客户端断开的时候关闭docker的连接，让下面的读取返回
+		select {
+		case <-ctx.Done():
+			hr.Close()
+		case <-done:
+		}
+	}()
+	if stdin && streams.Stdin != nil {
+		go func() {
+			io.Copy(hr.Conn, streams.Stdin)
+			hr.CloseWrite()
+		}()
+	}
+	if tty && streams.Resize != nil {
+		go func() {
+			for {
+				select {
+				case size := <-streams.Resize:
+					if err := resize(size); err != nil {
+						K8sLogger.Warnln("resize terminal error: ", err)
+					}
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	var err error
+	if tty {
+		// tty模式下stdout和stderr合在一起
+		_, err = io.Copy(streams.Stdout, hr.Reader)
+	} else {
+		_, err = stdcopy.StdCopy(streams.Stdout, streams.Stderr, hr.Reader)
+	}
+	return err
+}
+
+// 找到pod中正在运行的容器（当前的实例）
+// 按uid查找，同名的旧pod还没被回收的容器不会被找到；同一秒创建的实例按照attempt区分新旧
+func (s *Streamer) findRunningContainer(ctx context.Context, podUID, containerName string) (string, error) {
+	filter := filters.NewArgs()
+	filter.Add("label", minik8sTypes.KubernetesPodUIDLabel+"="+podUID)
+	filter.Add("label", minik8sTypes.Minik8sPodTypeLabel+"="+minik8sTypes.Minik8sGenericPodType)
+	filter.Add("label", minik8sTypes.LabelsContainerName+"="+containerName)
+	filter.Add("status", "running")
+	res, err := s.cm.ListContainerWithOpts(ctx, types.ContainerListOptions{Filters: filter})
+	if err != nil {
+		return "", err
+	}
+	if len(res) == 0 {
+		return "", fmt.Errorf("%w: %s in pod %s", ErrContainerNotFound, containerName, podUID)
+	}
+	containermanager.SortByAttempt(res)
+	return res[0].ID, nil
+}
@@ -0,0 +1,157 @@
+package streaming
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"minik8s/minik8sTypes"
+	"minik8s/pkg/kubelet/dockerClient/dockertest"
+	containermanager "minik8s/pkg/kubelet/runtime/containerManager"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/gorilla/websocket"
+)
+
+// 模拟docker daemon的exec接口：exec start之后把stdin原样写回去（tty模式），退出码是3
+type fakeExecDaemon struct {
+	containers []types.Container
+	lock       sync.Mutex
+	execConfig types.ExecConfig
+	// 在哪个容器中exec，以及查找容器时的过滤条件
+	execContainer string
+	listFilters   string
+	resized       chan types.ResizeOptions
+}
+
+func (d *fakeExecDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/containers/json"):
+		d.lock.Lock()
+		d.listFilters = r.URL.Query().Get("filters")
+		d.lock.Unlock()
+		json.NewEncoder(w).Encode(d.containers)
+	case strings.HasSuffix(r.URL.Path, "/exec") && strings.Contains(r.URL.Path, "/containers/"):
+		d.lock.Lock()
+		d.execContainer = strings.TrimSuffix(r.URL.Path[strings.Index(r.URL.Path, "/containers/")+len("/containers/"):], "/exec")
+		json.NewDecoder(r.Body).Decode(&d.execConfig)
+		d.lock.Unlock()
+		json.NewEncoder(w).Encode(types.IDResponse{ID: "exec1"})
+	case strings.HasSuffix(r.URL.Path, "/exec/exec1/resize"):
+		height, width := r.URL.Query().Get("h"), r.URL.Query().Get("w")
+		if height == "24" && width == "80" {
+			d.resized <- types.ResizeOptions{Height: 24, Width: 80}
+		}
+	case strings.HasSuffix(r.URL.Path, "/exec/exec1/start"):
+		io.ReadAll(r.Body)
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+		buf.Flush()
+		select {
+		case <-d.resized:
+		case <-time.After(2 * time.Second):
+		}
+		io.Copy(conn, buf)
+	case strings.HasSuffix(r.URL.Path, "/exec/exec1/json"):
+		json.NewEncoder(w).Encode(types.ContainerExecInspect{ExecID: "exec1", ExitCode: 3})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestStreamServer(t *testing.T, daemon http.Handler, opts ExecOptions) string {
+	c := dockertest.NewClient(t, daemon)
+	streamer := NewStreamer(containermanager.NewContainerManager(c))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Serve(w, r, opts.Stdin, func(ctx context.Context, streams Streams) (int, error) {
+			return streamer.Exec(ctx, "pod1", "web", opts, streams)
+		})
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestExec(t *testing.T) {
+	// 两个实例在同一秒创建，只能按照attempt区分新旧
+	daemon := &fakeExecDaemon{
+		containers: []types.Container{
+			{ID: "c0", Created: 1, Labels: map[string]string{minik8sTypes.KubernetesContainerAttemptLabel: "0"}},
+			{ID: "c1", Created: 1, Labels: map[string]string{minik8sTypes.KubernetesContainerAttemptLabel: "1"}},
+		},
+		resized: make(chan types.ResizeOptions, 1),
+	}
+	url := newTestStreamServer(t, daemon, ExecOptions{Command: []string{"cat"}, Stdin: true, TTY: true})
+	conn, err := Dial(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	resize := make(chan Resize, 1)
+	resize <- Resize{Width: 80, Height: 24}
+	var stdout bytes.Buffer
+	exitCode, err := conn.Stream(Streams{
+		Stdin:  strings.NewReader("hello"),
+		Stdout: &stdout,
+		Stderr: io.Discard,
+		Resize: resize,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != 3 {
+		t.Fatalf("expected exit code 3, got %d", exitCode)
+	}
+	if stdout.String() != "hello" {
+		t.Fatalf("expected stdin to be echoed, got %q", stdout.String())
+	}
+	daemon.lock.Lock()
+	defer daemon.lock.Unlock()
+	if !daemon.execConfig.Tty || !daemon.execConfig.AttachStdin || strings.Join(daemon.execConfig.Cmd, " ") != "cat" {
+		t.Fatalf("unexpected exec config %+v", daemon.execConfig)
+	}
+	if daemon.execContainer != "c1" {
+		t.Fatalf("expected exec in the newest instance c1, got %s", daemon.execContainer)
+	}
+	if !strings.Contains(daemon.listFilters, minik8sTypes.KubernetesPodUIDLabel+"=pod1") {
+		t.Fatalf("expected containers to be filtered by pod uid, got %s", daemon.listFilters)
+	}
+}
+
+func TestExecContainerNotFound(t *testing.T) {
+	daemon := &fakeExecDaemon{resized: make(chan types.ResizeOptions, 1)}
+	url := newTestStreamServer(t, daemon, ExecOptions{Command: []string{"ls"}})
+	conn, err := Dial(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Stream(Streams{Stdout: io.Discard, Stderr: io.Discard})
+	if err == nil || !strings.Contains(err.Error(), ErrContainerNotFound.Error()) {
+		t.Fatalf("expected container not found error, got %v", err)
+	}
+}
+
+// 浏览器发起的跨站websocket请求一定带着Origin，直接拒绝
+func TestExecRejectsBrowserOrigin(t *testing.T) {
+	daemon := &fakeExecDaemon{resized: make(chan types.ResizeOptions, 1)}
+	url := newTestStreamServer(t, daemon, ExecOptions{Command: []string{"ls"}})
+	_, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"http://evil.example.com"}})
+	if err == nil {
+		t.Fatal("expected the upgrade to be rejected")
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %v", resp)
+	}
+}
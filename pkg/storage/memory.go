@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var errClosed = errors.New("storage is closed")
+
+type memoryStorage struct {
+	lock    sync.Mutex
+	version uint64
+	items   map[string]KeyValue
+	// 最近的事件，版本号是连续的
+	history  []Event
+	watchers map[*memoryWatcher]struct{}
+	closed   bool
+}
+
+type memoryWatcher struct {
+	prefix string
+	// 只发送版本号比这个大的事件
+	after uint64
+	ch    chan Event
+}
+
+func NewMemoryStorage() Storage {
+	return &memoryStorage{
+		items:    map[string]KeyValue{},
+		watchers: map[*memoryWatcher]struct{}{},
+	}
+}
+
+// 存进去和取出来的value都是拷贝
+func copyKeyValue(kv KeyValue) KeyValue {
+	kv.Value = append([]byte(nil), kv.Value...)
+	return kv
+}
+
+func (s *memoryStorage) Get(ctx context.Context, key string) (*KeyValue, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	kv, ok := s.items[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	kv = copyKeyValue(kv)
+	return &kv, nil
+}
+
+func (s *memoryStorage) List(ctx context.Context, prefix string) ([]KeyValue, uint64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	items := []KeyValue{}
+	for key, kv := range s.items {
+		if strings.HasPrefix(key, prefix) {
+			items = append(items, copyKeyValue(kv))
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+	return items, s.version, nil
+}
+
+func (s *memoryStorage) Create(ctx context.Context, key string, value []byte) (uint64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return 0, errClosed
+	}
+	if _, ok := s.items[key]; ok {
+		return 0, ErrKeyExists
+	}
+	return s.put(Added, key, value), nil
+}
+
+func (s *memoryStorage) Update(ctx context.Context, key string, value []byte, resourceVersion uint64) (uint64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return 0, errClosed
+	}
+	old, ok := s.items[key]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	if resourceVersion != 0 && resourceVersion != old.ResourceVersion {
+		return 0, ErrConflict
+	}
+	return s.put(Modified, key, value), nil
+}
+
+func (s *memoryStorage) Delete(ctx context.Context, key string, resourceVersion uint64) (*KeyValue, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return nil, errClosed
+	}
+	old, ok := s.items[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if resourceVersion != 0 && resourceVersion != old.ResourceVersion {
+		return nil, ErrConflict
+	}
+	delete(s.items, key)
+	s.version++
+	deleted := old
+	deleted.ResourceVersion = s.version
+	s.notify(Event{Type: Deleted, KeyValue: deleted})
+	return &old, nil
+}
+
+// 调用的时候要持有锁
+func (s *memoryStorage) put(eventType EventType, key string, value []byte) uint64 {
+	s.version++
+	kv := KeyValue{Key: key, Value: append([]byte(nil), value...), ResourceVersion: s.version}
+	s.items[key] = kv
+	s.notify(Event{Type: eventType, KeyValue: kv})
+	return s.version
+}
+
+// 调用的时候要持有锁，channel满了的watcher直接关闭
+func (s *memoryStorage) notify(event Event) {
+	if len(s.history) == watchHistorySize {
+		s.history = append(s.history[:0], s.history[1:]...)
+	}
+	s.history = append(s.history, event)
+	for w := range s.watchers {
+		if event.ResourceVersion <= w.after || !strings.HasPrefix(event.Key, w.prefix) {
+			continue
+		}
+		select {
+		case w.ch <- Event{Type: event.Type, KeyValue: copyKeyValue(event.KeyValue)}:
+		default:
+			K8sLogger.Warnln("watcher of ", w.prefix, " is too slow, closing it")
+			s.stopWatcher(w)
+		}
+	}
+}
+
+// 调用的时候要持有锁
+func (s *memoryStorage) stopWatcher(w *memoryWatcher) {
+	if _, ok := s.watchers[w]; ok {
+		delete(s.watchers, w)
+		close(w.ch)
+	}
+}
+
+func (s *memoryStorage) Watch(ctx context.Context, prefix string, resourceVersion uint64) (<-chan Event, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return nil, errClosed
+	}
+	var replay []Event
+	if resourceVersion != 0 && resourceVersion < s.version {
+		if len(s.history) == 0 || s.history[0].ResourceVersion > resourceVersion+1 {
+			return nil, ErrResourceVersionTooOld
+		}
+		for _, event := range s.history {
+			if event.ResourceVersion > resourceVersion && strings.HasPrefix(event.Key, prefix) {
+				replay = append(replay, Event{Type: event.Type, KeyValue: copyKeyValue(event.KeyValue)})
+			}
+		}
+	}
+	w := &memoryWatcher{prefix: prefix, after: resourceVersion, ch: make(chan Event, watchChanSize+len(replay))}
+	for _, event := range replay {
+		w.ch <- event
+	}
+	s.watchers[w] = struct{}{}
+	go func() {
+		<-ctx.Done()
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.stopWatcher(w)
+	}()
+	return w.ch, nil
+}
+
+func (s *memoryStorage) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	for w := range s.watchers {
+		s.stopWatcher(w)
+	}
+	return nil
+}
@@ -0,0 +1,395 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+/*
+	基于redis的存储，所有的key都在KeyPrefix下面:
+		{prefix}:version       全局的版本号，每次写入INCR
+		{prefix}:keys          所有key的有序集合（score都是0），用来按前缀列出
+		{prefix}:data:{key}    hash，value字段是对象，rv字段是版本号
+		{prefix}:events        pub/sub的channel，每次写入都发布一个事件
+		{prefix}:history       最近的watchHistorySize个事件，用来从指定的版本号开始watch
+	写操作都用lua脚本完成，检查版本号、写入和发布事件是原子的
+	版本号在脚本中格式化成字符串，避免lua的数字在很大的时候变成科学计数法
+*/
+
+type RedisOptions struct {
+	Address  string
+	Password string
+	DB       int
+	// 多个集群共用一个redis的时候用不同的前缀隔开
+	KeyPrefix string
+}
+
+const defaultRedisKeyPrefix = "minik8s"
+
+// lua脚本的返回值，表示key不存在或者版本号冲突
+const (
+	scriptNotFound = -1
+	scriptConflict = -2
+	scriptExists   = -3
+)
+
+var createScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then return -3 end
+local rv = string.format('%d', redis.call('INCR', KEYS[2]))
+redis.call('HSET', KEYS[1], 'value', ARGV[2], 'rv', rv)
+redis.call('ZADD', KEYS[3], 0, ARGV[1])
+local event = cjson.encode({type = 'ADDED', key = ARGV[1], value = ARGV[2], rv = rv})
+redis.call('RPUSH', KEYS[4], event)
+redis.call('LTRIM', KEYS[4], -tonumber(ARGV[4]), -1)
+redis.call('PUBLISH', ARGV[3], event)
+return rv
+`)
+
+var updateScript = redis.NewScript(`
+local old = redis.call('HGET', KEYS[1], 'rv')
+if not old then return -1 end
+if ARGV[4] ~= '0' and ARGV[4] ~= old then return -2 end
+local rv = string.format('%d', redis.call('INCR', KEYS[2]))
+redis.call('HSET', KEYS[1], 'value', ARGV[2], 'rv', rv)
+local event = cjson.encode({type = 'MODIFIED', key = ARGV[1], value = ARGV[2], rv = rv})
+redis.call('RPUSH', KEYS[3], event)
+redis.call('LTRIM', KEYS[3], -tonumber(ARGV[5]), -1)
+redis.call('PUBLISH', ARGV[3], event)
+return rv
+`)
+
+// 返回 {删除前的value, 删除前的版本号}
+var deleteScript = redis.NewScript(`
+local old = redis.call('HMGET', KEYS[1], 'value', 'rv')
+if not old[2] then return -1 end
+if ARGV[3] ~= '0' and ARGV[3] ~= old[2] then return -2 end
+local rv = string.format('%d', redis.call('INCR', KEYS[2]))
+redis.call('DEL', KEYS[1])
+redis.call('ZREM', KEYS[3], ARGV[1])
+local event = cjson.encode({type = 'DELETED', key = ARGV[1], value = old[1], rv = rv})
+redis.call('RPUSH', KEYS[4], event)
+redis.call('LTRIM', KEYS[4], -tonumber(ARGV[4]), -1)
+redis.call('PUBLISH', ARGV[2], event)
+return old
+`)
+
+// 返回 {当前的版本号, key1, value1, rv1, key2, value2, rv2, ...}
+// 脚本里用 ARGV[2] .. key 拼出来的key没有在KEYS中声明，redis cluster会拒绝或者读到别的节点上，
+// 所以只能用在单节点的redis上
+var listScript = redis.NewScript(`
+local keys
+if ARGV[1] == '' then
+	keys = redis.call('ZRANGEBYLEX', KEYS[1], '-', '+')
+else
+	keys = redis.call('ZRANGEBYLEX', KEYS[1], '[' .. ARGV[1], '(' .. ARGV[1] .. '\255')
+end
+local result = {redis.call('GET', KEYS[2]) or '0'}
+for _, key in ipairs(keys) do
+	local kv = redis.call('HMGET', ARGV[2] .. key, 'value', 'rv')
+	if kv[2] then
+		table.insert(result, key)
+		table.insert(result, kv[1])
+		table.insert(result, kv[2])
+	end
+end
+return result
+`)
+
+// 返回 {当前的版本号, 最近的事件...}，两者在同一个脚本里读，是一致的
+var historyScript = redis.NewScript(`
+local result = {redis.call('GET', KEYS[1]) or '0'}
+for _, event in ipairs(redis.call('LRANGE', KEYS[2], 0, -1)) do
+	table.insert(result, event)
+end
+return result
+`)
+
+// 发布到pub/sub中的事件，也保存在历史中
+type redisEvent struct {
+	Type  EventType `json:"type"`
+	Key   string    `json:"key"`
+	Value string    `json:"value"`
+	RV    uint64    `json:"rv,string"`
+}
+
+type redisStorage struct {
+	client     *redis.Client
+	versionKey string
+	indexKey   string
+	dataPrefix string
+	historyKey string
+	channel    string
+
+	lock     sync.Mutex
+	watchers map[*redis.PubSub]struct{}
+}
+
+// 连接redis，连不上的时候返回错误
+func NewRedisStorage(ctx context.Context, opts RedisOptions) (Storage, error) {
+	if opts.KeyPrefix == "" {
+		opts.KeyPrefix = defaultRedisKeyPrefix
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     opts.Address,
+		Password: opts.Password,
+		DB:       opts.DB,
+	})
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connect to redis %s: %w", opts.Address, err)
+	}
+	return &redisStorage{
+		client:     client,
+		versionKey: opts.KeyPrefix + ":version",
+		indexKey:   opts.KeyPrefix + ":keys",
+		dataPrefix: opts.KeyPrefix + ":data:",
+		historyKey: opts.KeyPrefix + ":history",
+		channel:    opts.KeyPrefix + ":events",
+		watchers:   map[*redis.PubSub]struct{}{},
+	}, nil
+}
+
+func (s *redisStorage) dataKey(key string) string {
+	return s.dataPrefix + key
+}
+
+// 把lua脚本返回的错误码转换成对应的错误
+func scriptResult(result interface{}) (interface{}, error) {
+	if code, ok := result.(int64); ok {
+		switch code {
+		case scriptNotFound:
+			return nil, ErrNotFound
+		case scriptConflict:
+			return nil, ErrConflict
+		case scriptExists:
+			return nil, ErrKeyExists
+		}
+	}
+	return result, nil
+}
+
+func parseVersion(value interface{}) (uint64, error) {
+	switch v := value.(type) {
+	case int64:
+		return uint64(v), nil
+	case string:
+		return strconv.ParseUint(v, 10, 64)
+	}
+	return 0, fmt.Errorf("unexpected resource version %v", value)
+}
+
+func (s *redisStorage) Get(ctx context.Context, key string) (*KeyValue, error) {
+	values, err := s.client.HMGet(ctx, s.dataKey(key), "value", "rv").Result()
+	if err != nil {
+		return nil, err
+	}
+	if values[1] == nil {
+		return nil, ErrNotFound
+	}
+	rv, err := parseVersion(values[1])
+	if err != nil {
+		return nil, err
+	}
+	value, _ := values[0].(string)
+	return &KeyValue{Key: key, Value: []byte(value), ResourceVersion: rv}, nil
+}
+
+func (s *redisStorage) List(ctx context.Context, prefix string) ([]KeyValue, uint64, error) {
+	result, err := listScript.Run(ctx, s.client, []string{s.indexKey, s.versionKey}, prefix, s.dataPrefix).Slice()
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(result) == 0 {
+		return nil, 0, fmt.Errorf("unexpected list result %v", result)
+	}
+	version, err := parseVersion(result[0])
+	if err != nil {
+		return nil, 0, err
+	}
+	items := make([]KeyValue, 0, len(result)/3)
+	for i := 1; i+2 < len(result); i += 3 {
+		key, _ := result[i].(string)
+		value, _ := result[i+1].(string)
+		rv, err := parseVersion(result[i+2])
+		if err != nil {
+			return nil, 0, err
+		}
+		items = append(items, KeyValue{Key: key, Value: []byte(value), ResourceVersion: rv})
+	}
+	return items, version, nil
+}
+
+func (s *redisStorage) Create(ctx context.Context, key string, value []byte) (uint64, error) {
+	result, err := createScript.Run(ctx, s.client, []string{s.dataKey(key), s.versionKey, s.indexKey, s.historyKey}, key, value, s.channel, watchHistorySize).Result()
+	if err != nil {
+		return 0, err
+	}
+	if result, err = scriptResult(result); err != nil {
+		return 0, err
+	}
+	return parseVersion(result)
+}
+
+func (s *redisStorage) Update(ctx context.Context, key string, value []byte, resourceVersion uint64) (uint64, error) {
+	result, err := updateScript.Run(ctx, s.client, []string{s.dataKey(key), s.versionKey, s.historyKey}, key, value, s.channel, resourceVersion, watchHistorySize).Result()
+	if err != nil {
+		return 0, err
+	}
+	if result, err = scriptResult(result); err != nil {
+		return 0, err
+	}
+	return parseVersion(result)
+}
+
+func (s *redisStorage) Delete(ctx context.Context, key string, resourceVersion uint64) (*KeyValue, error) {
+	result, err := deleteScript.Run(ctx, s.client, []string{s.dataKey(key), s.versionKey, s.indexKey, s.historyKey}, key, s.channel, resourceVersion, watchHistorySize).Result()
+	if err != nil {
+		return nil, err
+	}
+	if result, err = scriptResult(result); err != nil {
+		return nil, err
+	}
+	old, ok := result.([]interface{})
+	if !ok || len(old) != 2 {
+		return nil, fmt.Errorf("unexpected delete result %v", result)
+	}
+	rv, err := parseVersion(old[1])
+	if err != nil {
+		return nil, err
+	}
+	value, _ := old[0].(string)
+	return &KeyValue{Key: key, Value: []byte(value), ResourceVersion: rv}, nil
+}
+
+// 订阅成功之后再读历史，订阅之前的事件从历史中补上，之后的事件从pub/sub中收到，中间重复的按版本号去掉
+// go-redis的PubSub断线之后会悄悄重连，重连期间的事件就丢了，所以这里不用pubsub.Channel()，
+// 连接出错就关闭channel，让调用方从收到的最后一个版本号重新Watch
+// 每次写入都会INCR全局版本号并发布事件，所以收到的版本号应该是连续的，不连续说明丢了事件，也关闭channel
+func (s *redisStorage) Watch(ctx context.Context, prefix string, resourceVersion uint64) (<-chan Event, error) {
+	pubsub := s.client.Subscribe(ctx, s.channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("subscribe %s: %w", s.channel, err)
+	}
+	lastRV, replay, err := s.replay(ctx, prefix, resourceVersion)
+	if err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+	s.lock.Lock()
+	s.watchers[pubsub] = struct{}{}
+	s.lock.Unlock()
+
+	out := make(chan Event, watchChanSize+len(replay))
+	for _, event := range replay {
+		out <- event
+	}
+	go func() {
+		defer close(out)
+		defer s.stopWatcher(pubsub)
+		// Receive不会因为ctx结束而返回，关闭pubsub让它返回错误
+		stop := context.AfterFunc(ctx, func() { pubsub.Close() })
+		defer stop()
+		for {
+			msg, err := pubsub.Receive(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					K8sLogger.Warnln("storage subscription of ", prefix, " dropped, closing the watcher: ", err)
+				}
+				return
+			}
+			switch msg := msg.(type) {
+			case *redis.Message:
+				var e redisEvent
+				if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+					K8sLogger.Errorln("decode storage event error: ", err)
+					return
+				}
+				if e.RV <= lastRV {
+					// 已经从历史中补过了
+					continue
+				}
+				if e.RV != lastRV+1 {
+					K8sLogger.Warnf("watcher of %s missed events between resource version %d and %d, closing it", prefix, lastRV, e.RV)
+					return
+				}
+				lastRV = e.RV
+				if !strings.HasPrefix(e.Key, prefix) {
+					continue
+				}
+				select {
+				case out <- Event{Type: e.Type, KeyValue: KeyValue{Key: e.Key, Value: []byte(e.Value), ResourceVersion: e.RV}}:
+				default:
+					K8sLogger.Warnln("watcher of ", prefix, " is too slow, closing it")
+					return
+				}
+			case *redis.Subscription:
+				// 只有重新订阅的时候才会再收到订阅消息，中间的事件可能已经丢了
+				K8sLogger.Warnln("storage subscription of ", prefix, " was re-established, closing the watcher")
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// 从历史中找出resourceVersion之后以prefix开头的事件，返回之后要从哪个版本号继续
+func (s *redisStorage) replay(ctx context.Context, prefix string, resourceVersion uint64) (uint64, []Event, error) {
+	result, err := historyScript.Run(ctx, s.client, []string{s.versionKey, s.historyKey}).Slice()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(result) == 0 {
+		return 0, nil, fmt.Errorf("unexpected history result %v", result)
+	}
+	version, err := parseVersion(result[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	if resourceVersion == 0 {
+		return version, nil, nil
+	}
+	if resourceVersion >= version {
+		return resourceVersion, nil, nil
+	}
+	if len(result) == 1 {
+		return 0, nil, ErrResourceVersionTooOld
+	}
+	var replay []Event
+	for i, item := range result[1:] {
+		payload, _ := item.(string)
+		var e redisEvent
+		if err := json.Unmarshal([]byte(payload), &e); err != nil {
+			return 0, nil, fmt.Errorf("decode storage event: %w", err)
+		}
+		if i == 0 && e.RV > resourceVersion+1 {
+			return 0, nil, ErrResourceVersionTooOld
+		}
+		if e.RV > resourceVersion && strings.HasPrefix(e.Key, prefix) {
+			replay = append(replay, Event{Type: e.Type, KeyValue: KeyValue{Key: e.Key, Value: []byte(e.Value), ResourceVersion: e.RV}})
+		}
+	}
+	return version, replay, nil
+}
+
+func (s *redisStorage) stopWatcher(pubsub *redis.PubSub) {
+	s.lock.Lock()
+	delete(s.watchers, pubsub)
+	s.lock.Unlock()
+	pubsub.Close()
+}
+
+func (s *redisStorage) Close() error {
+	s.lock.Lock()
+	for pubsub := range s.watchers {
+		pubsub.Close()
+	}
+	s.lock.Unlock()
+	return s.client.Close()
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"minik8s/logger"
+)
+
+/*
+	apiserver保存对象的存储，和etcd类似是一个带版本号的kv存储
+	key是 /registry/pods/{namespace}/{name} 这样的路径，value是编码之后的对象
+	每次写入都会得到一个新的全局递增的版本号，作为对象的resourceVersion
+	有内存和redis两种实现，内存的实现用于测试和单机运行
+*/
+
+var (
+	K8sLogger = logger.Named("storage")
+)
+
+var (
+	ErrNotFound  = errors.New("key not found")
+	ErrKeyExists = errors.New("key already exists")
+	// 更新或者删除的时候指定的版本号和保存的不一致
+	ErrConflict = errors.New("resource version conflict")
+	// Watch指定的版本号之后的事件已经不在保存的历史中了，调用方需要重新List
+	ErrResourceVersionTooOld = errors.New("resource version is too old")
+)
+
+type KeyValue struct {
+	Key   string
+	Value []byte
+	// 最后一次写入这个key时的版本号
+	ResourceVersion uint64
+}
+
+type EventType string
+
+const (
+	Added    EventType = "ADDED"
+	Modified EventType = "MODIFIED"
+	Deleted  EventType = "DELETED"
+)
+
+// 删除事件中的KeyValue是删除之前的值，版本号是删除操作的版本号
+type Event struct {
+	Type EventType
+	KeyValue
+}
+
+type Storage interface {
+	Get(ctx context.Context, key string) (*KeyValue, error)
+	// 返回所有以prefix开头的key，按照key排序，以及List时存储的版本号
+	List(ctx context.Context, prefix string) ([]KeyValue, uint64, error)
+	// key已经存在的时候返回ErrKeyExists
+	Create(ctx context.Context, key string, value []byte) (uint64, error)
+	// resourceVersion不为0的时候必须和保存的版本号一致，否则返回ErrConflict
+	Update(ctx context.Context, key string, value []byte, resourceVersion uint64) (uint64, error)
+	// resourceVersion的含义和Update一样，返回被删除的值
+	Delete(ctx context.Context, key string, resourceVersion uint64) (*KeyValue, error)
+	// 监听以prefix开头的key在resourceVersion之后的变化，为0表示从现在开始，ctx结束之后channel被关闭
+	// 用List返回的版本号Watch，List之后的写入一个都不会漏掉
+	// 版本号之后的事件已经不在历史中的时候返回ErrResourceVersionTooOld
+	// 消费太慢或者和后端的连接断开的时候channel也会被关闭，调用方需要从收到的最后一个版本号重新Watch，
+	// 返回ErrResourceVersionTooOld的话就重新List再Watch
+	Watch(ctx context.Context, prefix string, resourceVersion uint64) (<-chan Event, error)
+	Close() error
+}
+
+// watch的channel的缓冲大小，超过之后认为消费太慢
+const watchChanSize = 100
+
+// 保存最近多少个事件，用来从指定的版本号开始Watch
+const watchHistorySize = 1000
@@ -0,0 +1,363 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// 设置了这个环境变量的时候用真正的redis-server测试，比如 127.0.0.1:6379
+const envTestRedisAddress = "MINIK8S_TEST_REDIS_ADDR"
+
+func newTestRedisStorage(t *testing.T) Storage {
+	address := os.Getenv(envTestRedisAddress)
+	if address == "" {
+		address = miniredis.RunT(t).Addr()
+	}
+	// 每个测试用不同的前缀，不会互相影响，也不会碰到redis中已有的数据
+	prefix := "minik8s-test-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	s, err := NewRedisStorage(context.Background(), RedisOptions{Address: address, KeyPrefix: prefix})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	if os.Getenv(envTestRedisAddress) != "" {
+		t.Cleanup(func() { cleanupRedis(t, s.(*redisStorage), prefix) })
+	}
+	return s
+}
+
+func cleanupRedis(t *testing.T, s *redisStorage, prefix string) {
+	ctx := context.Background()
+	keys, err := s.client.Keys(ctx, prefix+":*").Result()
+	if err == nil && len(keys) > 0 {
+		err = s.client.Del(ctx, keys...).Err()
+	}
+	if err != nil {
+		t.Logf("clean up redis keys %s: %v", prefix, err)
+	}
+}
+
+func TestMemoryStorage(t *testing.T) {
+	testStorage(t, func(t *testing.T) Storage {
+		s := NewMemoryStorage()
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+func TestRedisStorage(t *testing.T) {
+	testStorage(t, newTestRedisStorage)
+}
+
+// 两种实现的行为必须一致
+func testStorage(t *testing.T, newStorage func(t *testing.T) Storage) {
+	t.Run("CRUD", func(t *testing.T) { testCRUD(t, newStorage(t)) })
+	t.Run("List", func(t *testing.T) { testList(t, newStorage(t)) })
+	t.Run("Watch", func(t *testing.T) { testWatch(t, newStorage(t)) })
+	t.Run("WatchFromResourceVersion", func(t *testing.T) { testWatchFromResourceVersion(t, newStorage(t)) })
+	t.Run("WatchTooOld", func(t *testing.T) { testWatchTooOld(t, newStorage(t)) })
+}
+
+func testCRUD(t *testing.T, s Storage) {
+	ctx := context.Background()
+	key := "/registry/pods/default/web"
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	rv1, err := s.Create(ctx, key, []byte(`{"v":1}`))
+	if err != nil || rv1 == 0 {
+		t.Fatalf("create: %d %v", rv1, err)
+	}
+	if _, err := s.Create(ctx, key, []byte(`{"v":2}`)); !errors.Is(err, ErrKeyExists) {
+		t.Fatalf("expected key exists, got %v", err)
+	}
+	kv, err := s.Get(ctx, key)
+	if err != nil || string(kv.Value) != `{"v":1}` || kv.ResourceVersion != rv1 || kv.Key != key {
+		t.Fatalf("get: %+v %v", kv, err)
+	}
+
+	rv2, err := s.Update(ctx, key, []byte(`{"v":2}`), rv1)
+	if err != nil || rv2 <= rv1 {
+		t.Fatalf("update: %d %v", rv2, err)
+	}
+	// 旧的版本号不能再用来更新或者删除
+	if _, err := s.Update(ctx, key, []byte(`{"v":3}`), rv1); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	if _, err := s.Delete(ctx, key, rv1); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	// 版本号为0的时候不检查
+	rv3, err := s.Update(ctx, key, []byte(`{"v":3}`), 0)
+	if err != nil || rv3 <= rv2 {
+		t.Fatalf("unconditional update: %d %v", rv3, err)
+	}
+	if _, err := s.Update(ctx, "/registry/pods/default/missing", []byte(`{}`), 0); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	deleted, err := s.Delete(ctx, key, rv3)
+	if err != nil || string(deleted.Value) != `{"v":3}` || deleted.ResourceVersion != rv3 {
+		t.Fatalf("delete: %+v %v", deleted, err)
+	}
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found after delete, got %v", err)
+	}
+	if _, err := s.Delete(ctx, key, 0); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	// 删除之后可以重新创建，版本号继续递增
+	rv4, err := s.Create(ctx, key, []byte(`{"v":4}`))
+	if err != nil || rv4 <= rv3 {
+		t.Fatalf("create again: %d %v", rv4, err)
+	}
+}
+
+func testList(t *testing.T, s Storage) {
+	ctx := context.Background()
+	for _, key := range []string{
+		"/registry/pods/prod/web",
+		"/registry/pods/default/web",
+		"/registry/pods/default/db",
+		"/registry/pods/defaults/api",
+		"/registry/namespaces/default",
+	} {
+		if _, err := s.Create(ctx, key, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	items, _, err := s.List(ctx, "/registry/pods/default/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Key != "/registry/pods/default/db" || items[1].Key != "/registry/pods/default/web" ||
+		string(items[0].Value) != items[0].Key || items[0].ResourceVersion == 0 {
+		t.Fatalf("unexpected items %+v", items)
+	}
+	if items, _, _ := s.List(ctx, "/registry/pods/"); len(items) != 4 {
+		t.Fatalf("expected 4 pods, got %+v", items)
+	}
+	if items, _, _ := s.List(ctx, ""); len(items) != 5 {
+		t.Fatalf("expected 5 keys, got %+v", items)
+	}
+	if items, _, err := s.List(ctx, "/registry/services/"); err != nil || items == nil || len(items) != 0 {
+		t.Fatalf("expected an empty list, got %+v %v", items, err)
+	}
+}
+
+func nextEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case e, ok := <-events:
+		if !ok {
+			t.Fatal("watch channel closed")
+		}
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	return Event{}
+}
+
+func testWatch(t *testing.T, s Storage) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := s.Watch(ctx, "/registry/pods/default/", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "/registry/pods/default/web"
+	rv1, _ := s.Create(ctx, key, []byte("v1"))
+	// 前缀不匹配的key不会收到
+	s.Create(ctx, "/registry/pods/prod/web", []byte("other"))
+	rv2, _ := s.Update(ctx, key, []byte("v2"), rv1)
+	s.Delete(ctx, key, rv2)
+
+	e := nextEvent(t, events)
+	if e.Type != Added || e.Key != key || string(e.Value) != "v1" || e.ResourceVersion != rv1 {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	e = nextEvent(t, events)
+	if e.Type != Modified || string(e.Value) != "v2" || e.ResourceVersion != rv2 {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	e = nextEvent(t, events)
+	if e.Type != Deleted || string(e.Value) != "v2" || e.ResourceVersion <= rv2 {
+		t.Fatalf("unexpected event %+v", e)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatal("unexpected event after cancel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch channel not closed after cancel")
+	}
+}
+
+// 从List返回的版本号开始Watch，List和Watch之间的写入不会丢
+func testWatchFromResourceVersion(t *testing.T, s Storage) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	key := "/registry/pods/default/web"
+	rv1, _ := s.Create(ctx, key, []byte("v1"))
+	items, listRV, err := s.List(ctx, "/registry/pods/default/")
+	if err != nil || len(items) != 1 || listRV != rv1 {
+		t.Fatalf("unexpected list %+v at %d: %v", items, listRV, err)
+	}
+	// List之后、Watch之前的写入
+	rv2, _ := s.Update(ctx, key, []byte("v2"), rv1)
+	s.Create(ctx, "/registry/pods/prod/web", []byte("other"))
+
+	events, err := s.Watch(ctx, "/registry/pods/default/", listRV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rv3, _ := s.Update(ctx, key, []byte("v3"), rv2)
+
+	e := nextEvent(t, events)
+	if e.Type != Modified || string(e.Value) != "v2" || e.ResourceVersion != rv2 {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	e = nextEvent(t, events)
+	if e.Type != Modified || string(e.Value) != "v3" || e.ResourceVersion != rv3 {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected event %+v", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// 版本号之后的事件已经不在历史中了
+func testWatchTooOld(t *testing.T, s Storage) {
+	ctx := context.Background()
+	key := "/registry/pods/default/web"
+	rv, err := s.Create(ctx, key, []byte("v"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := rv
+	for i := 0; i <= watchHistorySize; i++ {
+		if rv, err = s.Update(ctx, key, []byte("v"), rv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.Watch(ctx, "/registry/", start); !errors.Is(err, ErrResourceVersionTooOld) {
+		t.Fatalf("expected too old, got %v", err)
+	}
+	// 历史中最早的版本号之前一个还可以
+	events, err := s.Watch(ctx, "/registry/", rv-watchHistorySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, events); e.ResourceVersion != rv-watchHistorySize+1 {
+		t.Fatalf("unexpected event %+v", e)
+	}
+}
+
+// 一直不读取的watcher在缓冲满了之后被关闭
+// redis的事件是异步送达的，缓冲什么时候满不确定，所以只测内存的实现
+func TestMemoryStorageSlowWatcher(t *testing.T) {
+	s := NewMemoryStorage()
+	defer s.Close()
+	ctx := context.Background()
+	events, err := s.Watch(ctx, "/registry/", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i <= watchChanSize; i++ {
+		if _, err := s.Create(ctx, "/registry/pods/default/web-"+strconv.Itoa(i), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	timeout := time.After(5 * time.Second)
+	for received := 0; ; received++ {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				if received != watchChanSize {
+					t.Fatalf("expected %d buffered events, got %d", watchChanSize, received)
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatal("slow watcher was not closed")
+		}
+	}
+}
+
+func newMiniredisStorage(t *testing.T) (*redisStorage, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	s, err := NewRedisStorage(context.Background(), RedisOptions{Address: mr.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s.(*redisStorage), mr
+}
+
+func expectWatchClosed(t *testing.T, events <-chan Event) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("watch channel not closed")
+		}
+	}
+}
+
+// 和redis的连接断开之后，go-redis会悄悄重连，中间的事件就丢了，watcher必须被关闭
+func TestRedisWatchClosedWhenSubscriptionDrops(t *testing.T) {
+	s, mr := newMiniredisStorage(t)
+	events, err := s.Watch(context.Background(), "/registry/", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr.Close()
+	expectWatchClosed(t, events)
+}
+
+// 收到的版本号不连续说明丢了事件
+func TestRedisWatchClosedOnResourceVersionGap(t *testing.T) {
+	s, _ := newMiniredisStorage(t)
+	ctx := context.Background()
+	events, err := s.Watch(ctx, "/registry/", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rv, err := s.Create(ctx, "/registry/pods/default/web", []byte("v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-events:
+		if e.Type != Added || e.ResourceVersion != rv {
+			t.Fatalf("unexpected event %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+	// 跳过一个版本号，模拟丢失的事件
+	if err := s.client.Incr(ctx, s.versionKey).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Update(ctx, "/registry/pods/default/web", []byte("v2"), rv); err != nil {
+		t.Fatal(err)
+	}
+	expectWatchClosed(t, events)
+}
@@ -4,6 +4,14 @@ import (
 	"github.com/google/uuid"
 )
 
+// 静态pod等场景下需要稳定的uid，内容相同的输入总是得到相同的uid
+var minik8sNamespace = uuid.MustParse("6f1c3f0e-5b2a-4e43-9a43-2f4d5b2c6a11")
+
 func NewUID() string {
 	return uuid.New().String()
 }
+
+// 根据内容生成一个确定的uid(uuid v5)
+func NewUIDFromContent(content []byte) string {
+	return uuid.NewSHA1(minik8sNamespace, content).String()
+}